@@ -23,17 +23,29 @@ const (
 	ActSyncLB    = "synclb"
 	ActCreateLB  = "createlb"
 	ActDestroyLB = "destroylb"
+	ActRenameLB  = "renamelb" // rename local bucket: ActionMsg.Name carries the new name
 	ActSetConfig = "setconfig"
 	ActRename    = "rename"
 	ActEvict     = "evict"
 	ActDelete    = "delete"
 	ActPrefetch  = "prefetch"
+	ActSetProps  = "setprops"
+	ActCopy      = "copy" // server-side copy: see CopyMsg and RangeListMsgBase.DestBucket
+	ActMove      = "move" // same as ActCopy followed by the removal of the source
+	// multipart upload: ActionMsg.Name carries the upload ID (except initiate)
+	ActMultipartInit     = "mpinit"
+	ActMultipartComplete = "mpcomplete"
+	ActMultipartAbort    = "mpabort"
 )
 
-// Cloud Provider enum
+// Cloud Provider enum: names of the built-in cloud backends (see CloudProviders()
+// for all registered ones) and ProviderDfc for DFC-local buckets
 const (
 	ProviderAmazon = "aws"
 	ProviderGoogle = "gcp"
+	ProviderAzure  = "azure"
+	ProviderFS     = "fs"   // local or shared (e.g., NFS) filesystem: see config "cloud_fs_root"
+	ProviderMock   = "mock" // in-memory, for testing: see config "mock_cloud"
 	ProviderDfc    = "dfc"
 )
 
@@ -44,8 +56,25 @@ const (
 	HeaderDfcChecksumType = "HeaderDfcChecksumType" // Checksum Type (xxhash, md5, none)
 	HeaderDfcChecksumVal  = "HeaderDfcChecksumVal"  // Checksum Value
 	HeaderDfcObjVersion   = "HeaderDfcObjVersion"   // Object version/generation
+	HeaderDfcObjIsCached  = "HeaderDfcObjIsCached"  // true if the object is present in DFC cache
+	HeaderDfcObjAtime     = "HeaderDfcObjAtime"     // Object access time (RFC822) - cached objects only
 	HeaderPrimaryProxyURL = "PrimaryProxyURL"       // URL of Primary Proxy
 	HeaderPrimaryProxyID  = "PrimaryProxyID"        // ID of Primary Proxy
+	HeaderDfcECMeta       = "HeaderDfcECMeta"       // Erasure coded slice metadata (intra-cluster)
+	HeaderDfcMetaPrefix   = "X-Dfc-Meta-"           // User-defined object metadata: X-Dfc-Meta-<key>: <value>
+)
+
+// Header Key enum: bucket properties in effect (HEAD bucket), that is, the
+// per-bucket overrides (see BucketProps) or else the cluster-wide configuration
+const (
+	HeaderBucketChecksum        = "BucketChecksum"        // DFC checksum type: xxhash, none
+	HeaderBucketValidateColdGet = "BucketValidateColdGet" // MD5 (ETag) validation upon cold GET
+	HeaderBucketValidateWarmGet = "BucketValidateWarmGet" // object version validation upon warm GET
+	HeaderBucketLRUEnabled      = "BucketLRUEnabled"      // whether LRU evicts the bucket's objects
+	HeaderBucketLRULowWM        = "BucketLRULowWM"        // LRU low watermark
+	HeaderBucketLRUHighWM       = "BucketLRUHighWM"       // LRU high watermark
+	HeaderBucketNextTierURL     = "BucketNextTierURL"     // URL of the next tier
+	HeaderBucketPinned          = "BucketPinned"          // LRU never evicts the objects of a pinned bucket
 )
 
 // URL Query Parameter enum
@@ -59,24 +88,99 @@ const (
 	URLParamPrimaryCandidate = "candidate"  // candidate=string - id of candidate for primary proxy
 	URLParamForce            = "force"      // force=bool - Must be true to shutdown the primary proxy
 	URLParamPrepare          = "prepare"    // prepare=bool - if true, this request is the prepare phase for primary proxy change
+	URLParamUploadID         = "uploadid"   // uploadid=string - multipart upload ID returned by ActMultipartInit
+	URLParamPartNum          = "partnum"    // partnum=int - multipart upload part number, starting from 1
+	URLParamReplica          = "replica"    // replica=true - intra-cluster: the request is for the object's replica
+	URLParamWhat             = "what"       // what=locate - GET /v1/objects/bucket-name/object-name: ObjectLocation instead of the object
 )
 
-// TODO: sort and some props are TBD
+// TODO: some props are TBD
 // GetMsg represents properties and options for get requests
 type GetMsg struct {
-	GetWhat       string `json:"what"`        // "config" | "stats" ...
-	GetSort       string `json:"sort"`        // "ascending, atime" | "descending, name"
-	GetProps      string `json:"props"`       // e.g. "checksum, size" | "atime, size" | "ctime, iscached" | "bucket, size"
-	GetTimeFormat string `json:"time_format"` // "RFC822" default - see the enum below
-	GetPrefix     string `json:"prefix"`      // object name filter: return only objects which name starts with prefix
-	GetPageMarker string `json:"pagemarker"`  // AWS/GCP: marker
-	GetPageSize   int    `json:"pagesize"`    // maximum number of entries returned by list bucket call
+	GetWhat        string `json:"what"`                   // "config" | "stats" ...
+	GetSort        string `json:"sort"`                   // "ascending, atime" | "descending, name"
+	GetProps       string `json:"props"`                  // e.g. "checksum, size" | "atime, size" | "ctime, iscached" | "bucket, size"
+	GetTimeFormat  string `json:"time_format"`            // "RFC822" default - see the enum below
+	GetPrefix      string `json:"prefix"`                 // object name filter: return only objects which name starts with prefix
+	GetDelimiter   string `json:"delimiter"`              // roll up the names that contain delimiter past prefix into CommonPrefixes
+	GetRegex       string `json:"regex,omitempty"`        // object name filter past prefix - same as RangeMsg.Regex
+	GetRange       string `json:"range,omitempty"`        // "min:max" range of the number matched by regex - same as RangeMsg.Range
+	GetMinSize     int64  `json:"min_size,omitempty"`     // size filter, bytes (inclusive)
+	GetMaxSize     int64  `json:"max_size,omitempty"`     // ditto; zero - no limit
+	GetCtimeAfter  string `json:"ctime_after,omitempty"`  // ctime filter, RFC3339: return only objects created at or after
+	GetCtimeBefore string `json:"ctime_before,omitempty"` // ditto: before
+	GetPageMarker  string `json:"pagemarker"`             // AWS/GCP: marker
+	GetPageSize    int    `json:"pagesize"`               // maximum number of entries returned by list bucket call
+}
+
+// MultipartUpload is returned in response to ActMultipartInit
+type MultipartUpload struct {
+	UploadID string `json:"uploadid"`
+	Bucket   string `json:"bucket"`
+	Objname  string `json:"objname"`
+}
+
+// ECConf configures erasure coding of a local bucket: each object is split into
+// DataSlices data slices protected by ParitySlices parity slices, so that the
+// object survives the loss of any ParitySlices targets; erasure coding is disabled
+// when DataSlices is zero
+type ECConf struct {
+	DataSlices   int `json:"data_slices"`
+	ParitySlices int `json:"parity_slices"`
+}
+
+// BucketProps are the properties of a local bucket that can be specified at
+// creation time: ActionMsg{Action: ActCreateLB, Value: BucketProps}; all but
+// erasure coding and replication can be changed later via ActSetProps
+type BucketProps struct {
+	EC ECConf `json:"ec"`
+	// N-way replication: total number of copies of each object (0 or 1 - no replicas)
+	// stored by the first Copies targets in the HRW order; replicas are created
+	// before PUT returns or, if CopiesAsync, in background
+	Copies      int  `json:"copies"`
+	CopiesAsync bool `json:"copies_async"`
+	// per-bucket overrides of the cluster-wide configuration: nil - not overridden
+	CksumConf   *CksumConf   `json:"cksum_config,omitempty"`
+	VersionConf *VersionConf `json:"version_config,omitempty"`
+	LRUConf     *LRUConf     `json:"lru_config,omitempty"`
+	// NextTierURL is the URL of another DFC cluster (proxy) to GET the objects
+	// that are not present in this one
+	NextTierURL string `json:"next_tier_url,omitempty"`
+	// Pinned bucket's objects are never evicted by LRU
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// CksumConf overrides config "cksum_config" for a given bucket
+type CksumConf struct {
+	Checksum        string `json:"checksum"`          // DFC checksum: xxhash:none
+	ValidateColdGet bool   `json:"validate_cold_get"` // MD5 (ETag) validation upon cold GET
+}
+
+// VersionConf overrides config "version_config" for a given bucket
+type VersionConf struct {
+	ValidateWarmGet bool   `json:"validate_warm_get"` // True: validate object version upon warm GET
+	Versioning      string `json:"versioning"`        // types of objects versioning is enabled for: all, cloud, local, none
+}
+
+// LRUConf overrides LRU enable and watermarks (config "lru_config") for a given bucket;
+// the watermarks apply to the bucket's own usage of each mountpath (see getBucketToEvict)
+type LRUConf struct {
+	LowWM      uint32 `json:"lowwm"`
+	HighWM     uint32 `json:"highwm"`
+	LRUEnabled bool   `json:"lru_enabled"`
 }
 
 // RangeListMsgBase contains fields common to Range and List operations
 type RangeListMsgBase struct {
-	Deadline time.Duration `json:"deadline,omitempty"`
-	Wait     bool          `json:"wait,omitempty"`
+	Deadline   time.Duration `json:"deadline,omitempty"`
+	Wait       bool          `json:"wait,omitempty"`
+	DestBucket string        `json:"dest_bucket,omitempty"` // ActCopy and ActMove only
+}
+
+// CopyMsg is the destination of the object copy or move: ActionMsg{Action: ActCopy|ActMove, Value: CopyMsg}
+type CopyMsg struct {
+	DestBucket  string `json:"dest_bucket"`
+	DestObjname string `json:"dest_objname,omitempty"` // default: the name of the source object
 }
 
 // ListMsg contains a list of files and a duration within which to get them
@@ -101,10 +205,13 @@ type RangeMsg struct {
 
 // GetMsg.GetWhat enum
 const (
-	GetWhatFile   = "file" // { "what": "file" } is implied by default and can be omitted
-	GetWhatConfig = "config"
-	GetWhatSmap   = "smap"
-	GetWhatStats  = "stats"
+	GetWhatFile    = "file" // { "what": "file" } is implied by default and can be omitted
+	GetWhatConfig  = "config"
+	GetWhatSmap    = "smap"
+	GetWhatStats   = "stats"
+	GetWhatProps   = "props"   // GET /v1/buckets/bucket-name: properties (BucketProps) instead of the list of objects
+	GetWhatLocate  = "locate"  // GET /v1/objects/bucket-name/object-name?what=locate - see ObjectLocation
+	GetWhatSummary = "summary" // GET /v1/buckets/bucket-name: BucketSummary instead of the list of objects
 )
 
 // GetMsg.GetSort enum
@@ -157,8 +264,57 @@ type BucketEntry struct {
 
 // BucketList represents the contents of a given bucket - somewhat analagous to the 'ls <bucket-name>'
 type BucketList struct {
-	Entries    []*BucketEntry `json:"entries"`
-	PageMarker string         `json:"pagemarker"`
+	Entries        []*BucketEntry `json:"entries"`
+	PageMarker     string         `json:"pagemarker"`
+	CommonPrefixes []string       `json:"commonprefixes,omitempty"` // see GetMsg.GetDelimiter
+}
+
+// BucketSummary is the number of objects and bytes the cluster stores in a bucket
+// (for a Cloud bucket - caches), in total and per target and mountpath; for a Cloud
+// bucket it also includes the totals in the Cloud and the cached fraction of bytes
+type BucketSummary struct {
+	Bucket string `json:"bucket"`
+	Local  bool   `json:"local"`
+	BucketUsage
+	Targets        map[string]*TargetBucketSummary `json:"targets"`                   // by daemon ID
+	CloudObjects   int64                           `json:"cloud_objects,omitempty"`   // Cloud bucket: objects in the Cloud
+	CloudBytes     int64                           `json:"cloud_bytes,omitempty"`     // Cloud bucket: bytes in the Cloud
+	CachedFraction float64                         `json:"cached_fraction,omitempty"` // Cloud bucket: Bytes/CloudBytes
+}
+
+// TargetBucketSummary is the target's part of BucketSummary
+type TargetBucketSummary struct {
+	BucketUsage
+	Mountpaths map[string]*BucketUsage `json:"mountpaths"`
+}
+
+// BucketUsage is the number of objects and bytes
+type BucketUsage struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// ObjectLocation tells where the cluster stores the object: the target and the
+// mountpath as per HRW, and the copies found elsewhere (replicas, leftovers of
+// an interrupted rebalance, etc.)
+type ObjectLocation struct {
+	Bucket    string            `json:"bucket"`
+	Objname   string            `json:"objname"`
+	DaemonID  string            `json:"daemon_id"`        // HRW target
+	Mountpath string            `json:"mountpath"`        // HRW mountpath of the target
+	FQN       string            `json:"fqn"`              // fully qualified name of the object on the target
+	Present   bool              `json:"present"`          // true if the object is stored at FQN
+	Size      int64             `json:"size"`             // size in bytes, if present
+	Copies    []*ObjectCopy     `json:"copies,omitempty"` // the object found elsewhere
+	Errors    map[string]string `json:"errors,omitempty"` // the targets that failed to look for the object: ID => error
+}
+
+// ObjectCopy is a copy of the object stored other than as per HRW
+type ObjectCopy struct {
+	DaemonID string `json:"daemon_id"`
+	FQN      string `json:"fqn"`
+	Size     int64  `json:"size"`
+	Replica  bool   `json:"replica"` // true if the copy is the object's replica - see BucketProps.Copies
 }
 
 // All bucket names known to the system
@@ -185,4 +341,7 @@ const (
 	Rproxy     = "proxy"
 	Rvoteres   = "result"
 	Rvoteinit  = "init"
+	Rs3        = "s3"        // S3-compatible front-end: /s3/bucket-name/object-name
+	Rslices    = "slices"    // target only: erasure coded slices /v1/slices/bucket-name/object-name
+	Rmockcloud = "mockcloud" // primary proxy only: mock Cloud store /v1/mockcloud/bucket-name/object-name
 )
@@ -8,6 +8,7 @@ package dfc
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -27,8 +28,11 @@ const (
 	awsPutDfcHashVal  = "x-amz-meta-dfc-hash-val"
 	awsGetDfcHashType = "X-Amz-Meta-Dfc-Hash-Type"
 	awsGetDfcHashVal  = "X-Amz-Meta-Dfc-Hash-Val"
+	awsMetaPrefix     = "X-Amz-Meta-"
 	awsMultipartDelim = "-"
 	awsMaxPageSize    = 1000
+
+	awsUploadConcurrency = 8 // S3 multipart: number of parts uploaded in parallel
 )
 
 //======
@@ -40,6 +44,10 @@ type awsimpl struct {
 	t *targetrunner
 }
 
+func init() {
+	registerCloudProvider(ProviderAmazon, func(t *targetrunner) cloudif { return &awsimpl{t} })
+}
+
 //======
 //
 // session FIXME: optimize
@@ -80,6 +88,9 @@ func (awsimpl *awsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	if msg.GetPrefix != "" {
 		params.Prefix = aws.String(msg.GetPrefix)
 	}
+	if msg.GetDelimiter != "" {
+		params.Delimiter = aws.String(msg.GetDelimiter)
+	}
 	if msg.GetPageMarker != "" {
 		params.Marker = aws.String(msg.GetPageMarker)
 	}
@@ -152,6 +163,9 @@ func (awsimpl *awsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 		// TODO: other GetMsg props TBD
 		reslist.Entries = append(reslist.Entries, entry)
 	}
+	for _, cp := range resp.CommonPrefixes {
+		reslist.CommonPrefixes = append(reslist.CommonPrefixes, *cp.Prefix)
+	}
 	if glog.V(4) {
 		glog.Infof("listbucket count %d", len(reslist.Entries))
 	}
@@ -159,7 +173,11 @@ func (awsimpl *awsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	if *resp.IsTruncated {
 		// For AWS, resp.NextMarker is only set when a query has a delimiter.
 		// Without a delimiter, NextMarker should be the last returned key.
-		reslist.PageMarker = reslist.Entries[len(reslist.Entries)-1].Name
+		if resp.NextMarker != nil {
+			reslist.PageMarker = *resp.NextMarker
+		} else {
+			reslist.PageMarker = reslist.Entries[len(reslist.Entries)-1].Name
+		}
 	}
 
 	jsbytes, err = json.Marshal(reslist)
@@ -183,7 +201,6 @@ func (awsimpl *awsimpl) headbucket(bucket string) (bucketprops map[string]string
 		errstr = fmt.Sprintf("The bucket %s either does not exist or is not accessible, err: %v", bucket, err)
 		return
 	}
-	bucketprops[CloudProvider] = ProviderAmazon
 
 	inputVers := &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)}
 	result, err := svc.GetBucketVersioning(inputVers)
@@ -240,10 +257,12 @@ func (awsimpl *awsimpl) headobject(bucket string, objname string) (objmeta map[s
 		errstr = fmt.Sprintf("Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
 		return
 	}
-	objmeta[CloudProvider] = ProviderAmazon
 	if awsIsVersionSet(headOutput.VersionId) {
 		objmeta["version"] = *headOutput.VersionId
 	}
+	if headOutput.ContentLength != nil {
+		objmeta["size"] = strconv.FormatInt(*headOutput.ContentLength, 10)
+	}
 	return
 }
 
@@ -253,13 +272,36 @@ func (awsimpl *awsimpl) headobject(bucket string, objname string) (objmeta map[s
 //
 //=======================
 func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
-	var v cksumvalue
+	var (
+		v         cksumvalue
+		dl        *dlprogress
+		off, size int64 = 0, -1
+	)
 	sess := createsession()
 	svc := s3.New(sess)
-	obj, err := svc.GetObject(&s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(objname),
-	})
+	}
+	// resume the interrupted download of the same object (ETag)
+	if awsimpl.t.resumable(fqn) {
+		head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(objname)})
+		if err == nil && head.ETag != nil && head.ContentLength != nil {
+			dl = awsimpl.t.resumedownload(fqn, *head.ETag, *head.ContentLength)
+			off, input.IfMatch = dl.Offset, head.ETag
+		}
+	}
+	// ranged cold GET: GET the first part to find out the size
+	if rangedcoldget(size) {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", off, off+ctx.config.ColdGet.PartSize-1))
+	} else if off > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", off))
+	}
+	obj, err := svc.GetObject(input)
+	if err != nil && off == 0 && input.Range != nil && awsErrorToHTTP(err) == http.StatusRequestedRangeNotSatisfiable {
+		input.Range = nil // empty object
+		obj, err = svc.GetObject(input)
+	}
 	if err != nil {
 		errcode = awsErrorToHTTP(err)
 		errstr = fmt.Sprintf("Failed to GET %s/%s, err: %v", bucket, objname, err)
@@ -284,7 +326,46 @@ func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps,
 	if obj.VersionId != nil {
 		props.version = *obj.VersionId
 	}
-	if _, props.nhobj, props.size, errstr = awsimpl.t.receive(fqn, false, objname, md5, v, obj.Body); errstr != "" {
+	md := make(map[string]string, len(obj.Metadata))
+	for k, v := range obj.Metadata {
+		if v != nil {
+			md[strings.TrimPrefix(k, awsMetaPrefix)] = *v
+		}
+	}
+	props.meta = cloudmeta(md, strings.TrimPrefix(awsGetDfcHashType, awsMetaPrefix),
+		strings.TrimPrefix(awsGetDfcHashVal, awsMetaPrefix))
+	if obj.ContentRange != nil {
+		if size, err = parseContentRange(*obj.ContentRange); err != nil {
+			errstr = fmt.Sprintf("Failed to GET %s/%s, err: %v", bucket, objname, err)
+			return
+		}
+	} else if obj.ContentLength != nil {
+		size = *obj.ContentLength
+	}
+	if dl == nil {
+		dl = &dlprogress{Version: *obj.ETag, Size: size}
+	}
+	if rangedcoldget(size) {
+		// the rest of the parts - of the same object version
+		getrange := func(off, length int64) (io.ReadCloser, string) {
+			part, err := svc.GetObject(&s3.GetObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       aws.String(objname),
+				Range:     aws.String(fmt.Sprintf("bytes=%d-%d", off, off+length-1)),
+				IfMatch:   obj.ETag,
+				VersionId: obj.VersionId,
+			})
+			if err != nil {
+				return nil, fmt.Sprintf("Failed to GET %s/%s range [%d, %d), err: %v", bucket, objname, off, off+length, err)
+			}
+			return part.Body, ""
+		}
+		if props.nhobj, props.size, errstr = awsimpl.t.receiveranges(fqn, bucket, objname, md5, v, dl,
+			obj.Body, getrange); errstr != "" {
+			return
+		}
+	} else if _, props.nhobj, props.size, errstr = awsimpl.t.receiveresumable(fqn, false, bucket, objname, md5, v, dl,
+		obj.Body); errstr != "" {
 		return
 	}
 	if glog.V(4) {
@@ -293,7 +374,8 @@ func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps,
 	return
 }
 
-func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue) (version string, errstr string, errcode int) {
+func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue,
+	meta map[string]string) (version string, errstr string, errcode int) {
 	var (
 		err          error
 		htype, hval  string
@@ -306,8 +388,25 @@ func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksu
 		md[awsPutDfcHashType] = aws.String(htype)
 		md[awsPutDfcHashVal] = aws.String(hval)
 	}
+	for k, v := range meta {
+		if md == nil {
+			md = make(map[string]*string)
+		}
+		md[k] = aws.String(v)
+	}
+	// large objects are uploaded via S3 multipart; the part size must be adjusted
+	// for the object to fit into s3manager.MaxUploadParts
+	partsize := s3manager.DefaultUploadPartSize
+	if finfo, err := file.Stat(); err == nil {
+		if n := finfo.Size()/s3manager.MaxUploadParts + 1; n > partsize {
+			partsize = n
+		}
+	}
 	sess := createsession()
-	uploader := s3manager.NewUploader(sess)
+	uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		u.PartSize = partsize
+		u.Concurrency = awsUploadConcurrency
+	})
 	uploadoutput, err = uploader.Upload(&s3manager.UploadInput{
 		Bucket:   aws.String(bucket),
 		Key:      aws.String(objname),
@@ -0,0 +1,406 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/golang/glog"
+)
+
+const (
+	// Azure metadata keys must be valid C# identifiers - hence, no dashes
+	azureDfcHashType = "dfchashtype"
+	azureDfcHashVal  = "dfchashval"
+	// user-defined metadata keys are prefixed and escaped to become valid identifiers
+	azureDfcMetaPrefix = "dfcmeta_"
+
+	azureMaxPageSize = 5000
+
+	azureBlockSize         = 16 * 1024 * 1024 // block blob upload: size of the block staged per request
+	azureUploadParallelism = 8                // block blob upload: number of blocks staged in parallel
+	azureGetRetries        = 3                // GET: number of times to resume interrupted download
+)
+
+//======
+//
+// implements cloudif
+//
+//======
+type azureimpl struct {
+	t *targetrunner
+}
+
+func init() {
+	registerCloudProvider(ProviderAzure, func(t *targetrunner) cloudif { return &azureimpl{t} })
+}
+
+//======
+//
+// global - FIXME: environ
+//
+//======
+func azureErrorToHTTP(azureError error) int {
+	if stgerr, ok := azureError.(azblob.StorageError); ok && stgerr.Response() != nil {
+		return stgerr.Response().StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// Azure ETag serves as the object version: it is updated on every write
+func azureVersion(etag azblob.ETag) string {
+	return strings.Trim(string(etag), "\"")
+}
+
+// the storage account and its key are taken from the environment
+func createserviceurl() (serviceURL azblob.ServiceURL, errstr string) {
+	account, key := os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")
+	if account == "" || key == "" {
+		errstr = "Failed to get Azure storage account credentials (AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_ACCESS_KEY)"
+		return
+	}
+	cred, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		errstr = fmt.Sprintf("Failed to create Azure credentials, err: %v", err)
+		return
+	}
+	u, err := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net", account))
+	if err != nil {
+		errstr = fmt.Sprintf("Failed to parse Azure endpoint URL, err: %v", err)
+		return
+	}
+	serviceURL = azblob.NewServiceURL(*u, azblob.NewPipeline(cred, azblob.PipelineOptions{}))
+	return
+}
+
+func createcontainerurl(bucket string) (containerURL azblob.ContainerURL, errstr string) {
+	serviceURL, errstr := createserviceurl()
+	if errstr != "" {
+		return
+	}
+	containerURL = serviceURL.NewContainerURL(bucket)
+	return
+}
+
+//==================
+//
+// bucket operations
+//
+//==================
+func (azureimpl *azureimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("listbucket %s", bucket)
+	}
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	opts := azblob.ListBlobsSegmentOptions{Prefix: msg.GetPrefix, MaxResults: azureMaxPageSize}
+	if msg.GetPageSize != 0 && msg.GetPageSize < azureMaxPageSize {
+		opts.MaxResults = int32(msg.GetPageSize)
+	}
+	marker := azblob.Marker{}
+	if msg.GetPageMarker != "" {
+		marker.Val = &msg.GetPageMarker
+	}
+	var (
+		reslist = BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
+		blobs   []azblob.BlobItemInternal
+		err     error
+	)
+	if msg.GetDelimiter == "" {
+		var resp *azblob.ListBlobsFlatSegmentResponse
+		if resp, err = containerURL.ListBlobsFlatSegment(context.Background(), marker, opts); err == nil {
+			blobs, marker = resp.Segment.BlobItems, resp.NextMarker
+		}
+	} else {
+		var resp *azblob.ListBlobsHierarchySegmentResponse
+		resp, err = containerURL.ListBlobsHierarchySegment(context.Background(), marker, msg.GetDelimiter, opts)
+		if err == nil {
+			blobs, marker = resp.Segment.BlobItems, resp.NextMarker
+			for _, cp := range resp.Segment.BlobPrefixes {
+				reslist.CommonPrefixes = append(reslist.CommonPrefixes, cp.Name)
+			}
+		}
+	}
+	if err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to list objects of bucket %s, err: %v", bucket, err)
+		return
+	}
+
+	if marker.NotDone() {
+		reslist.PageMarker = *marker.Val
+	}
+	for _, blob := range blobs {
+		entry := &BucketEntry{}
+		entry.Name = blob.Name
+		if strings.Contains(msg.GetProps, GetPropsSize) && blob.Properties.ContentLength != nil {
+			entry.Size = *blob.Properties.ContentLength
+		}
+		if strings.Contains(msg.GetProps, GetPropsBucket) {
+			entry.Bucket = bucket
+		}
+		if strings.Contains(msg.GetProps, GetPropsCtime) {
+			t := blob.Properties.LastModified
+			switch msg.GetTimeFormat {
+			case "":
+				fallthrough
+			case RFC822:
+				entry.Ctime = t.Format(time.RFC822)
+			default:
+				entry.Ctime = t.Format(msg.GetTimeFormat)
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsChecksum) {
+			entry.Checksum = hex.EncodeToString(blob.Properties.ContentMD5)
+		}
+		if strings.Contains(msg.GetProps, GetPropsVersion) {
+			entry.Version = azureVersion(blob.Properties.Etag)
+		}
+		// TODO: other GetMsg props TBD
+
+		reslist.Entries = append(reslist.Entries, entry)
+	}
+	if glog.V(4) {
+		glog.Infof("listbucket count %d", len(reslist.Entries))
+	}
+	jsbytes, err = json.Marshal(reslist)
+	assert(err == nil, err)
+	return
+}
+
+func (azureimpl *azureimpl) headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headbucket %s", bucket)
+	}
+	bucketprops = make(map[string]string)
+
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	if _, err := containerURL.GetProperties(context.Background(), azblob.LeaseAccessConditions{}); err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("The bucket %s either does not exist or is not accessible, err: %v", bucket, err)
+		return
+	}
+	// every write updates blob's ETag which is then used to detect version changes
+	bucketprops[Versioning] = VersionCloud
+	return
+}
+
+func (azureimpl *azureimpl) getbucketnames() (buckets []string, errstr string, errcode int) {
+	serviceURL, errstr := createserviceurl()
+	if errstr != "" {
+		return
+	}
+	buckets = make([]string, 0, 16)
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := serviceURL.ListContainersSegment(context.Background(), marker, azblob.ListContainersSegmentOptions{})
+		if err != nil {
+			errcode = azureErrorToHTTP(err)
+			errstr = fmt.Sprintf("Failed to list all buckets, err: %v", err)
+			return
+		}
+		for _, container := range resp.ContainerItems {
+			buckets = append(buckets, container.Name)
+			if glog.V(4) {
+				glog.Infof("%s: modified %v", container.Name, container.Properties.LastModified)
+			}
+		}
+		marker = resp.NextMarker
+	}
+	return
+}
+
+//============
+//
+// object meta
+//
+//============
+func (azureimpl *azureimpl) headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headobject %s/%s", bucket, objname)
+	}
+	objmeta = make(map[string]string)
+
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	blobURL := containerURL.NewBlobURL(objname)
+	props, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
+		return
+	}
+	objmeta["version"] = azureVersion(props.ETag())
+	objmeta["size"] = strconv.FormatInt(props.ContentLength(), 10)
+	return
+}
+
+//=======================
+//
+// object data operations
+//
+//=======================
+func (azureimpl *azureimpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
+	var v cksumvalue
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	actx := context.Background()
+	blobURL := containerURL.NewBlobURL(objname)
+	resp, err := blobURL.Download(actx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false,
+		azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: azureGetRetries})
+	defer body.Close()
+	// may not have dfc metadata
+	md := azureDecodeMeta(resp.NewMetadata())
+	if htype, ok := md[azureDfcHashType]; ok {
+		if hval, ok := md[azureDfcHashVal]; ok {
+			v = newcksumvalue(htype, hval)
+		}
+	}
+	// Content-MD5 is only maintained for blobs uploaded in a single request
+	md5 := hex.EncodeToString(resp.ContentMD5())
+	props = &objectProps{version: azureVersion(resp.ETag()), meta: cloudmeta(md, azureDfcHashType, azureDfcHashVal)}
+	if _, props.nhobj, props.size, errstr = azureimpl.t.receive(fqn, false, bucket, objname, md5, v, body); errstr != "" {
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("GET %s/%s", bucket, objname)
+	}
+	return
+}
+
+func (azureimpl *azureimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue,
+	meta map[string]string) (version string, errstr string, errcode int) {
+	var md azblob.Metadata
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	if ohash != nil {
+		htype, hval := ohash.get()
+		md = azblob.Metadata{azureDfcHashType: htype, azureDfcHashVal: hval}
+	}
+	// NOTE: Azure requires metadata names to be valid C# identifiers
+	for k, v := range meta {
+		if md == nil {
+			md = azblob.Metadata{}
+		}
+		md[azureEncodeMetaKey(k)] = v
+	}
+	// Azure native block upload: the object is staged in azureBlockSize blocks
+	// (in parallel) and then committed as a whole
+	resp, err := azblob.UploadFileToBlockBlob(context.Background(), file, containerURL.NewBlockBlobURL(objname),
+		azblob.UploadToBlockBlobOptions{
+			BlockSize:   azureBlockSize,
+			Metadata:    md,
+			Parallelism: azureUploadParallelism,
+		})
+	if err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to PUT %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	version = azureVersion(resp.ETag())
+	if glog.V(4) {
+		glog.Infof("PUT %s/%s, version %s", bucket, objname, version)
+	}
+	return
+}
+
+func (azureimpl *azureimpl) deleteobj(bucket, objname string) (errstr string, errcode int) {
+	containerURL, errstr := createcontainerurl(bucket)
+	if errstr != "" {
+		return
+	}
+	blobURL := containerURL.NewBlobURL(objname)
+	_, err := blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	if err != nil {
+		errcode = azureErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to DELETE %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("DELETE %s/%s", bucket, objname)
+	}
+	return
+}
+
+// azureEncodeMetaKey makes a valid C# identifier out of the user-defined metadata
+// key: the key is prefixed, and each character other than [a-z0-9] is replaced
+// with '_' followed by its two hex digits
+func azureEncodeMetaKey(k string) string {
+	var b strings.Builder
+	b.WriteString(azureDfcMetaPrefix)
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// azureDecodeMeta reverses azureEncodeMetaKey for the keys that have the prefix;
+// all other (native) metadata is returned as is
+func azureDecodeMeta(md azblob.Metadata) azblob.Metadata {
+	decoded := make(azblob.Metadata, len(md))
+	for k, v := range md {
+		key := strings.ToLower(k)
+		if !strings.HasPrefix(key, azureDfcMetaPrefix) {
+			decoded[k] = v
+			continue
+		}
+		var (
+			b   []byte
+			enc = key[len(azureDfcMetaPrefix):]
+			ok  = true
+		)
+		for i := 0; i < len(enc) && ok; i++ {
+			if enc[i] != '_' {
+				b = append(b, enc[i])
+				continue
+			}
+			if i+2 < len(enc) {
+				if c, err := strconv.ParseUint(enc[i+1:i+3], 16, 8); err == nil {
+					b, i = append(b, byte(c)), i+2
+					continue
+				}
+			}
+			ok = false
+		}
+		if ok {
+			decoded[string(b)] = v
+		} else {
+			decoded[k] = v
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,176 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// bucket properties: the per-bucket overrides (BucketProps) of the cluster-wide
+// configuration are stored in the bucket map (local buckets and the Cloud buckets
+// that have overrides) and distributed with synclbmap; the accessors below return
+// the configuration in effect for a given bucket
+//
+//======
+
+// bucketprops looks up local buckets first - same as islocalBucket
+func (t *targetrunner) bucketprops(bucket string) (props BucketProps, ok bool) {
+	if props, ok = t.lbmap.LBmap[bucket]; !ok {
+		props, ok = t.lbmap.CBmap[bucket]
+	}
+	return
+}
+
+func (t *targetrunner) cksumconf(bucket string) *cksumconfig {
+	if props, ok := t.bucketprops(bucket); ok && props.CksumConf != nil {
+		conf := cksumconfig(*props.CksumConf)
+		return &conf
+	}
+	return &ctx.config.Cksum
+}
+
+func (t *targetrunner) versionconf(bucket string) *versionconfig {
+	if props, ok := t.bucketprops(bucket); ok && props.VersionConf != nil {
+		conf := versionconfig(*props.VersionConf)
+		return &conf
+	}
+	return &ctx.config.Ver
+}
+
+// lruconf returns the bucket's LRU override or nil if there's none
+func (t *targetrunner) lruconf(bucket string) *LRUConf {
+	if props, ok := t.bucketprops(bucket); ok && props.LRUConf != nil {
+		conf := *props.LRUConf
+		return &conf
+	}
+	return nil
+}
+
+func (t *targetrunner) pinned(bucket string) bool {
+	props, _ := t.bucketprops(bucket)
+	return props.Pinned
+}
+
+// ownlru returns true if the bucket is excluded from the cluster-wide LRU:
+// the bucket is either pinned or has its own LRU configuration
+func (t *targetrunner) ownlru(bucket string) bool {
+	return t.pinned(bucket) || t.lruconf(bucket) != nil
+}
+
+// lruhighwm returns the lowest high watermark among the cluster-wide LRU configuration
+// and the buckets' overrides - LRU is enabled for - and false if LRU is disabled for all
+func (t *targetrunner) lruhighwm() (hwm uint32, enabled bool) {
+	if ctx.config.LRU.LRUEnabled {
+		hwm, enabled = ctx.config.LRU.HighWM, true
+	}
+	lruhwm := func(buckets map[string]BucketProps) {
+		for bucket := range buckets {
+			conf := t.lruconf(bucket)
+			if conf != nil && conf.LRUEnabled && !t.pinned(bucket) && (!enabled || conf.HighWM < hwm) {
+				hwm, enabled = conf.HighWM, true
+			}
+		}
+	}
+	lruhwm(t.lbmap.LBmap)
+	lruhwm(t.lbmap.CBmap)
+	return
+}
+
+func (t *targetrunner) nexttier(bucket string) string {
+	props, _ := t.bucketprops(bucket)
+	return props.NextTierURL
+}
+
+// bucketpropsHeaders adds the bucket properties in effect to HEAD bucket response
+func (t *targetrunner) bucketpropsHeaders(w http.ResponseWriter, bucket string) {
+	var (
+		cksumconf   = t.cksumconf(bucket)
+		versionconf = t.versionconf(bucket)
+		lruconf     = t.lruconf(bucket)
+	)
+	if lruconf == nil {
+		lruconf = &LRUConf{LowWM: ctx.config.LRU.LowWM, HighWM: ctx.config.LRU.HighWM, LRUEnabled: ctx.config.LRU.LRUEnabled}
+	}
+	w.Header().Add(HeaderBucketChecksum, cksumconf.Checksum)
+	w.Header().Add(HeaderBucketValidateColdGet, strconv.FormatBool(cksumconf.ValidateColdGet))
+	w.Header().Add(HeaderBucketValidateWarmGet, strconv.FormatBool(versionconf.ValidateWarmGet))
+	w.Header().Add(HeaderBucketLRUEnabled, strconv.FormatBool(lruconf.LRUEnabled))
+	w.Header().Add(HeaderBucketLRULowWM, strconv.FormatUint(uint64(lruconf.LowWM), 10))
+	w.Header().Add(HeaderBucketLRUHighWM, strconv.FormatUint(uint64(lruconf.HighWM), 10))
+	if nexttier := t.nexttier(bucket); nexttier != "" {
+		w.Header().Add(HeaderBucketNextTierURL, nexttier)
+	}
+	if t.pinned(bucket) {
+		w.Header().Add(HeaderBucketPinned, "true")
+	}
+}
+
+// nexttierget GETs the object that is not present locally from the next tier
+func (t *targetrunner) nexttierget(bucket, objname, fqn, nexttier string) (errstr string, errcode int) {
+	uname := t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, true)
+	if _, err := os.Stat(fqn); err == nil || !os.IsNotExist(err) {
+		return // present or else isObjectCached will tell
+	}
+	started := time.Now()
+	url := nexttier + "/" + Rversion + "/" + Robjects + "/" + bucket + "/" + objname
+	response, err := t.httpclient.Get(url)
+	if err != nil {
+		return fmt.Sprintf("Failed to GET %s/%s from the next tier %s, err: %v", bucket, objname, nexttier, err),
+			http.StatusBadGateway
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		ReadToNull(response.Body)
+		if response.StatusCode == http.StatusNotFound {
+			return fmt.Sprintf("GET local: file %s (object %s/%s) does not exist", fqn, bucket, objname),
+				http.StatusNotFound
+		}
+		return fmt.Sprintf("Failed to GET %s/%s from the next tier %s, status %d",
+			bucket, objname, nexttier, response.StatusCode), response.StatusCode
+	}
+	var (
+		workfqn = t.fqn2workfile(fqn)
+		hdhobj  = newcksumvalue(response.Header.Get(HeaderDfcChecksumType), response.Header.Get(HeaderDfcChecksumVal))
+		props   = &objectProps{version: response.Header.Get(HeaderDfcObjVersion)}
+	)
+	props.meta, _ = objmetaFromHeader(response.Header)
+	if _, props.nhobj, props.size, errstr = t.receive(workfqn, false, bucket, objname, "", hdhobj, response.Body); errstr != "" {
+		return
+	}
+	if err = CreateDir(filepath.Dir(fqn)); err == nil {
+		err = os.Rename(workfqn, fqn)
+	}
+	if err != nil {
+		removeworkfiles([]string{workfqn})
+		return fmt.Sprintf("Failed to rename %s => %s, err: %v", workfqn, fqn, err), 0
+	}
+	if errstr = t.finalizeobj(fqn, props); errstr != "" {
+		return
+	}
+	t.statsif.addMany("numcoldget", int64(1), "bytesloaded", props.size)
+	if glog.V(3) {
+		glog.Infof("GET %s/%s from the next tier %s, %.2f MB, %d µs",
+			bucket, objname, nexttier, float64(props.size)/MiB, time.Since(started)/1000)
+	}
+	// same as PUT
+	if _, enabled := t.ecconf(bucket); enabled {
+		go t.ecencode(bucket, objname)
+	} else if copies, _ := t.copies(bucket); copies > 1 {
+		go t.replicate(bucket, objname, false)
+	}
+	return
+}
@@ -0,0 +1,165 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// GET {"what": "summary"} /v1/buckets/bucket-name: each target walks its
+// mountpaths' bucket directories and counts objects and bytes, the proxy
+// aggregates the targets' summaries and - for a Cloud bucket - lists the
+// bucket in the Cloud to compute the cached fraction
+//
+//======
+
+// bucketsummary: the proxy
+func (p *proxyrunner) bucketsummary(w http.ResponseWriter, r *http.Request, bucket string, listmsgjson []byte) {
+	type targetReply struct {
+		id      string
+		summary *TargetBucketSummary
+		err     error
+	}
+	var (
+		islocal = p.islocalBucket(bucket)
+		summary = &BucketSummary{Bucket: bucket, Local: islocal, Targets: make(map[string]*TargetBucketSummary)}
+		ch      = make(chan *targetReply, len(p.smap.Smap))
+		wg      = &sync.WaitGroup{}
+	)
+	for _, si := range p.smap.Smap {
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			url := fmt.Sprintf("%s/%s/%s/%s?%s=%t", si.DirectURL, Rversion, Rbuckets, bucket, URLParamLocal, islocal)
+			// walking the bucket may take a while - no timeout
+			outjson, err, _, status := p.call(si, url, http.MethodGet, listmsgjson, 0)
+			if err != nil {
+				p.kalive.onerr(err, status)
+				ch <- &targetReply{id: si.DaemonID, err: err}
+				return
+			}
+			tsummary := &TargetBucketSummary{}
+			err = json.Unmarshal(outjson, tsummary)
+			ch <- &targetReply{id: si.DaemonID, summary: tsummary, err: err}
+		}(si)
+	}
+	wg.Wait()
+	close(ch)
+
+	for reply := range ch {
+		if reply.err != nil {
+			errstr := fmt.Sprintf("Failed to get bucket %s summary from target %s, err: %v", bucket, reply.id, reply.err)
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
+		summary.Targets[reply.id] = reply.summary
+		summary.Objects += reply.summary.Objects
+		summary.Bytes += reply.summary.Bytes
+	}
+	if !islocal {
+		if errstr := p.cloudsummary(bucket, summary); errstr != "" {
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
+	}
+	jsbytes, err := json.Marshal(summary)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "bucketsummary")
+}
+
+// cloudsummary lists the Cloud bucket page by page to count its objects and bytes
+func (p *proxyrunner) cloudsummary(bucket string, summary *BucketSummary) (errstr string) {
+	msg := &GetMsg{GetProps: GetPropsSize}
+	for {
+		listmsgjson, err := json.Marshal(msg)
+		assert(err == nil, err)
+		reslist, err := p.getCloudBucketObjects(bucket, listmsgjson)
+		if err != nil {
+			return fmt.Sprintf("Failed to list Cloud bucket %s, err: %v", bucket, err)
+		}
+		for _, entry := range reslist.Entries {
+			summary.CloudObjects++
+			summary.CloudBytes += entry.Size
+		}
+		if reslist.PageMarker == "" {
+			break
+		}
+		msg.GetPageMarker = reslist.PageMarker
+	}
+	if summary.CloudBytes > 0 {
+		summary.CachedFraction = float64(summary.Bytes) / float64(summary.CloudBytes)
+	}
+	return
+}
+
+// bucketsummary: the target counts the objects and bytes on each of its mountpaths
+func (t *targetrunner) bucketsummary(w http.ResponseWriter, r *http.Request, bucket string, islocal bool) {
+	type mpathReply struct {
+		mpath string
+		usage *BucketUsage
+		err   error
+	}
+	var (
+		summary = &TargetBucketSummary{Mountpaths: make(map[string]*BucketUsage)}
+		ch      = make(chan *mpathReply, len(ctx.mountpaths.Available))
+		wg      = &sync.WaitGroup{}
+	)
+	for mpath := range ctx.mountpaths.Available {
+		dir := filepath.Join(makePathCloud(mpath), bucket)
+		if islocal {
+			dir = filepath.Join(makePathLocal(mpath), bucket)
+		}
+		wg.Add(1)
+		go func(mpath, dir string) {
+			defer wg.Done()
+			usage := &BucketUsage{}
+			err := filepath.Walk(dir, func(fqn string, osfi os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if osfi.IsDir() {
+					return nil
+				}
+				if iswork, _ := t.isworkfile(fqn); iswork {
+					return nil
+				}
+				usage.Objects++
+				usage.Bytes += osfi.Size()
+				return nil
+			})
+			ch <- &mpathReply{mpath: mpath, usage: usage, err: err}
+		}(mpath, dir)
+	}
+	wg.Wait()
+	close(ch)
+
+	for reply := range ch {
+		if reply.err != nil {
+			glog.Errorf("Failed to traverse %s bucket %s, err: %v", reply.mpath, bucket, reply.err)
+			t.runFSKeeper(reply.err)
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to get bucket %s summary, err: %v", bucket, reply.err))
+			return
+		}
+		summary.Mountpaths[reply.mpath] = reply.usage
+		summary.Objects += reply.usage.Objects
+		summary.Bytes += reply.usage.Bytes
+	}
+	jsbytes, err := json.Marshal(summary)
+	assert(err == nil, err)
+	t.writeJSON(w, r, jsbytes, "bucketsummary")
+}
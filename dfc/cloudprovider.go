@@ -0,0 +1,67 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cloudctor constructs the cloudif implementation for a given storage target
+type cloudctor func(t *targetrunner) cloudif
+
+//======
+//
+// registry of cloud providers: each backend registers its constructor
+// under the provider name (the value of "cloudprovider" in the config)
+//
+//======
+var cloudproviders = struct {
+	sync.Mutex
+	ctors map[string]cloudctor
+}{ctors: make(map[string]cloudctor, 4)}
+
+// registerCloudProvider is expected to be called from the backend's init()
+func registerCloudProvider(name string, ctor cloudctor) {
+	cloudproviders.Lock()
+	defer cloudproviders.Unlock()
+	assert(name != "" && name != ProviderDfc, "invalid cloud provider name: "+name)
+	_, ok := cloudproviders.ctors[name]
+	assert(!ok, "duplicate cloud provider: "+name)
+	cloudproviders.ctors[name] = ctor
+}
+
+func newCloudProvider(name string, t *targetrunner) (cloudif, string) {
+	cloudproviders.Lock()
+	ctor, ok := cloudproviders.ctors[name]
+	cloudproviders.Unlock()
+	if !ok {
+		return nil, fmt.Sprintf("Unknown cloud provider %q, expecting one of: %s",
+			name, strings.Join(CloudProviders(), ", "))
+	}
+	return ctor(t), ""
+}
+
+// CloudProviders returns the sorted names of all registered cloud providers
+func CloudProviders() []string {
+	cloudproviders.Lock()
+	defer cloudproviders.Unlock()
+	names := make([]string, 0, len(cloudproviders.ctors))
+	for name := range cloudproviders.ctors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isCloudProvider(name string) bool {
+	cloudproviders.Lock()
+	_, ok := cloudproviders.ctors[name]
+	cloudproviders.Unlock()
+	return ok
+}
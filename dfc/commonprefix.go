@@ -0,0 +1,43 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"strings"
+)
+
+//======
+//
+// hierarchical listing: with GetMsg.GetDelimiter set, the objects which names
+// contain the delimiter past the prefix are rolled up into CommonPrefixes -
+// the names up to and including the first such delimiter. Each common prefix
+// takes one entry of the page, and the page marker may point to it
+//
+//======
+
+// commonprefix returns the common prefix the object rolls up into or "" if
+// the object is listed at the prefix's level
+func commonprefix(objname, prefix, delim string) string {
+	if delim == "" || !strings.HasPrefix(objname, prefix) {
+		return ""
+	}
+	if i := strings.Index(objname[len(prefix):], delim); i >= 0 {
+		return objname[:len(prefix)+i+len(delim)]
+	}
+	return ""
+}
+
+// prevpage returns true if the object has been listed by one of the previous
+// pages: either directly or - if the marker is a common prefix - as part of it
+func prevpage(objname, marker, delim string) bool {
+	if marker == "" {
+		return false
+	}
+	if objname <= marker {
+		return true
+	}
+	return delim != "" && strings.HasSuffix(marker, delim) && strings.HasPrefix(objname, marker)
+}
@@ -0,0 +1,82 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditional requests (RFC 7232)
+const (
+	headerETag            = "ETag"
+	headerLastModified    = "Last-Modified"
+	headerIfMatch         = "If-Match"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+	etagAny               = "*"
+)
+
+// objetag returns the strong entity tag of the object: its checksum if available or
+// else its version (that, for a Cloud object, is the one assigned by the Cloud provider)
+func objetag(nhobj cksumvalue, version string) string {
+	if nhobj != nil {
+		if _, hval := nhobj.get(); hval != "" {
+			return `"` + hval + `"`
+		}
+	}
+	if version != "" {
+		return `"` + version + `"`
+	}
+	return ""
+}
+
+// etagmatch returns true if the If-Match or If-None-Match header value -
+// "*" or a comma-separated list of entity tags - matches the etag
+func etagmatch(hdr, etag string) bool {
+	if strings.TrimSpace(hdr) == etagAny {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(hdr, ",") {
+		// weak comparison, RFC 7232, 2.3.2
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkpreconds evaluates the GET preconditions in the order prescribed by
+// RFC 7232, section 6, and returns 304 or 412 if the object is not to be sent
+func checkpreconds(r *http.Request, etag string, mtime time.Time) (status int) {
+	if hdr := r.Header.Get(headerIfMatch); hdr != "" && !etagmatch(hdr, etag) {
+		return http.StatusPreconditionFailed
+	}
+	if hdr := r.Header.Get(headerIfNoneMatch); hdr != "" {
+		if etagmatch(hdr, etag) {
+			return http.StatusNotModified
+		}
+		return
+	}
+	if hdr := r.Header.Get(headerIfModifiedSince); hdr != "" {
+		// NOTE: ignoring invalid dates, as per RFC
+		if since, err := http.ParseTime(hdr); err == nil && !mtime.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return
+}
+
+// isconditional returns true if the GET carries preconditions
+func isconditional(r *http.Request) bool {
+	return r.Header.Get(headerIfMatch) != "" || r.Header.Get(headerIfNoneMatch) != "" ||
+		r.Header.Get(headerIfModifiedSince) != ""
+}
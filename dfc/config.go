@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -27,6 +28,7 @@ const (
 const (
 	xattrXXHashVal  = "user.obj.dfchash"
 	xattrObjVersion = "user.obj.version"
+	xattrObjMeta    = "user.obj.meta" // user-defined metadata (JSON)
 
 	ChecksumNone   = "none"
 	ChecksumXXHash = "xxhash"
@@ -57,6 +59,7 @@ type dfconfig struct {
 	Confdir       string `json:"confdir"`
 	CloudProvider string `json:"cloudprovider"`
 	CloudBuckets  string `json:"cloud_buckets"`
+	CloudFSRoot   string `json:"cloud_fs_root"` // the root directory of ProviderFS buckets
 	LocalBuckets  string `json:"local_buckets"`
 	// structs
 	Log          logconfig         `json:"log"`
@@ -66,12 +69,14 @@ type dfconfig struct {
 	LRU          lruconfig         `json:"lru_config"`
 	Rebalance    rebalanceconf     `json:"rebalance_conf"`
 	Cksum        cksumconfig       `json:"cksum_config"`
+	ColdGet      coldgetconf       `json:"cold_get"`
 	Ver          versionconfig     `json:"version_config"`
 	FSpaths      map[string]string `json:"fspaths"`
 	TestFSP      testfspathconf    `json:"test_fspaths"`
 	Net          netconfig         `json:"netconfig"`
 	FSKeeper     fskeeperconf      `json:"fskeeper"`
 	Experimental experimental      `json:"experimental"`
+	MockCloud    mockcloudconf     `json:"mock_cloud"`
 	H2c          bool              `json:"h2c"`
 }
 
@@ -116,14 +121,16 @@ type proxycnf struct {
 }
 
 type lruconfig struct {
-	LowWM              uint32        `json:"lowwm"`             // capacity usage low watermark
-	HighWM             uint32        `json:"highwm"`            // capacity usage high watermark
-	AtimeCacheMax      uint64        `json:"atime_cache_max"`   // atime cache - max num entries
-	DontEvictTimeStr   string        `json:"dont_evict_time"`   // eviction is not permitted during [atime, atime + dont]
-	CapacityUpdTimeStr string        `json:"capacity_upd_time"` // min time to update capacity
-	DontEvictTime      time.Duration `json:"-"`                 // omitempty
-	CapacityUpdTime    time.Duration `json:"-"`                 // ditto
-	LRUEnabled         bool          `json:"lru_enabled"`       // LRU will only run when LRUEnabled is true
+	LowWM                  uint32        `json:"lowwm"`                 // capacity usage low watermark
+	HighWM                 uint32        `json:"highwm"`                // capacity usage high watermark
+	AtimeCacheMax          uint64        `json:"atime_cache_max"`       // atime cache - max num entries
+	DontEvictTimeStr       string        `json:"dont_evict_time"`       // eviction is not permitted during [atime, atime + dont]
+	CapacityUpdTimeStr     string        `json:"capacity_upd_time"`     // min time to update capacity
+	MultipartExpireTimeStr string        `json:"multipart_expire_time"` // parts of idle multipart uploads are evicted after
+	DontEvictTime          time.Duration `json:"-"`                     // omitempty
+	CapacityUpdTime        time.Duration `json:"-"`                     // ditto
+	MultipartExpireTime    time.Duration `json:"-"`                     // ditto
+	LRUEnabled             bool          `json:"lru_enabled"`           // LRU will only run when LRUEnabled is true
 }
 
 type rebalanceconf struct {
@@ -161,6 +168,14 @@ type cksumconfig struct {
 	ValidateColdGet bool   `json:"validate_cold_get"` // MD5 (ETag) validation upon cold GET
 }
 
+// coldgetconf configures ranged cold GET - see rangedget.go
+type coldgetconf struct {
+	PartSize             int64         `json:"part_size"`           // objects larger than that are downloaded in parts of that size; 0 - disabled
+	Concurrency          int           `json:"concurrency"`         // max number of parts of the object downloaded concurrently
+	PartialExpireTimeStr string        `json:"partial_expire_time"` // interrupted downloads are evicted after - see resume.go
+	PartialExpireTime    time.Duration `json:"-"`                   // omitempty
+}
+
 type versionconfig struct {
 	ValidateWarmGet bool   `json:"validate_warm_get"` // True: validate object version upon warm GET
 	Versioning      string `json:"versioning"`        // types of objects versioning is enabled for: all, cloud, local, none
@@ -175,8 +190,20 @@ type fskeeperconf struct {
 }
 
 type experimental struct {
-	AckPut   string `json:"ack_put"`
-	MaxMemMB int    `json:"max_mem_mb"` // max memory size for the "memory" option - FIXME: niy
+	AckPut     string `json:"ack_put"`
+	MaxMemMB   int    `json:"max_mem_mb"`   // max memory size for the "memory" option - FIXME: niy
+	TeeColdGet bool   `json:"tee_cold_get"` // stream cold GETs while downloading - see teeget.go
+}
+
+// mockcloudconf configures the in-memory Cloud (ProviderMock) used for testing
+type mockcloudconf struct {
+	Buckets    []string      `json:"buckets"`     // names of the mock Cloud buckets
+	Versioning bool          `json:"versioning"`  // whether mock Cloud objects are versioned
+	PageSize   int           `json:"page_size"`   // max number of objects in a page returned by listbucket
+	LatencyStr string        `json:"latency"`     // latency injected into every mock Cloud request
+	Latency    time.Duration `json:"-"`           // omitempty
+	ErrorPct   int           `json:"error_pct"`   // percentage of mock Cloud requests that fail
+	AbortAfter int64         `json:"abort_after"` // GET fails after reading that many bytes of the object; 0 - never
 }
 
 //==============================
@@ -280,10 +307,35 @@ func validateconf() (err error) {
 	if ctx.config.LRU.CapacityUpdTime, err = time.ParseDuration(ctx.config.LRU.CapacityUpdTimeStr); err != nil {
 		return fmt.Errorf("Bad capacity_upd_time format %s, err: %v", ctx.config.LRU.CapacityUpdTimeStr, err)
 	}
+	if ctx.config.LRU.MultipartExpireTime, err = time.ParseDuration(ctx.config.LRU.MultipartExpireTimeStr); err != nil {
+		return fmt.Errorf("Bad multipart_expire_time format %s, err: %v", ctx.config.LRU.MultipartExpireTimeStr, err)
+	}
 	if ctx.config.Rebalance.StartupDelayTime, err = time.ParseDuration(ctx.config.Rebalance.StartupDelayTimeStr); err != nil {
 		return fmt.Errorf("Bad startup_delay_time format %s, err: %v", ctx.config.Rebalance.StartupDelayTimeStr, err)
 	}
 
+	if !isCloudProvider(ctx.config.CloudProvider) {
+		return fmt.Errorf("Invalid cloud provider %q, expecting one of: %s",
+			ctx.config.CloudProvider, strings.Join(CloudProviders(), ", "))
+	}
+	if ctx.config.CloudProvider == ProviderMock {
+		mc := &ctx.config.MockCloud
+		if mc.Latency, err = time.ParseDuration(mc.LatencyStr); err != nil {
+			return fmt.Errorf("Bad mock_cloud latency format %s, err: %v", mc.LatencyStr, err)
+		}
+		if mc.ErrorPct < 0 || mc.ErrorPct > 100 || mc.PageSize <= 0 || mc.AbortAfter < 0 {
+			return fmt.Errorf("Invalid mock_cloud configuration %+v", *mc)
+		}
+	}
+	if ctx.config.CloudProvider == ProviderFS {
+		if !filepath.IsAbs(ctx.config.CloudFSRoot) {
+			return fmt.Errorf("Invalid cloud_fs_root %q: must be an absolute path", ctx.config.CloudFSRoot)
+		}
+		if finfo, err := os.Stat(ctx.config.CloudFSRoot); err != nil || !finfo.IsDir() {
+			return fmt.Errorf("Invalid cloud_fs_root %q: not a directory (err: %v)", ctx.config.CloudFSRoot, err)
+		}
+	}
+
 	hwm, lwm := ctx.config.LRU.HighWM, ctx.config.LRU.LowWM
 	if hwm <= 0 || lwm <= 0 || hwm < lwm || lwm > 100 || hwm > 100 {
 		return fmt.Errorf("Invalid LRU configuration %+v", ctx.config.LRU)
@@ -300,6 +352,12 @@ func validateconf() (err error) {
 	if ctx.config.Cksum.Checksum != ChecksumXXHash && ctx.config.Cksum.Checksum != ChecksumNone {
 		return fmt.Errorf("Invalid checksum: %s - expecting %s or %s", ctx.config.Cksum.Checksum, ChecksumXXHash, ChecksumNone)
 	}
+	if ctx.config.ColdGet.PartialExpireTime, err = time.ParseDuration(ctx.config.ColdGet.PartialExpireTimeStr); err != nil {
+		return fmt.Errorf("Bad partial_expire_time format %s, err: %v", ctx.config.ColdGet.PartialExpireTimeStr, err)
+	}
+	if ctx.config.ColdGet.PartSize < 0 || ctx.config.ColdGet.Concurrency < 0 {
+		return fmt.Errorf("Invalid cold_get configuration %+v", ctx.config.ColdGet)
+	}
 	if err := validateVersion(ctx.config.Ver.Versioning); err != nil {
 		return err
 	}
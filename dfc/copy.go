@@ -0,0 +1,248 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+type xactCopy struct {
+	xactBase
+	targetrunner *targetrunner
+}
+
+//======
+//
+// server-side copy: the target that stores the source object (cold-GETting it
+// first if need be) sends it directly to the destination's HRW target - same as
+// rebalance - where the object is PUT into the destination bucket;
+// move is copy followed by the removal of the source
+//
+//======
+
+// POST { action: copy|move, value: CopyMsg } /Rversion/Robjects/bucket-name/object-name
+func (t *targetrunner) copyfile(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	var (
+		copymsg CopyMsg
+		errstr  string
+		errcode int
+	)
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	jsbytes, err := json.Marshal(msg.Value)
+	assert(err == nil, err)
+	if err = json.Unmarshal(jsbytes, &copymsg); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to parse CopyMsg, err: %v", err))
+		return
+	}
+	if copymsg.DestObjname == "" {
+		copymsg.DestObjname = objname
+	}
+	if msg.Action == ActMove {
+		errstr, errcode = t.moveobj(bucket, objname, copymsg.DestBucket, copymsg.DestObjname)
+	} else {
+		errstr, errcode = t.copyobj(bucket, objname, copymsg.DestBucket, copymsg.DestObjname)
+	}
+	if errstr != "" {
+		if errcode == 0 {
+			t.invalmsghdlr(w, r, errstr)
+		} else {
+			t.invalmsghdlr(w, r, errstr, errcode)
+		}
+	}
+}
+
+// POST { action: copy|move, value: ListMsg|RangeMsg } /Rversion/Rbuckets/bucket-name
+func (t *targetrunner) copyfiles(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	jsmap, ok := msg.Value.(map[string]interface{})
+	if !ok {
+		t.invalmsghdlr(w, r, "Could not parse List/Range Message: ActionMsg.Value was not map[string]interface{}")
+		return
+	}
+	move := msg.Action == ActMove
+	if _, ok := jsmap["objnames"]; ok {
+		// Copy with List
+		copyMsg, err := parseListMsg(jsmap)
+		if err == nil && copyMsg.DestBucket == "" {
+			err = fmt.Errorf("No destination bucket")
+		}
+		if err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse CopyMsg: %v", err))
+			return
+		}
+		t.listOperation(w, r, copyMsg, func(objs []string, bucket string, deadline time.Duration, done chan struct{}) error {
+			return t.doListCopy(objs, bucket, copyMsg.DestBucket, move, deadline, done)
+		})
+	} else {
+		// Copy with Range
+		copyMsg, err := parseRangeMsg(jsmap)
+		if err == nil && copyMsg.DestBucket == "" {
+			err = fmt.Errorf("No destination bucket")
+		}
+		if err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse CopyMsg: %v", err))
+			return
+		}
+		t.rangeOperation(w, r, copyMsg, func(bucket, prefix, regex string, min, max int64,
+			deadline time.Duration, done chan struct{}) error {
+			objs, err := t.getListFromRange(bucket, prefix, regex, min, max)
+			if err != nil {
+				return err
+			}
+			return t.doListCopy(objs, bucket, copyMsg.DestBucket, move, deadline, done)
+		})
+	}
+}
+
+func (t *targetrunner) doListCopy(objs []string, bucket, dstbucket string, move bool,
+	deadline time.Duration, done chan struct{}) error {
+	copyobj := t.copyobj
+	if move {
+		copyobj = t.moveobj
+	}
+	xcopy := t.xactinp.newCopy(t)
+	defer func() {
+		if done != nil {
+			var v struct{}
+			done <- v
+		}
+		t.xactinp.del(xcopy.id)
+	}()
+
+	var absdeadline time.Time
+	if deadline != 0 {
+		absdeadline = time.Now().Add(deadline)
+	}
+	for _, objname := range objs {
+		select {
+		case <-xcopy.abrt:
+			return nil
+		default:
+		}
+		if !absdeadline.IsZero() && time.Now().After(absdeadline) {
+			continue
+		}
+		if errstr, _ := copyobj(bucket, objname, dstbucket, objname); errstr != "" {
+			return fmt.Errorf("%s", errstr)
+		}
+	}
+	return nil
+}
+
+// copyobj copies bucket/objname to dstbucket/dstobjname
+func (t *targetrunner) copyobj(bucket, objname, dstbucket, dstobjname string) (errstr string, errcode int) {
+	if dstbucket == "" || strings.Contains(dstbucket, "/") {
+		return fmt.Sprintf("Copy %s/%s: invalid destination bucket %q", bucket, objname, dstbucket), 0
+	}
+	if bucket == dstbucket && objname == dstobjname {
+		return fmt.Sprintf("Copy %s/%s: the source and the destination are the same", bucket, objname), 0
+	}
+	fqn, uname := t.fqn(bucket, objname), t.uname(bucket, objname)
+	coldget, _, _, errstr := t.isObjectCached(bucket, objname, fqn)
+	if errstr != "" {
+		if t.islocalBucket(bucket) {
+			errcode = http.StatusNotFound
+		}
+		return
+	}
+	if coldget {
+		if _, errstr, errcode = t.coldget(bucket, objname, false, nil); errstr != "" {
+			return
+		}
+	}
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		return fmt.Sprintf("Copy: failed to fstat %s (object %s/%s), err: %v", fqn, bucket, objname, err), 0
+	}
+	si, errstr := hrwTarget(dstbucket+"/"+dstobjname, t.smap)
+	if errstr != "" {
+		return
+	}
+	if si.DaemonID == t.si.DaemonID {
+		errstr, errcode = t.copylocal(bucket, fqn, dstbucket, dstobjname)
+	} else {
+		url := si.DirectURL + "/" + Rversion + "/" + Robjects + "/" + dstbucket + "/" + dstobjname
+		errstr = t.sendfqn(http.MethodPut, bucket, fqn, url, si, finfo.Size())
+	}
+	if errstr != "" {
+		return
+	}
+	t.statsif.add("numcopy", 1)
+	if glog.V(3) {
+		glog.Infof("Copied %s/%s => %s/%s (%s), %.2f MB",
+			bucket, objname, dstbucket, dstobjname, si.DaemonID, float64(finfo.Size())/MiB)
+	}
+	return
+}
+
+// moveobj copies the object and then deletes the source - from the Cloud as well
+// if the source bucket is a Cloud one
+func (t *targetrunner) moveobj(bucket, objname, dstbucket, dstobjname string) (errstr string, errcode int) {
+	if errstr, errcode = t.copyobj(bucket, objname, dstbucket, dstobjname); errstr != "" {
+		return
+	}
+	if err := t.fildelete(bucket, objname, false /*evict*/); err != nil {
+		errstr = fmt.Sprintf("Copied %s/%s => %s/%s but failed to remove the source, err: %v",
+			bucket, objname, dstbucket, dstobjname, err)
+	}
+	return
+}
+
+// copylocal PUTs the local file fqn into the destination bucket when the
+// destination's HRW target is self; the caller must hold the source's lock
+func (t *targetrunner) copylocal(bucket, fqn, dstbucket, dstobjname string) (errstr string, errcode int) {
+	var hdhobj cksumvalue
+	file, err := os.Open(fqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to open %q, err: %v", fqn, err), 0
+	}
+	defer file.Close()
+	if t.cksumconf(bucket).Checksum != ChecksumNone {
+		if hashbinary, errs := Getxattr(fqn, xattrXXHashVal); errs == "" && hashbinary != nil {
+			hdhobj = newcksumvalue(ChecksumXXHash, string(hashbinary))
+		}
+	}
+	var (
+		dstfqn = t.fqn(dstbucket, dstobjname)
+		putfqn = t.fqn2workfile(dstfqn)
+		props  = &objectProps{meta: t.objmeta(fqn)}
+	)
+	if _, props.nhobj, props.size, errstr = t.receive(putfqn, false, dstbucket, dstobjname, "", hdhobj, file); errstr != "" {
+		return
+	}
+	return t.putCommit(dstbucket, dstobjname, putfqn, dstfqn, props, false /*rebalance*/)
+}
+
+func (q *xactInProgress) newCopy(t *targetrunner) *xactCopy {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	id := q.uniqueid()
+	xcopy := &xactCopy{xactBase: *newxactBase(id, ActCopy), targetrunner: t}
+	q.add(xcopy)
+	return xcopy
+}
+
+func (xact *xactCopy) tostring() string {
+	start := xact.stime.Sub(xact.targetrunner.starttime())
+	if !xact.finished() {
+		return fmt.Sprintf("xaction %s:%d started %v", xact.kind, xact.id, start)
+	}
+	fin := time.Since(xact.targetrunner.starttime())
+	return fmt.Sprintf("xaction %s:%d started %v finished %v", xact.kind, xact.id, start, fin)
+}
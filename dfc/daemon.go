@@ -6,8 +6,10 @@
 package dfc
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"sync"
 	"time"
@@ -80,11 +82,14 @@ type proxyInfo struct {
 	Primary bool
 }
 
-// local (cache-only) bucket names and their TBD props
+// local (cache-only) bucket names and their props;
+// cloud buckets that have props of their own (see setcloud)
 type lbmap struct {
 	sync.Mutex
-	LBmap       map[string]string `json:"l_bmap"`
-	Version     int64             `json:"version"`
+	LBmap       map[string]BucketProps `json:"l_bmap"`
+	CBmap       map[string]BucketProps `json:"c_bmap"`
+	Renamed     map[string]string      `json:"renamed,omitempty"` // old => new name of the renamed local buckets
+	Version     int64                  `json:"version"`
 	syncversion int64
 }
 
@@ -197,12 +202,78 @@ func (m *Smap) unlock() {
 // lbmap wrapper - NOTE - caller must take the lock
 //
 //====================
-func (m *lbmap) add(b string) bool {
+func (m *lbmap) add(b string, props BucketProps) bool {
 	_, ok := m.LBmap[b]
 	if ok {
 		return false
 	}
-	m.LBmap[b] = ""
+	m.LBmap[b] = props
+	delete(m.Renamed, b)
+	m.Version++
+	return true
+}
+
+// rename replaces the local bucket b with newb and records the rename - the targets
+// that did not get to rename the bucket themselves do it when installing the lbmap
+func (m *lbmap) rename(b, newb string) bool {
+	props, ok := m.LBmap[b]
+	if !ok {
+		return false
+	}
+	if _, ok = m.LBmap[newb]; ok {
+		return false
+	}
+	if m.Renamed == nil {
+		m.Renamed = make(map[string]string)
+	}
+	delete(m.LBmap, b)
+	delete(m.Renamed, newb)
+	m.LBmap[newb] = props
+	m.Renamed[b] = newb
+	m.Version++
+	return true
+}
+
+// renamedto returns the current name of the local bucket that has been renamed (possibly
+// more than once) or "" if the bucket has not been renamed or does not exist anymore
+func (m *lbmap) renamedto(b string) string {
+	for i := 0; i < len(m.Renamed); i++ {
+		newb, ok := m.Renamed[b]
+		if !ok {
+			return ""
+		}
+		if _, ok = m.LBmap[newb]; ok {
+			return newb
+		}
+		b = newb
+	}
+	return ""
+}
+
+func (m *lbmap) set(b string, props BucketProps) bool {
+	_, ok := m.LBmap[b]
+	if !ok {
+		return false
+	}
+	m.LBmap[b] = props
+	m.Version++
+	return true
+}
+
+// setcloud adds or updates the cloud bucket's entry; a cloud bucket
+// without props of its own is removed from the map
+func (m *lbmap) setcloud(b string, props BucketProps) bool {
+	if props == (BucketProps{}) {
+		if _, ok := m.CBmap[b]; !ok {
+			return false
+		}
+		delete(m.CBmap, b)
+	} else {
+		if m.CBmap == nil {
+			m.CBmap = make(map[string]BucketProps)
+		}
+		m.CBmap[b] = props
+	}
 	m.Version++
 	return true
 }
@@ -217,6 +288,57 @@ func (m *lbmap) del(b string) bool {
 	return true
 }
 
+// UnmarshalJSON accepts the empty string that was stored as the (placeholder)
+// value in the local bucket maps of the previous versions
+func (props *BucketProps) UnmarshalJSON(b []byte) error {
+	if string(b) == `""` {
+		*props = BucketProps{}
+		return nil
+	}
+	type bucketprops BucketProps // no methods - no recursion
+	return json.Unmarshal(b, (*bucketprops)(props))
+}
+
+// validate is called by the primary proxy upon local bucket creation
+func (props *BucketProps) validate(ntargets int) (errstr string) {
+	if errstr = props.EC.validate(ntargets); errstr != "" {
+		return
+	}
+	if props.Copies < 0 || props.Copies > ntargets {
+		return fmt.Sprintf("Invalid number of copies %d (the cluster has %d targets)", props.Copies, ntargets)
+	}
+	if props.Copies > 1 && props.EC.DataSlices > 0 {
+		return "Replication and erasure coding cannot be both enabled for the same bucket"
+	}
+	return props.validateOverrides()
+}
+
+// validateOverrides validates the per-bucket overrides the same way validateconf
+// validates the cluster-wide configuration
+func (props *BucketProps) validateOverrides() (errstr string) {
+	if conf := props.CksumConf; conf != nil && conf.Checksum != ChecksumXXHash && conf.Checksum != ChecksumNone {
+		return fmt.Sprintf("Invalid checksum: %s - expecting %s or %s", conf.Checksum, ChecksumXXHash, ChecksumNone)
+	}
+	if conf := props.VersionConf; conf != nil {
+		if err := validateVersion(conf.Versioning); err != nil {
+			return err.Error()
+		}
+	}
+	if conf := props.LRUConf; conf != nil {
+		hwm, lwm := conf.HighWM, conf.LowWM
+		if hwm <= 0 || lwm <= 0 || hwm < lwm || lwm > 100 || hwm > 100 {
+			return fmt.Sprintf("Invalid LRU configuration %+v", *conf)
+		}
+	}
+	if props.NextTierURL != "" {
+		u, err := url.Parse(props.NextTierURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("Invalid next tier URL %q", props.NextTierURL)
+		}
+	}
+	return
+}
+
 func (m *lbmap) version() int64 {
 	return m.Version
 }
@@ -0,0 +1,800 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/klauspost/reedsolomon"
+)
+
+// Erasure coding of local buckets (BucketProps.EC):
+//   1. PUT: once the object is committed, the owning target (hrwTarget) splits it
+//      into k data slices, computes m parity slices and sends the k+m slices to
+//      the next k+m targets in the HRW order, one slice per target
+//   2. GET: when the owning target does not have the object (e.g., the target that
+//      used to own it is gone, or its disk was lost) the object gets reconstructed
+//      from any k slices collected from the other targets - and then re-encoded
+//      to re-place the slices that are missing
+//   3. DELETE: the object's slices are removed cluster-wide
+//
+// Slices are stored under <mountpath>/ecslices/<bucket>/<object>, each with
+// its ecmeta (JSON) in the xattrECMeta extended attribute; the metadata also
+// travels with the slice in the HeaderDfcECMeta header.
+
+const (
+	ecSlicesDir = "ecslices"
+	xattrECMeta = "user.obj.dfcec"
+	ecMaxSlices = 32 // data + parity
+)
+
+type ecmeta struct {
+	Size    int64             `json:"size"`   // object size
+	Data    int               `json:"data"`   // number of data slices
+	Parity  int               `json:"parity"` // number of parity slices
+	Idx     int               `json:"idx"`    // slice index: [0, Data) - data, [Data, Data+Parity) - parity
+	Stamp   int64             `json:"stamp"`  // encoding time: distinguishes the slices of different object's versions
+	Cksum   string            `json:"cksum,omitempty"`
+	Version string            `json:"version,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"` // user-defined metadata
+}
+
+type ecslice struct {
+	meta ecmeta
+	fqn  string
+	work bool // fqn is a work file to be removed when done
+}
+
+// validate is called by the primary proxy when creating erasure coded local bucket
+func (conf *ECConf) validate(ntargets int) (errstr string) {
+	if conf.DataSlices == 0 && conf.ParitySlices == 0 {
+		return
+	}
+	if conf.DataSlices < 1 || conf.ParitySlices < 1 {
+		return fmt.Sprintf("Invalid erasure coding configuration %d:%d (expecting at least 1 data and 1 parity slice)",
+			conf.DataSlices, conf.ParitySlices)
+	}
+	if conf.DataSlices+conf.ParitySlices > ecMaxSlices {
+		return fmt.Sprintf("Invalid erasure coding configuration %d:%d (the total number of slices cannot exceed %d)",
+			conf.DataSlices, conf.ParitySlices, ecMaxSlices)
+	}
+	if required := conf.DataSlices + conf.ParitySlices + 1; ntargets < required {
+		return fmt.Sprintf("Erasure coding %d:%d requires at least %d targets, have %d",
+			conf.DataSlices, conf.ParitySlices, required, ntargets)
+	}
+	return
+}
+
+func (t *targetrunner) ecconf(bucket string) (conf ECConf, enabled bool) {
+	props, ok := t.lbmap.LBmap[bucket]
+	if !ok {
+		return
+	}
+	return props.EC, props.EC.DataSlices > 0
+}
+
+// builds fqn of directory for erasure coded slices from mountpath
+func makePathEC(basePath string) string {
+	return filepath.Join(basePath, ecSlicesDir)
+}
+
+func (t *targetrunner) slicefqn(bucket, objname string) string {
+	mpath := hrwMpath(bucket + "/" + objname)
+	return filepath.Join(makePathEC(mpath), bucket, objname)
+}
+
+// slices are locked separately from the objects: the target that owns an object
+// may also store one of its slices
+func (t *targetrunner) sliceuname(bucket, objname string) string {
+	return "ec:" + t.uname(bucket, objname)
+}
+
+func (t *targetrunner) ecworkfile(fqn string, idx int) string {
+	return t.fqn2workfile(fqn + ".ec" + strconv.Itoa(idx))
+}
+
+func (t *targetrunner) sliceurl(si *daemonInfo, bucket, objname string) string {
+	return si.DirectURL + "/" + Rversion + "/" + Rslices + "/" + bucket + "/" + objname
+}
+
+func removeworkfiles(fqns []string) {
+	for _, fqn := range fqns {
+		if fqn == "" {
+			continue
+		}
+		if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to remove work file %s, err: %v", fqn, err)
+		}
+	}
+}
+
+//============================================================
+//
+// "/"+Rversion+"/"+Rslices+"/"+bucket+"/"+objname (intra-cluster)
+//
+//============================================================
+func (t *targetrunner) slicehdlr(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t.httpsliceget(w, r)
+	case http.MethodHead:
+		t.httpslicehead(w, r)
+	case http.MethodPut:
+		t.httpsliceput(w, r)
+	case http.MethodDelete:
+		t.httpslicedelete(w, r)
+	default:
+		invalhdlr(w, r)
+	}
+}
+
+func (t *targetrunner) sliceapitems(w http.ResponseWriter, r *http.Request) (bucket, objname string, ok bool) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Rslices); apitems == nil {
+		return
+	}
+	bucket, objname = apitems[0], strings.Join(apitems[1:], "/")
+	if !t.islocalBucket(bucket) {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Erasure coding: %s is not a local bucket", bucket), http.StatusNotFound)
+		return
+	}
+	ok = true
+	return
+}
+
+// GET: slice + HeaderDfcECMeta or 404
+func (t *targetrunner) httpsliceget(w http.ResponseWriter, r *http.Request) {
+	bucket, objname, ok := t.sliceapitems(w, r)
+	if !ok {
+		return
+	}
+	fqn, uname := t.slicefqn(bucket, objname), t.sliceuname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+
+	file, err := os.Open(fqn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No slice %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to open slice %s, err: %v", fqn, err))
+		}
+		return
+	}
+	defer file.Close()
+	metabytes, errstr := Getxattr(fqn, xattrECMeta)
+	if errstr == "" && len(metabytes) == 0 {
+		errstr = fmt.Sprintf("Slice %s has no metadata", fqn)
+	}
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	w.Header().Set(HeaderDfcECMeta, string(metabytes))
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	if _, err = io.CopyBuffer(w, file, buf); err != nil {
+		glog.Errorf("Failed to send slice %s, err: %v", fqn, err)
+	}
+}
+
+// HEAD: 200 if the slice is present, 404 otherwise
+func (t *targetrunner) httpslicehead(w http.ResponseWriter, r *http.Request) {
+	bucket, objname, ok := t.sliceapitems(w, r)
+	if !ok {
+		return
+	}
+	fqn := t.slicefqn(bucket, objname)
+	if _, err := os.Stat(fqn); err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No slice %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to fstat slice %s, err: %v", fqn, err))
+		}
+	}
+}
+
+// PUT: slice with its HeaderDfcECMeta
+func (t *targetrunner) httpsliceput(w http.ResponseWriter, r *http.Request) {
+	var meta ecmeta
+	bucket, objname, ok := t.sliceapitems(w, r)
+	if !ok {
+		return
+	}
+	metastr := r.Header.Get(HeaderDfcECMeta)
+	if err := json.Unmarshal([]byte(metastr), &meta); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Invalid slice %s/%s metadata %q, err: %v", bucket, objname, metastr, err))
+		return
+	}
+	fqn, uname := t.slicefqn(bucket, objname), t.sliceuname(bucket, objname)
+	workfqn := t.fqn2workfile(fqn)
+	if _, _, _, errstr := t.receive(workfqn, false, bucket, objname, "", nil, r.Body); errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	if errstr := Setxattr(workfqn, xattrECMeta, []byte(metastr)); errstr != "" {
+		removeworkfiles([]string{workfqn})
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	err := os.Rename(workfqn, fqn)
+	t.rtnamemap.unlockname(uname, true)
+	if err != nil {
+		removeworkfiles([]string{workfqn})
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to rename %s => %s, err: %v", workfqn, fqn, err))
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("Slice %s/%s #%d of %d:%d", bucket, objname, meta.Idx, meta.Data, meta.Parity)
+	}
+}
+
+// DELETE: 404 if there's no slice
+func (t *targetrunner) httpslicedelete(w http.ResponseWriter, r *http.Request) {
+	bucket, objname, ok := t.sliceapitems(w, r)
+	if !ok {
+		return
+	}
+	fqn, uname := t.slicefqn(bucket, objname), t.sliceuname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	err := os.Remove(fqn)
+	t.rtnamemap.unlockname(uname, true)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No slice %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to remove slice %s, err: %v", fqn, err))
+		}
+	}
+}
+
+//============================================================
+//
+// encode, restore, delete
+//
+//============================================================
+
+// ecencode splits the (committed) object into slices and sends the slices
+// to the next targets in the HRW order
+func (t *targetrunner) ecencode(bucket, objname string) (errstr string) {
+	conf, enabled := t.ecconf(bucket)
+	if !enabled {
+		return
+	}
+	targets, errstr := t.slicetargets(bucket, objname, t.si, conf)
+	if errstr != "" {
+		return
+	}
+	fqn, uname := t.fqn(bucket, objname), t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	workfqns, meta, errstr := t.ecsplit(bucket, objname, fqn, conf)
+	t.rtnamemap.unlockname(uname, false)
+	defer removeworkfiles(workfqns)
+	if errstr != "" || meta == nil {
+		return
+	}
+	return t.sendslices(bucket, objname, targets, workfqns, meta, conf)
+}
+
+// ecmigrate re-encodes the object that is moving to its new owner (see renamelb):
+// the slices are made out of the local copy at fqn - must be called under lock
+func (t *targetrunner) ecmigrate(bucket, objname, fqn string, owner *daemonInfo) (errstr string) {
+	conf, enabled := t.ecconf(bucket)
+	if !enabled {
+		return
+	}
+	targets, errstr := t.slicetargets(bucket, objname, owner, conf)
+	if errstr != "" {
+		return
+	}
+	workfqns, meta, errstr := t.ecsplit(bucket, objname, fqn, conf)
+	defer removeworkfiles(workfqns)
+	if errstr != "" || meta == nil {
+		return
+	}
+	return t.sendslices(bucket, objname, targets, workfqns, meta, conf)
+}
+
+// slicetargets returns the targets that store the object's slices: the next
+// targets in the HRW order after the owner
+func (t *targetrunner) slicetargets(bucket, objname string, owner *daemonInfo, conf ECConf) (targets []*daemonInfo, errstr string) {
+	nslices := conf.DataSlices + conf.ParitySlices
+	sis, errstr := hrwTargetList(bucket+"/"+objname, t.smap)
+	if errstr != "" {
+		return
+	}
+	targets = make([]*daemonInfo, 0, nslices)
+	for _, si := range sis {
+		if si.DaemonID != owner.DaemonID && len(targets) < nslices {
+			targets = append(targets, si)
+		}
+	}
+	if len(targets) < nslices {
+		errstr = fmt.Sprintf("Erasure coding %s/%s: insufficient number of targets %d, required %d",
+			bucket, objname, len(sis), nslices+1)
+	}
+	return
+}
+
+func (t *targetrunner) sendslices(bucket, objname string, targets []*daemonInfo, workfqns []string,
+	meta *ecmeta, conf ECConf) (errstr string) {
+	var (
+		wg      = &sync.WaitGroup{}
+		errch   = make(chan string, len(targets))
+		started = time.Now()
+	)
+	for i, si := range targets {
+		wg.Add(1)
+		go func(i int, si *daemonInfo) {
+			defer wg.Done()
+			slicemeta := *meta
+			slicemeta.Idx = i
+			if errstr := t.sendslice(si, bucket, objname, workfqns[i], &slicemeta); errstr != "" {
+				errch <- errstr
+			}
+		}(i, si)
+	}
+	wg.Wait()
+	close(errch)
+	for errstr = range errch {
+		glog.Errorln(errstr)
+	}
+	if errstr != "" {
+		return fmt.Sprintf("Erasure coding %s/%s: failed to send slice(s), last err: %s", bucket, objname, errstr)
+	}
+	if glog.V(4) {
+		glog.Infof("Erasure coded %s/%s %d:%d, %d µs", bucket, objname, conf.DataSlices, conf.ParitySlices,
+			time.Since(started)/1000)
+	}
+	return
+}
+
+// ecsplit produces data and parity slices (work files) - must be called under lock
+func (t *targetrunner) ecsplit(bucket, objname, fqn string, conf ECConf) (workfqns []string, meta *ecmeta, errstr string) {
+	file, err := os.Open(fqn)
+	if err != nil {
+		if !os.IsNotExist(err) { // otherwise, deleted in the meantime
+			errstr = fmt.Sprintf("Erasure coding: failed to open %s, err: %v", fqn, err)
+		}
+		return
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		errstr = fmt.Sprintf("Erasure coding: failed to fstat %s, err: %v", fqn, err)
+		return
+	}
+	if finfo.Size() == 0 {
+		return // nothing to protect
+	}
+	meta = &ecmeta{Size: finfo.Size(), Data: conf.DataSlices, Parity: conf.ParitySlices, Stamp: time.Now().UnixNano()}
+	if b, errs := Getxattr(fqn, xattrXXHashVal); errs == "" {
+		meta.Cksum = string(b)
+	}
+	if b, errs := Getxattr(fqn, xattrObjVersion); errs == "" {
+		meta.Version = string(b)
+	}
+	// the metadata is stored in the slice's xattr - hence, the size limit
+	if meta.Meta = t.objmeta(fqn); meta.Meta != nil {
+		if jsbytes, err := json.Marshal(meta); err != nil || len(jsbytes) >= maxAttrSize {
+			glog.Errorf("Erasure coding %s/%s: user-defined metadata is too large - not protecting", bucket, objname)
+			meta.Meta = nil
+		}
+	}
+	stream, err := reedsolomon.NewStream(conf.DataSlices, conf.ParitySlices)
+	if err != nil {
+		errstr = fmt.Sprintf("Erasure coding %s/%s: %v", bucket, objname, err)
+		return
+	}
+	nslices := conf.DataSlices + conf.ParitySlices
+	workfqns = make([]string, nslices)
+	files := make([]*os.File, nslices)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i := range workfqns {
+		workfqns[i] = t.ecworkfile(fqn, i)
+		if files[i], err = CreateFile(workfqns[i]); err != nil {
+			errstr = fmt.Sprintf("Erasure coding: failed to create %s, err: %v", workfqns[i], err)
+			return
+		}
+	}
+	data := make([]io.Writer, conf.DataSlices)
+	for i := range data {
+		data[i] = files[i]
+	}
+	if err = stream.Split(file, data, finfo.Size()); err != nil {
+		errstr = fmt.Sprintf("Erasure coding: failed to split %s, err: %v", fqn, err)
+		return
+	}
+	readers := make([]io.Reader, conf.DataSlices)
+	for i := range readers {
+		if _, err = files[i].Seek(0, io.SeekStart); err != nil {
+			errstr = fmt.Sprintf("Erasure coding: failed to seek %s, err: %v", workfqns[i], err)
+			return
+		}
+		readers[i] = files[i]
+	}
+	parity := make([]io.Writer, conf.ParitySlices)
+	for i := range parity {
+		parity[i] = files[conf.DataSlices+i]
+	}
+	if err = stream.Encode(readers, parity); err != nil {
+		errstr = fmt.Sprintf("Erasure coding: failed to encode %s, err: %v", fqn, err)
+	}
+	return
+}
+
+func (t *targetrunner) sendslice(si *daemonInfo, bucket, objname, workfqn string, meta *ecmeta) (errstr string) {
+	metabytes, err := json.Marshal(meta)
+	assert(err == nil, err)
+	file, err := os.Open(workfqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to open slice %s, err: %v", workfqn, err)
+	}
+	defer file.Close()
+	url := t.sliceurl(si, bucket, objname)
+	request, err := http.NewRequest(http.MethodPut, url, file)
+	if err != nil {
+		return fmt.Sprintf("Unexpected failure to create PUT request %s, err: %v", url, err)
+	}
+	request.Header.Set(HeaderDfcECMeta, string(metabytes))
+	response, err := t.httpclient.Do(request)
+	if err != nil {
+		return fmt.Sprintf("Failed to send slice %s/%s #%d to %s, err: %v", bucket, objname, meta.Idx, si.DaemonID, err)
+	}
+	defer response.Body.Close()
+	ReadToNull(response.Body)
+	if response.StatusCode >= http.StatusBadRequest {
+		errstr = fmt.Sprintf("Failed to send slice %s/%s #%d to %s, status %d",
+			bucket, objname, meta.Idx, si.DaemonID, response.StatusCode)
+	}
+	return
+}
+
+// ecrestore reconstructs the object from its slices unless the object is present
+func (t *targetrunner) ecrestore(bucket, objname, fqn string) (errstr string, errcode int) {
+	if _, err := os.Stat(fqn); err == nil || !os.IsNotExist(err) {
+		return
+	}
+	// most of the time a missing object simply does not exist: no need to lock it
+	// and collect the slices cluster-wide unless its slice holders have any
+	if !t.hasslices(bucket, objname) {
+		errstr = fmt.Sprintf("GET local: file %s (object %s/%s) does not exist", fqn, bucket, objname)
+		errcode = http.StatusNotFound
+		return
+	}
+	uname := t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, true)
+	if _, err := os.Stat(fqn); err == nil || !os.IsNotExist(err) {
+		return // restored or PUT in the meantime
+	}
+	started := time.Now()
+	sliceuname := t.sliceuname(bucket, objname)
+	t.rtnamemap.lockname(sliceuname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	slices := t.collectslices(bucket, objname, fqn)
+	defer func() {
+		t.rtnamemap.unlockname(sliceuname, false)
+		for _, slice := range slices {
+			if slice.work {
+				removeworkfiles([]string{slice.fqn})
+			}
+		}
+	}()
+	if len(slices) == 0 {
+		errstr = fmt.Sprintf("GET local: file %s (object %s/%s) does not exist", fqn, bucket, objname)
+		errcode = http.StatusNotFound
+		return
+	}
+	// the most recent set of slices sufficient to reconstruct the object
+	var (
+		meta  *ecmeta
+		byidx []*ecslice
+	)
+	for _, slice := range slices {
+		ref := &slice.meta
+		if ref.Data < 1 || ref.Parity < 0 || ref.Data+ref.Parity > ecMaxSlices {
+			continue
+		}
+		if meta != nil && meta.Stamp >= ref.Stamp {
+			continue
+		}
+		set, count := make([]*ecslice, ref.Data+ref.Parity), 0
+		for _, s := range slices {
+			m := &s.meta
+			if m.Stamp != ref.Stamp || m.Size != ref.Size || m.Data != ref.Data || m.Parity != ref.Parity ||
+				m.Idx < 0 || m.Idx >= len(set) || set[m.Idx] != nil {
+				continue
+			}
+			set[m.Idx] = s
+			count++
+		}
+		if count >= ref.Data {
+			meta, byidx = ref, set
+		}
+	}
+	if meta == nil {
+		errstr = fmt.Sprintf("Erasure coding: cannot restore %s/%s from %d slice(s)", bucket, objname, len(slices))
+		errcode = http.StatusInternalServerError
+		return
+	}
+	if errstr = t.ecjoin(bucket, objname, fqn, meta, byidx); errstr != "" {
+		errcode = http.StatusInternalServerError
+		return
+	}
+	glog.Infof("Restored %s/%s from %d slice(s), %.2f MB, %d µs", bucket, objname, len(slices),
+		float64(meta.Size)/MiB, time.Since(started)/1000)
+	t.statsif.add("numecrestore", 1)
+	// re-place the slices, including the missing ones
+	go func() {
+		if errstr := t.ecencode(bucket, objname); errstr != "" {
+			glog.Errorln(errstr)
+		}
+	}()
+	return
+}
+
+// hasslices returns true if this target or any of the object's slice holders - the targets
+// that follow this one in the HRW order (see ecencode) - has a slice of the object;
+// one extra target is checked to tolerate a target joining the cluster after the PUT
+func (t *targetrunner) hasslices(bucket, objname string) bool {
+	if _, err := os.Stat(t.slicefqn(bucket, objname)); err == nil {
+		return true
+	}
+	conf, _ := t.ecconf(bucket)
+	sis, errstr := hrwTargetList(bucket+"/"+objname, t.smap)
+	if errstr != "" {
+		return false
+	}
+	var (
+		wg    = &sync.WaitGroup{}
+		found = make(chan struct{}, len(sis))
+		n     = 0
+	)
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		if n++; n > conf.DataSlices+conf.ParitySlices+1 {
+			break
+		}
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			response, err := t.httpclient.Head(t.sliceurl(si, bucket, objname))
+			if err != nil {
+				glog.Errorf("Failed to HEAD slice %s/%s at %s, err: %v", bucket, objname, si.DaemonID, err)
+				found <- struct{}{} // unknown: fall back to collecting the slices
+				return
+			}
+			ReadToNull(response.Body)
+			response.Body.Close()
+			if response.StatusCode < http.StatusBadRequest {
+				found <- struct{}{}
+			}
+		}(si)
+	}
+	wg.Wait()
+	return len(found) > 0
+}
+
+// collectslices gets the object's slices from all targets, self including
+func (t *targetrunner) collectslices(bucket, objname, fqn string) (slices []*ecslice) {
+	var (
+		mu = &sync.Mutex{}
+		wg = &sync.WaitGroup{}
+	)
+	slicefqn := t.slicefqn(bucket, objname)
+	if metabytes, errstr := Getxattr(slicefqn, xattrECMeta); errstr == "" && len(metabytes) > 0 {
+		slice := &ecslice{fqn: slicefqn}
+		if err := json.Unmarshal(metabytes, &slice.meta); err != nil {
+			glog.Errorf("Invalid slice %s metadata, err: %v", slicefqn, err)
+		} else {
+			slices = append(slices, slice)
+		}
+	}
+	i := ecMaxSlices // work file indices that do not collide with ecjoin()
+	for _, si := range t.smap.Smap {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		i++
+		wg.Add(1)
+		go func(si *daemonInfo, workfqn string) {
+			defer wg.Done()
+			slice, errstr := t.fetchslice(si, bucket, objname, workfqn)
+			if errstr != "" {
+				glog.Errorln(errstr)
+				return
+			}
+			if slice != nil {
+				mu.Lock()
+				slices = append(slices, slice)
+				mu.Unlock()
+			}
+		}(si, t.ecworkfile(fqn, i))
+	}
+	wg.Wait()
+	return
+}
+
+func (t *targetrunner) fetchslice(si *daemonInfo, bucket, objname, workfqn string) (slice *ecslice, errstr string) {
+	url := t.sliceurl(si, bucket, objname)
+	response, err := t.httpclient.Get(url)
+	if err != nil {
+		errstr = fmt.Sprintf("Failed to GET slice %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		ReadToNull(response.Body)
+		if response.StatusCode != http.StatusNotFound {
+			errstr = fmt.Sprintf("Failed to GET slice %s/%s from %s, status %d",
+				bucket, objname, si.DaemonID, response.StatusCode)
+		}
+		return
+	}
+	slice = &ecslice{fqn: workfqn, work: true}
+	if err = json.Unmarshal([]byte(response.Header.Get(HeaderDfcECMeta)), &slice.meta); err != nil {
+		ReadToNull(response.Body)
+		errstr = fmt.Sprintf("Invalid slice %s/%s metadata from %s, err: %v", bucket, objname, si.DaemonID, err)
+		slice = nil
+		return
+	}
+	if _, _, _, errstr = t.receive(workfqn, false, bucket, objname, "", nil, response.Body); errstr != "" {
+		slice = nil
+	}
+	return
+}
+
+// ecjoin reconstructs the missing data slices, if any, and then joins the data
+// slices into the object - validating its checksum
+func (t *targetrunner) ecjoin(bucket, objname, fqn string, meta *ecmeta, slices []*ecslice) (errstr string) {
+	var (
+		ohobj    cksumvalue
+		missing  bool
+		nslices  = len(slices)
+		valid    = make([]io.Reader, nslices)
+		fill     = make([]io.Writer, nslices)
+		fillfqns = make([]string, meta.Data)
+		files    = make([]*os.File, 0, nslices+meta.Data)
+	)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+		removeworkfiles(fillfqns)
+	}()
+	stream, err := reedsolomon.NewStream(meta.Data, meta.Parity)
+	if err != nil {
+		return fmt.Sprintf("Erasure coding %s/%s: %v", bucket, objname, err)
+	}
+	for i, slice := range slices {
+		var file *os.File
+		if slice != nil {
+			if file, err = os.Open(slice.fqn); err != nil {
+				return fmt.Sprintf("Failed to open slice %s, err: %v", slice.fqn, err)
+			}
+			valid[i] = file
+		} else if i < meta.Data {
+			fillfqns[i] = t.ecworkfile(fqn, i)
+			if file, err = CreateFile(fillfqns[i]); err != nil {
+				return fmt.Sprintf("Failed to create %s, err: %v", fillfqns[i], err)
+			}
+			fill[i], missing = file, true
+		} else {
+			continue
+		}
+		files = append(files, file)
+	}
+	if missing {
+		if err = stream.Reconstruct(valid, fill); err != nil {
+			return fmt.Sprintf("Erasure coding: failed to reconstruct %s/%s, err: %v", bucket, objname, err)
+		}
+	}
+	data := make([]io.Reader, meta.Data)
+	for i := range data {
+		name := fillfqns[i]
+		if slices[i] != nil {
+			name = slices[i].fqn
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return fmt.Sprintf("Failed to open slice %s, err: %v", name, err)
+		}
+		files = append(files, file)
+		data[i] = file
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(stream.Join(writer, data, meta.Size))
+	}()
+	if meta.Cksum != "" {
+		ohobj = newcksumvalue(ChecksumXXHash, meta.Cksum)
+	}
+	workfqn := t.fqn2workfile(fqn)
+	_, nhobj, _, errstr := t.receive(workfqn, false, bucket, objname, "", ohobj, reader)
+	reader.Close() // unblocks the writer if receive has failed
+	if errstr != "" {
+		return
+	}
+	props := &objectProps{version: meta.Version, size: meta.Size, nhobj: nhobj, meta: meta.Meta}
+	if props.nhobj == nil {
+		props.nhobj = ohobj
+	}
+	if err = os.Rename(workfqn, fqn); err != nil {
+		removeworkfiles([]string{workfqn})
+		return fmt.Sprintf("Failed to rename %s => %s, err: %v", workfqn, fqn, err)
+	}
+	return t.finalizeobj(fqn, props)
+}
+
+// ecdelete removes the object's slices cluster-wide and returns the number of removed slices
+func (t *targetrunner) ecdelete(bucket, objname string) (removed int) {
+	var (
+		mu = &sync.Mutex{}
+		wg = &sync.WaitGroup{}
+	)
+	fqn, uname := t.slicefqn(bucket, objname), t.sliceuname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	if err := os.Remove(fqn); err == nil {
+		removed++
+	} else if !os.IsNotExist(err) {
+		glog.Errorf("Failed to remove slice %s, err: %v", fqn, err)
+	}
+	t.rtnamemap.unlockname(uname, true)
+
+	for _, si := range t.smap.Smap {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			url := t.sliceurl(si, bucket, objname)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			if err != nil {
+				glog.Errorf("Unexpected failure to create DELETE request %s, err: %v", url, err)
+				return
+			}
+			response, err := t.httpclient.Do(request)
+			if err != nil {
+				glog.Errorf("Failed to DELETE slice %s/%s at %s, err: %v", bucket, objname, si.DaemonID, err)
+				return
+			}
+			ReadToNull(response.Body)
+			response.Body.Close()
+			switch {
+			case response.StatusCode < http.StatusBadRequest:
+				mu.Lock()
+				removed++
+				mu.Unlock()
+			case response.StatusCode != http.StatusNotFound:
+				glog.Errorf("Failed to DELETE slice %s/%s at %s, status %d",
+					bucket, objname, si.DaemonID, response.StatusCode)
+			}
+		}(si)
+	}
+	wg.Wait()
+	return
+}
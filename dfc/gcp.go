@@ -11,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +27,8 @@ const (
 	gcpDfcHashVal  = "x-goog-meta-dfc-hash-val"
 
 	gcpPageSize = 1000
+
+	gcpChunkSize = 16 * 1024 * 1024 // resumable upload: size of the chunk sent per request
 )
 
 //======
@@ -37,6 +40,10 @@ type gcpimpl struct {
 	t *targetrunner
 }
 
+func init() {
+	registerCloudProvider(ProviderGoogle, func(t *targetrunner) cloudif { return &gcpimpl{t} })
+}
+
 //======
 //
 // global - FIXME: environ
@@ -82,8 +89,8 @@ func (gcpimpl *gcpimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	var query *storage.Query
 	var pageToken string
 
-	if msg.GetPrefix != "" {
-		query = &storage.Query{Prefix: msg.GetPrefix}
+	if msg.GetPrefix != "" || msg.GetDelimiter != "" {
+		query = &storage.Query{Prefix: msg.GetPrefix, Delimiter: msg.GetDelimiter}
 	}
 	if msg.GetPageMarker != "" {
 		pageToken = msg.GetPageMarker
@@ -105,6 +112,11 @@ func (gcpimpl *gcpimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	var reslist = BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
 	reslist.PageMarker = nextPageToken
 	for _, attrs := range objs {
+		// with Delimiter set, the common prefixes are returned as synthetic objects
+		if attrs.Prefix != "" {
+			reslist.CommonPrefixes = append(reslist.CommonPrefixes, attrs.Prefix)
+			continue
+		}
 		entry := &BucketEntry{}
 		entry.Name = attrs.Name
 		if strings.Contains(msg.GetProps, GetPropsSize) {
@@ -161,7 +173,6 @@ func (gcpimpl *gcpimpl) headbucket(bucket string) (bucketprops map[string]string
 		errstr = fmt.Sprintf("Failed to get attributes (bucket %s), err: %v", bucket, err)
 		return
 	}
-	bucketprops[CloudProvider] = ProviderGoogle
 	// GCP always generates a versionid for an object even if versioning is disabled.
 	// So, return that we can detect versionid change on getobj etc
 	bucketprops[Versioning] = VersionCloud
@@ -214,8 +225,8 @@ func (gcpimpl *gcpimpl) headobject(bucket string, objname string) (objmeta map[s
 		errstr = fmt.Sprintf("Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
 		return
 	}
-	objmeta[CloudProvider] = ProviderGoogle
 	objmeta["version"] = fmt.Sprintf("%d", attrs.Generation)
+	objmeta["size"] = strconv.FormatInt(attrs.Size, 10)
 	return
 }
 
@@ -239,16 +250,35 @@ func (gcpimpl *gcpimpl) getobj(fqn string, bucket string, objname string) (props
 	}
 	v = newcksumvalue(attrs.Metadata[gcpDfcHashType], attrs.Metadata[gcpDfcHashVal])
 	md5 := hex.EncodeToString(attrs.MD5)
-	rc, err := o.NewReader(gctx)
-	if err != nil {
-		errstr = fmt.Sprintf("The object %s/%s either does not exist or is not accessible, err: %v", bucket, objname, err)
-		return
-	}
-	defer rc.Close()
 	// hashtype and hash could be empty for legacy objects.
 	props = &objectProps{version: fmt.Sprintf("%d", attrs.Generation)}
-	if _, props.nhobj, props.size, errstr = gcpimpl.t.receive(fqn, false, objname, md5, v, rc); errstr != "" {
-		return
+	props.meta = cloudmeta(attrs.Metadata, gcpDfcHashType, gcpDfcHashVal)
+	// resume the interrupted download of the same generation, if any
+	dl := gcpimpl.t.resumedownload(fqn, props.version, attrs.Size)
+	og := o.If(storage.Conditions{GenerationMatch: attrs.Generation})
+	if rangedcoldget(attrs.Size) {
+		getrange := func(off, length int64) (io.ReadCloser, string) {
+			rc, err := og.NewRangeReader(gctx, off, length)
+			if err != nil {
+				return nil, fmt.Sprintf("Failed to GET %s/%s range [%d, %d), err: %v", bucket, objname, off, off+length, err)
+			}
+			return rc, ""
+		}
+		if props.nhobj, props.size, errstr = gcpimpl.t.receiveranges(fqn, bucket, objname, md5, v, dl,
+			nil, getrange); errstr != "" {
+			return
+		}
+	} else {
+		rc, err := og.NewRangeReader(gctx, dl.Offset, -1)
+		if err != nil {
+			errstr = fmt.Sprintf("The object %s/%s either does not exist or is not accessible, err: %v", bucket, objname, err)
+			return
+		}
+		defer rc.Close()
+		if _, props.nhobj, props.size, errstr = gcpimpl.t.receiveresumable(fqn, false, bucket, objname, md5, v, dl,
+			rc); errstr != "" {
+			return
+		}
 	}
 	if glog.V(4) {
 		glog.Infof("GET %s/%s", bucket, objname)
@@ -256,7 +286,8 @@ func (gcpimpl *gcpimpl) getobj(fqn string, bucket string, objname string) (props
 	return
 }
 
-func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue) (version string, errstr string, errcode int) {
+func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue,
+	meta map[string]string) (version string, errstr string, errcode int) {
 	var (
 		htype, hval string
 		md          map[string]string
@@ -271,9 +302,18 @@ func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksu
 		md[gcpDfcHashType] = htype
 		md[gcpDfcHashVal] = hval
 	}
+	for k, v := range meta {
+		if md == nil {
+			md = make(map[string]string)
+		}
+		md[k] = v
+	}
 	gcpObj := client.Bucket(bucket).Object(objname)
 	wc := gcpObj.NewWriter(gctx)
 	wc.Metadata = md
+	// GCS native (resumable) upload: the object is sent in gcpChunkSize chunks
+	// each of which is retried on failure
+	wc.ChunkSize = gcpChunkSize
 	slab := selectslab(0)
 	buf := slab.alloc()
 	defer slab.free(buf)
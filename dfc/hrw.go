@@ -6,6 +6,8 @@
 package dfc
 
 import (
+	"sort"
+
 	"github.com/OneOfOne/xxhash"
 )
 
@@ -32,6 +34,30 @@ func hrwTarget(name string, smap *Smap) (si *daemonInfo, errstr string) {
 	return
 }
 
+// hrwTargetList returns all targets sorted in the descending order of their
+// HRW weights for the given name, so that the first one is hrwTarget()
+func hrwTargetList(name string, smap *Smap) (sis []*daemonInfo, errstr string) {
+	if smap.count() == 0 {
+		errstr = "DFC cluster map is empty: no targets"
+		return
+	}
+	type weighted struct {
+		cs uint64
+		si *daemonInfo
+	}
+	all := make([]weighted, 0, smap.count())
+	for id, sinfo := range smap.Smap {
+		cs := xxhash.ChecksumString64S(id+":"+name, mLCG32)
+		all = append(all, weighted{cs, sinfo})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].cs > all[j].cs })
+	sis = make([]*daemonInfo, len(all))
+	for i := range all {
+		sis[i] = all[i].si
+	}
+	return
+}
+
 func hrwProxyWithSkip(smap *Smap, idToSkip string) (pi *proxyInfo, errstr string) {
 	smap.Lock()
 	defer smap.Unlock()
@@ -34,9 +34,11 @@ const ( // => Transport.MaxIdleConnsPerHost
 )
 
 type objectProps struct {
-	version string
-	size    int64
-	nhobj   cksumvalue
+	version    string
+	size       int64
+	nhobj      cksumvalue
+	createonly bool              // PUT with "If-None-Match: *"
+	meta       map[string]string // user-defined metadata, if any
 }
 
 //===========
@@ -52,7 +54,7 @@ type cloudif interface {
 	headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int)
 	//
 	getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int)
-	putobj(file *os.File, bucket, objname string, ohobj cksumvalue) (version string, errstr string, errcode int)
+	putobj(file *os.File, bucket, objname string, ohobj cksumvalue, meta map[string]string) (version string, errstr string, errcode int)
 	deleteobj(bucket, objname string) (errstr string, errcode int)
 }
 
@@ -430,6 +432,12 @@ func (h *httprunner) setconfig(name, value string) (errstr string) {
 		} else {
 			ctx.config.LRU.CapacityUpdTime, ctx.config.LRU.CapacityUpdTimeStr = v, value
 		}
+	case "multipart_expire_time":
+		if v, err := time.ParseDuration(value); err != nil {
+			errstr = fmt.Sprintf("Failed to parse multipart_expire_time, err: %v", err)
+		} else {
+			ctx.config.LRU.MultipartExpireTime, ctx.config.LRU.MultipartExpireTimeStr = v, value
+		}
 	case "startup_delay_time":
 		if v, err := time.ParseDuration(value); err != nil {
 			errstr = fmt.Sprintf("Failed to parse startup_delay_time, err: %v", err)
@@ -472,12 +480,42 @@ func (h *httprunner) setconfig(name, value string) (errstr string) {
 		} else {
 			ctx.config.Cksum.ValidateColdGet = v
 		}
+	case "cold_get_part_size":
+		if v, err := strconv.ParseInt(value, 10, 64); err != nil || v < 0 {
+			errstr = fmt.Sprintf("Invalid cold_get_part_size %s - expecting non-negative number of bytes", value)
+		} else {
+			ctx.config.ColdGet.PartSize = v
+		}
+	case "cold_get_concurrency":
+		if v, err := strconv.Atoi(value); err != nil || v < 0 {
+			errstr = fmt.Sprintf("Invalid cold_get_concurrency %s - expecting non-negative number", value)
+		} else {
+			ctx.config.ColdGet.Concurrency = v
+		}
 	case "validate_warm_get":
 		if v, err := strconv.ParseBool(value); err != nil {
 			errstr = fmt.Sprintf("Failed to parse validate_warm_get, err: %v", err)
 		} else {
 			ctx.config.Ver.ValidateWarmGet = v
 		}
+	case "mock_latency":
+		if v, err := time.ParseDuration(value); err != nil {
+			errstr = fmt.Sprintf("Failed to parse mock_latency, err: %v", err)
+		} else {
+			ctx.config.MockCloud.Latency, ctx.config.MockCloud.LatencyStr = v, value
+		}
+	case "mock_error_pct":
+		if v, err := strconv.Atoi(value); err != nil || v < 0 || v > 100 {
+			errstr = fmt.Sprintf("Invalid mock_error_pct %s - expecting percentage in the range [0, 100]", value)
+		} else {
+			ctx.config.MockCloud.ErrorPct = v
+		}
+	case "mock_abort_after":
+		if v, err := strconv.ParseInt(value, 10, 64); err != nil || v < 0 {
+			errstr = fmt.Sprintf("Invalid mock_abort_after %s - expecting non-negative number of bytes", value)
+		} else {
+			ctx.config.MockCloud.AbortAfter = v
+		}
 	case "checksum":
 		if value == ChecksumXXHash || value == ChecksumNone {
 			ctx.config.Cksum.Checksum = value
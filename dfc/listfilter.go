@@ -0,0 +1,146 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//======
+//
+// filtered listing: besides the prefix, GetMsg may carry the regex and range
+// (same as RangeMsg), size and ctime filters. The targets apply the filters
+// while walking local buckets and cached objects, and to the Cloud provider's
+// listing - before returning the page to the proxy
+//
+//======
+
+type listfilter struct {
+	prefix           string
+	regex            *regexp.Regexp
+	hasrange         bool
+	min, max         int64     // range of the number matched by regex
+	minsize, maxsize int64     // zero - no limit
+	after, before    time.Time // ctime window [after, before); zero - no limit
+}
+
+// newlistfilter returns nil if the message does not filter beyond the prefix
+func newlistfilter(msg *GetMsg) (f *listfilter, errstr string) {
+	if msg.GetRegex == "" && msg.GetRange == "" && msg.GetMinSize == 0 && msg.GetMaxSize == 0 &&
+		msg.GetCtimeAfter == "" && msg.GetCtimeBefore == "" {
+		return
+	}
+	var err error
+	f = &listfilter{prefix: msg.GetPrefix, minsize: msg.GetMinSize, maxsize: msg.GetMaxSize}
+	if msg.GetRange != "" {
+		if msg.GetRegex == "" {
+			return nil, fmt.Sprintf("Range %q requires regex", msg.GetRange)
+		}
+		if !strings.Contains(msg.GetRange, ":") {
+			return nil, fmt.Sprintf("Invalid range %q, expecting \"min:max\"", msg.GetRange)
+		}
+		if f.min, f.max, err = parseRange(msg.GetRange); err != nil {
+			return nil, fmt.Sprintf("Invalid range %q, err: %v", msg.GetRange, err)
+		}
+		f.hasrange = true
+	}
+	if msg.GetRegex != "" {
+		if f.regex, err = regexp.Compile(msg.GetRegex); err != nil {
+			return nil, fmt.Sprintf("Invalid regex %q, err: %v", msg.GetRegex, err)
+		}
+	}
+	if f.minsize < 0 || f.maxsize < 0 || (f.maxsize != 0 && f.minsize > f.maxsize) {
+		return nil, fmt.Sprintf("Invalid size filter [%d, %d]", f.minsize, f.maxsize)
+	}
+	if msg.GetCtimeAfter != "" {
+		if f.after, err = time.Parse(time.RFC3339, msg.GetCtimeAfter); err != nil {
+			return nil, fmt.Sprintf("Invalid ctime_after %q, err: %v", msg.GetCtimeAfter, err)
+		}
+	}
+	if msg.GetCtimeBefore != "" {
+		if f.before, err = time.Parse(time.RFC3339, msg.GetCtimeBefore); err != nil {
+			return nil, fmt.Sprintf("Invalid ctime_before %q, err: %v", msg.GetCtimeBefore, err)
+		}
+	}
+	return
+}
+
+// needprops returns true if the filter needs object size and ctime
+func (f *listfilter) needprops() bool {
+	return f.minsize != 0 || f.maxsize != 0 || !f.after.IsZero() || !f.before.IsZero()
+}
+
+func (f *listfilter) accept(objname string, size int64, ctime time.Time) bool {
+	if f.regex != nil {
+		if f.hasrange {
+			if !acceptRegexRange(objname, f.prefix, f.regex, f.min, f.max) {
+				return false
+			}
+		} else if !f.regex.MatchString(strings.TrimPrefix(objname, f.prefix)) {
+			// no range - any match, not necessarily a number
+			return false
+		}
+	}
+	if size < f.minsize || (f.maxsize != 0 && size > f.maxsize) {
+		return false
+	}
+	if !f.after.IsZero() && ctime.Before(f.after) {
+		return false
+	}
+	return f.before.IsZero() || ctime.Before(f.before)
+}
+
+// filtercloud filters the Cloud provider's listing (jsbytes) that was requested
+// with the cloudmsg - see cloudmsg(); common prefixes and the page marker are kept
+// as is, so that the filtered page may be short or even empty
+func (f *listfilter) filtercloud(jsbytes []byte, msg, cloudmsg *GetMsg) ([]byte, string) {
+	reslist := &BucketList{}
+	if err := json.Unmarshal(jsbytes, reslist); err != nil {
+		return nil, fmt.Sprintf("Failed to unmarshal bucket list, err: %v", err)
+	}
+	entries := reslist.Entries[:0]
+	for _, entry := range reslist.Entries {
+		var ctime time.Time
+		if entry.Ctime != "" {
+			ctime, _ = time.Parse(time.RFC3339Nano, entry.Ctime)
+		}
+		if f.accept(entry.Name, entry.Size, ctime) {
+			entries = append(entries, entry)
+		}
+	}
+	reslist.Entries = entries
+	if cloudmsg != msg {
+		retimeEntries(reslist.Entries, msg)
+		if !strings.Contains(msg.GetProps, GetPropsSize) {
+			for _, entry := range reslist.Entries {
+				entry.Size = 0
+			}
+		}
+	}
+	jsbytes, err := json.Marshal(reslist)
+	assert(err == nil, err)
+	return jsbytes, ""
+}
+
+// cloudmsg returns the message to list the Cloud bucket with: the filter may
+// need the objects' sizes and (precise) creation times
+func (f *listfilter) cloudmsg(msg *GetMsg) *GetMsg {
+	if !f.needprops() {
+		return msg
+	}
+	cloudmsg := *msg
+	cloudmsg.GetTimeFormat = time.RFC3339Nano
+	for _, prop := range []string{GetPropsSize, GetPropsCtime} {
+		if !strings.Contains(cloudmsg.GetProps, prop) {
+			cloudmsg.GetProps += "," + prop
+		}
+	}
+	return &cloudmsg
+}
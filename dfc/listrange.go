@@ -365,7 +365,7 @@ func (t *targetrunner) prefetchMissing(objname, bucket string) {
 		vchanged, coldget bool
 		props             *objectProps
 	)
-	versioncfg := &ctx.config.Ver
+	versioncfg := t.versionconf(bucket)
 	fqn := t.fqn(bucket, objname)
 	islocal := t.islocalBucket(bucket)
 	//
@@ -384,7 +384,7 @@ func (t *targetrunner) prefetchMissing(objname, bucket string) {
 	if !coldget {
 		return
 	}
-	if props, errstr, _ = t.coldget(bucket, objname, true); errstr != "" {
+	if props, errstr, _ = t.coldget(bucket, objname, true, nil); errstr != "" {
 		if errstr != "skip" {
 			glog.Errorln(errstr)
 		}
@@ -474,6 +474,13 @@ func parseRangeListMsgBase(jsmap map[string]interface{}) (RangeListMsgBase, erro
 		}
 		pmb.Wait = wait
 	}
+	if v, ok := jsmap["dest_bucket"]; ok {
+		destbucket, ok := v.(string)
+		if !ok {
+			return pmb, fmt.Errorf("Error parsing PrefetchMsgBase DestBucket: Not a string")
+		}
+		pmb.DestBucket = destbucket
+	}
 	return pmb, nil
 }
 
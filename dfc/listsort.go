@@ -0,0 +1,268 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"container/heap"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//======
+//
+// sorted listing: each target lists its objects in the GetMsg.GetSort order
+// starting past the continuation token (PageMarker), and the proxy then k-way
+// merges the targets' pages. The token is the name of the last listed object
+// or, when sorting by size or time, "<key>|<name>" - object names are unique
+// and make the order total, so that the pages never skip or repeat entries
+//
+//======
+
+const sortByName = "name"
+
+type listsort struct {
+	field string // sortByName, GetPropsSize, GetPropsAtime or GetPropsCtime
+	desc  bool
+}
+
+// listitem is a bucket list entry or, if entry is nil, a common prefix
+type listitem struct {
+	key   int64 // size or time in nanoseconds; zero when sorting by name
+	name  string
+	entry *BucketEntry
+}
+
+// parsesort parses GetMsg.GetSort, e.g. "descending, size"; the default is
+// ascending by name
+func parsesort(msg *GetMsg) (ls *listsort, errstr string) {
+	ls = &listsort{field: sortByName}
+	for _, opt := range strings.Split(msg.GetSort, ",") {
+		switch opt = strings.TrimSpace(opt); opt {
+		case "", GetSortAsc:
+		case GetSortDes:
+			ls.desc = true
+		case sortByName, GetPropsSize, GetPropsAtime, GetPropsCtime:
+			ls.field = opt
+		default:
+			errstr = fmt.Sprintf("Invalid sort option %q in %q", opt, msg.GetSort)
+			return
+		}
+	}
+	if msg.GetDelimiter != "" && ls.field != sortByName {
+		errstr = fmt.Sprintf("Cannot sort by %s when listing with delimiter", ls.field)
+	}
+	return
+}
+
+func (ls *listsort) isdefault() bool { return ls.field == sortByName && !ls.desc }
+
+func (ls *listsort) bytime() bool { return ls.field == GetPropsAtime || ls.field == GetPropsCtime }
+
+// keyof returns the sort key of the file
+func (ls *listsort) keyof(osfi os.FileInfo) int64 {
+	switch ls.field {
+	case GetPropsSize:
+		return osfi.Size()
+	case GetPropsAtime:
+		atime, _, _ := getAmTimes(osfi)
+		return atime.UnixNano()
+	case GetPropsCtime:
+		return osfi.ModTime().UnixNano()
+	}
+	return 0
+}
+
+// key returns the sort key of the entry listed by a target - see getLocalBucketObjects
+func (ls *listsort) key(entry *BucketEntry, timeformat string) int64 {
+	var s string
+	switch ls.field {
+	case GetPropsSize:
+		return entry.Size
+	case GetPropsAtime:
+		s = entry.Atime
+	case GetPropsCtime:
+		s = entry.Ctime
+	default:
+		return 0
+	}
+	if timeformat == "" {
+		timeformat = RFC822
+	}
+	t, err := time.Parse(timeformat, s)
+	if err != nil {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (ls *listsort) less(a, b *listitem) bool {
+	if ls.desc {
+		a, b = b, a
+	}
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.name < b.name
+}
+
+func (ls *listsort) token(it *listitem) string {
+	if ls.field == sortByName {
+		return it.name
+	}
+	return strconv.FormatInt(it.key, 10) + "|" + it.name
+}
+
+// marker parses the continuation token; returns nil if the token is empty
+func (ls *listsort) marker(token string) (it *listitem, errstr string) {
+	if token == "" {
+		return
+	}
+	if ls.field == sortByName {
+		return &listitem{name: token}, ""
+	}
+	i := strings.Index(token, "|")
+	if i < 0 {
+		errstr = fmt.Sprintf("Invalid page marker %q (sorting by %s)", token, ls.field)
+		return
+	}
+	key, err := strconv.ParseInt(token[:i], 10, 64)
+	if err != nil {
+		errstr = fmt.Sprintf("Invalid page marker %q (sorting by %s), err: %v", token, ls.field, err)
+		return
+	}
+	return &listitem{key: key, name: token[i+1:]}, ""
+}
+
+// listed returns true if the item has been listed by one of the previous pages:
+// either directly or - if the marker is a common prefix - as part of it
+func (ls *listsort) listed(it, marker *listitem, delim string) bool {
+	if marker == nil {
+		return false
+	}
+	if !ls.less(marker, it) {
+		return true
+	}
+	return delim != "" && strings.HasSuffix(marker.name, delim) && strings.HasPrefix(it.name, marker.name)
+}
+
+// page sorts the items and returns the first page of at most pageSize of them;
+// a full page is marked incomplete as there may be more items to follow
+func (ls *listsort) page(items []*listitem, pageSize int) *BucketList {
+	sort.Slice(items, func(i, j int) bool { return ls.less(items[i], items[j]) })
+	list := &BucketList{}
+	if len(items) >= pageSize {
+		items = items[:pageSize]
+		list.PageMarker = ls.token(items[pageSize-1])
+	}
+	ls.fill(list, items)
+	return list
+}
+
+// merge k-way merges the targets' pages into one page of at most pageSize items.
+// A target that has more (PageMarker is set) may list, on its next page, items
+// that go before the remaining ones - hence, the merge stops at the smallest of
+// the targets' page markers (bound). Note that a filtered page may be short or
+// empty but still have a marker
+func (ls *listsort) merge(pages []*BucketList, pageSize int, timeformat string) *BucketList {
+	var (
+		h      = &mergeheap{ls: ls}
+		merged = make([]*listitem, 0, pageSize)
+		bound  *listitem
+	)
+	for _, page := range pages {
+		if marker, _ := ls.marker(page.PageMarker); marker != nil && (bound == nil || ls.less(marker, bound)) {
+			bound = marker
+		}
+		items := make([]*listitem, 0, len(page.Entries)+len(page.CommonPrefixes))
+		for _, entry := range page.Entries {
+			items = append(items, &listitem{key: ls.key(entry, timeformat), name: entry.Name, entry: entry})
+		}
+		for _, cp := range page.CommonPrefixes {
+			items = append(items, &listitem{name: cp})
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sort.Slice(items, func(i, j int) bool { return ls.less(items[i], items[j]) })
+		h.cursors = append(h.cursors, &mergecursor{items: items})
+	}
+	heap.Init(h)
+	for h.Len() > 0 && len(merged) < pageSize {
+		c := h.cursors[0]
+		if bound != nil && ls.less(bound, c.items[0]) {
+			break
+		}
+		// common prefixes are shared by the targets
+		if n := len(merged); n == 0 || c.items[0].entry != nil || merged[n-1].name != c.items[0].name {
+			merged = append(merged, c.items[0])
+		}
+		if c.items = c.items[1:]; len(c.items) > 0 {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	list := &BucketList{}
+	if len(merged) == pageSize && (h.Len() > 0 || bound != nil) {
+		list.PageMarker = ls.token(merged[len(merged)-1])
+	} else if bound != nil {
+		// all items up to and including the bound are merged
+		list.PageMarker = ls.token(bound)
+	}
+	ls.fill(list, merged)
+	return list
+}
+
+func (ls *listsort) fill(list *BucketList, items []*listitem) {
+	list.Entries = make([]*BucketEntry, 0, len(items))
+	for _, it := range items {
+		if it.entry != nil {
+			list.Entries = append(list.Entries, it.entry)
+		} else {
+			list.CommonPrefixes = append(list.CommonPrefixes, it.name)
+		}
+	}
+}
+
+//===========================================================================
+//
+// k-way merge heap: a cursor per target, ordered by the cursor's next item
+//
+//===========================================================================
+
+type mergecursor struct {
+	items []*listitem
+}
+
+type mergeheap struct {
+	ls      *listsort
+	cursors []*mergecursor
+}
+
+func (h *mergeheap) Len() int { return len(h.cursors) }
+
+func (h *mergeheap) Less(i, j int) bool {
+	return h.ls.less(h.cursors[i].items[0], h.cursors[j].items[0])
+}
+
+func (h *mergeheap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *mergeheap) Push(x interface{}) {
+	h.cursors = append(h.cursors, x.(*mergecursor))
+}
+
+func (h *mergeheap) Pop() interface{} {
+	n := len(h.cursors)
+	c := h.cursors[n-1]
+	h.cursors = h.cursors[:n-1]
+	return c
+}
@@ -0,0 +1,391 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// implements cloudif on top of a (shared, e.g. NFS-mounted) directory tree:
+// buckets are the top-level directories under ctx.config.CloudFSRoot,
+// objects - regular files underneath
+//
+//======
+type fsimpl struct {
+	t *targetrunner
+}
+
+func init() {
+	registerCloudProvider(ProviderFS, func(t *targetrunner) cloudif { return &fsimpl{t} })
+}
+
+func fsErrorToHTTP(err error) int {
+	if os.IsNotExist(err) {
+		return http.StatusNotFound
+	}
+	if os.IsPermission(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// the version of an object stored in the filesystem is derived from its mtime and size
+func fsVersion(finfo os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", finfo.ModTime().UnixNano(), finfo.Size())
+}
+
+// fsBucketPath validates bucket name and returns its directory
+func fsBucketPath(bucket string) (string, string) {
+	if bucket == "" || bucket == "." || bucket == ".." || strings.ContainsRune(bucket, filepath.Separator) {
+		return "", fmt.Sprintf("Invalid bucket name %q", bucket)
+	}
+	return filepath.Join(ctx.config.CloudFSRoot, bucket), ""
+}
+
+// fsObjectPath returns the path of the object making sure it does not escape the bucket
+func fsObjectPath(bucket, objname string) (string, string) {
+	bdir, errstr := fsBucketPath(bucket)
+	if errstr != "" {
+		return "", errstr
+	}
+	clean := filepath.Clean(objname)
+	if objname == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Sprintf("Invalid object name %q", objname)
+	}
+	return filepath.Join(bdir, clean), ""
+}
+
+func fsGetCksum(path string) cksumvalue {
+	b, errstr := Getxattr(path, xattrXXHashVal)
+	if errstr != "" || len(b) == 0 {
+		return nil
+	}
+	return newcksumvalue(ChecksumXXHash, string(b))
+}
+
+//==================
+//
+// bucket operations
+//
+//==================
+func (fsimpl *fsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("listbucket %s", bucket)
+	}
+	bdir, errstr := fsBucketPath(bucket)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	type fsentry struct {
+		name  string
+		finfo os.FileInfo
+	}
+	var (
+		all      = make([]fsentry, 0, initialBucketListSize)
+		pagesize = DefaultPageSize
+	)
+	if msg.GetPageSize != 0 {
+		pagesize = msg.GetPageSize
+	}
+	walkfn := func(path string, finfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if finfo.IsDir() || strings.HasPrefix(finfo.Name(), workfileprefix) {
+			return nil
+		}
+		name, err := filepath.Rel(bdir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !strings.HasPrefix(name, msg.GetPrefix) || prevpage(name, msg.GetPageMarker, msg.GetDelimiter) {
+			return nil
+		}
+		all = append(all, fsentry{name, finfo})
+		return nil
+	}
+	if err := filepath.Walk(bdir, walkfn); err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to list objects of bucket %s, err: %v", bucket, err)
+		return
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	// roll up into common prefixes (finfo == nil): the names that share one are adjacent
+	if msg.GetDelimiter != "" {
+		rolled := all[:0]
+		for _, e := range all {
+			if cp := commonprefix(e.name, msg.GetPrefix, msg.GetDelimiter); cp != "" {
+				if n := len(rolled); n > 0 && rolled[n-1].name == cp {
+					continue
+				}
+				e = fsentry{name: cp}
+			}
+			rolled = append(rolled, e)
+		}
+		all = rolled
+	}
+
+	var reslist = BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
+	if len(all) > pagesize {
+		all = all[:pagesize]
+		reslist.PageMarker = all[pagesize-1].name
+	}
+	for _, e := range all {
+		if e.finfo == nil {
+			reslist.CommonPrefixes = append(reslist.CommonPrefixes, e.name)
+			continue
+		}
+		entry := &BucketEntry{}
+		entry.Name = e.name
+		if strings.Contains(msg.GetProps, GetPropsSize) {
+			entry.Size = e.finfo.Size()
+		}
+		if strings.Contains(msg.GetProps, GetPropsBucket) {
+			entry.Bucket = bucket
+		}
+		if strings.Contains(msg.GetProps, GetPropsCtime) {
+			t := e.finfo.ModTime()
+			switch msg.GetTimeFormat {
+			case "":
+				fallthrough
+			case RFC822:
+				entry.Ctime = t.Format(time.RFC822)
+			default:
+				entry.Ctime = t.Format(msg.GetTimeFormat)
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsChecksum) {
+			if v := fsGetCksum(filepath.Join(bdir, e.name)); v != nil {
+				_, entry.Checksum = v.get()
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsVersion) {
+			entry.Version = fsVersion(e.finfo)
+		}
+		reslist.Entries = append(reslist.Entries, entry)
+	}
+	if glog.V(4) {
+		glog.Infof("listbucket count %d", len(reslist.Entries))
+	}
+	jsbytes, err := json.Marshal(reslist)
+	assert(err == nil, err)
+	return
+}
+
+func (fsimpl *fsimpl) headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headbucket %s", bucket)
+	}
+	bucketprops = make(map[string]string)
+
+	bdir, errstr := fsBucketPath(bucket)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	finfo, err := os.Stat(bdir)
+	if err == nil && !finfo.IsDir() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("The bucket %s either does not exist or is not accessible, err: %v", bucket, err)
+		return
+	}
+	// objects are versioned by mtime and size
+	bucketprops[Versioning] = VersionCloud
+	return
+}
+
+func (fsimpl *fsimpl) getbucketnames() (buckets []string, errstr string, errcode int) {
+	finfos, err := ioutil.ReadDir(ctx.config.CloudFSRoot)
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to list all buckets, err: %v", err)
+		return
+	}
+	buckets = make([]string, 0, 16)
+	for _, finfo := range finfos {
+		if finfo.IsDir() {
+			buckets = append(buckets, finfo.Name())
+		}
+	}
+	return
+}
+
+//============
+//
+// object meta
+//
+//============
+func (fsimpl *fsimpl) headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headobject %s/%s", bucket, objname)
+	}
+	objmeta = make(map[string]string)
+
+	path, errstr := fsObjectPath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	finfo, err := os.Stat(path)
+	if err == nil && finfo.IsDir() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
+		return
+	}
+	objmeta["version"] = fsVersion(finfo)
+	objmeta["size"] = strconv.FormatInt(finfo.Size(), 10)
+	return
+}
+
+//=======================
+//
+// object data operations
+//
+//=======================
+func (fsimpl *fsimpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
+	path, errstr := fsObjectPath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err == nil && finfo.IsDir() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	// may not have dfc checksum (e.g., placed into the filesystem directly)
+	v := fsGetCksum(path)
+	props = &objectProps{version: fsVersion(finfo), meta: fsimpl.t.objmeta(path)}
+	if _, props.nhobj, props.size, errstr = fsimpl.t.receive(fqn, false, bucket, objname, "", v, file); errstr != "" {
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("GET %s/%s", bucket, objname)
+	}
+	return
+}
+
+func (fsimpl *fsimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue,
+	meta map[string]string) (version string, errstr string, errcode int) {
+	path, errstr := fsObjectPath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	// the bucket itself must exist - same as with the real Cloud
+	bdir, _ := fsBucketPath(bucket)
+	if _, err := os.Stat(bdir); err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("PUT %s/%s: bucket is not accessible, err: %v", bucket, objname, err)
+		return
+	}
+	// write into a temporary file next to the destination and rename,
+	// so that concurrent readers never see partial content
+	dir, base := filepath.Split(path)
+	tmppath := filepath.Join(dir, workfileprefix+base+"."+strconv.FormatInt(time.Now().UnixNano(), 16))
+	tmpfile, err := CreateFile(tmppath)
+	if err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("PUT %s/%s: failed to create %s, err: %v", bucket, objname, tmppath, err)
+		return
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	written, err := io.CopyBuffer(tmpfile, file, buf)
+	slab.free(buf)
+	if err == nil {
+		err = tmpfile.Sync()
+	}
+	if errc := tmpfile.Close(); err == nil {
+		err = errc
+	}
+	if err == nil && ohash != nil {
+		if htype, hval := ohash.get(); htype == ChecksumXXHash {
+			if errstr := Setxattr(tmppath, xattrXXHashVal, []byte(hval)); errstr != "" && glog.V(3) {
+				glog.Infof("PUT %s/%s: not storing checksum, %s", bucket, objname, errstr)
+			}
+		}
+	}
+	if err == nil && meta != nil {
+		jsbytes, errj := json.Marshal(meta)
+		assert(errj == nil, errj)
+		if errstr := Setxattr(tmppath, xattrObjMeta, jsbytes); errstr != "" && glog.V(3) {
+			glog.Infof("PUT %s/%s: not storing user-defined metadata, %s", bucket, objname, errstr)
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmppath, path)
+	}
+	if err != nil {
+		if errr := os.Remove(tmppath); errr != nil && !os.IsNotExist(errr) {
+			glog.Errorf("Nested error: PUT %s/%s => (remove %s => err: %v)", bucket, objname, tmppath, errr)
+		}
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("PUT %s/%s: failed to write, err: %v", bucket, objname, err)
+		return
+	}
+	finfo, err := os.Stat(path)
+	if err != nil {
+		errstr = fmt.Sprintf("PUT %s/%s: failed to stat, err: %v", bucket, objname, err)
+		return
+	}
+	version = fsVersion(finfo)
+	if glog.V(4) {
+		glog.Infof("PUT %s/%s, size %d, version %s", bucket, objname, written, version)
+	}
+	return
+}
+
+func (fsimpl *fsimpl) deleteobj(bucket, objname string) (errstr string, errcode int) {
+	path, errstr := fsObjectPath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		errcode = fsErrorToHTTP(err)
+		errstr = fmt.Sprintf("Failed to DELETE %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("DELETE %s/%s", bucket, objname)
+	}
+	return
+}
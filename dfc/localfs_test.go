@@ -0,0 +1,139 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func fstestsetup(t *testing.T) (fs *fsimpl, root string) {
+	root, err := ioutil.TempDir("", "dfc-localfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.config.CloudFSRoot = filepath.Join(root, "cloud")
+	ctx.config.Cksum.Checksum = ChecksumXXHash
+	if err = os.MkdirAll(filepath.Join(ctx.config.CloudFSRoot, "bucket"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tr := &targetrunner{lbmap: &lbmap{LBmap: make(map[string]BucketProps)}, coldgets: newcoldgets()}
+	return &fsimpl{t: tr}, root
+}
+
+func TestFSObjectPath(t *testing.T) {
+	ctx.config.CloudFSRoot = "/cloud"
+	tests := []struct {
+		bucket, objname, path string
+	}{
+		{"bucket", "obj", "/cloud/bucket/obj"},
+		{"bucket", "a/b/../c", "/cloud/bucket/a/c"},
+		{"bucket", "./a//b", "/cloud/bucket/a/b"},
+		{"bucket", "..", ""},
+		{"bucket", "../other/obj", ""},
+		{"bucket", "a/../../other/obj", ""},
+		{"bucket", "/etc/passwd", ""},
+		{"bucket", ".", ""},
+		{"bucket", "", ""},
+		{"..", "obj", ""},
+		{".", "obj", ""},
+		{"a/b", "obj", ""},
+		{"", "obj", ""},
+	}
+	for _, test := range tests {
+		path, errstr := fsObjectPath(test.bucket, test.objname)
+		if test.path == "" {
+			if errstr == "" {
+				t.Errorf("%q/%q: expected to be rejected, got %q", test.bucket, test.objname, path)
+			}
+			continue
+		}
+		if errstr != "" || path != test.path {
+			t.Errorf("%q/%q: expected %q, got %q (%s)", test.bucket, test.objname, test.path, path, errstr)
+		}
+	}
+}
+
+func TestFSPutGetList(t *testing.T) {
+	fs, root := fstestsetup(t)
+	defer os.RemoveAll(root)
+
+	objects := map[string][]byte{
+		"a/b/obj1": bytes.Repeat([]byte("1"), 1000),
+		"obj2":     bytes.Repeat([]byte("2"), 2000),
+	}
+	for objname, data := range objects {
+		src := filepath.Join(root, "src")
+		if err := ioutil.WriteFile(src, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, errstr, _ := fs.putobj(file, "bucket", objname, nil, nil)
+		file.Close()
+		if errstr != "" || version == "" {
+			t.Fatalf("PUT %s: version %q, err: %s", objname, version, errstr)
+		}
+		// written into a temporary file that is then renamed
+		dir, base := filepath.Split(filepath.Join(ctx.config.CloudFSRoot, "bucket", objname))
+		if tmps, _ := filepath.Glob(filepath.Join(dir, workfileprefix+base+"*")); len(tmps) != 0 {
+			t.Errorf("PUT %s: temporary files left behind: %v", objname, tmps)
+		}
+		objmeta, errstr, _ := fs.headobject("bucket", objname)
+		if errstr != "" || objmeta["size"] != strconv.Itoa(len(data)) || objmeta["version"] != version {
+			t.Errorf("HEAD %s: %v, err: %s", objname, objmeta, errstr)
+		}
+	}
+	if _, errstr, errcode := fs.putobj(nil, "bucket", "../escape", nil, nil); errstr == "" || errcode != http.StatusBadRequest {
+		t.Errorf("PUT ../escape: expected to fail with %d, got %d (%s)", http.StatusBadRequest, errcode, errstr)
+	}
+	if _, errstr, errcode := fs.headobject("bucket", "nonexistent"); errcode != http.StatusNotFound {
+		t.Errorf("HEAD nonexistent: expected %d, got %d (%s)", http.StatusNotFound, errcode, errstr)
+	}
+
+	jsbytes, errstr, _ := fs.listbucket("bucket", &GetMsg{GetProps: GetPropsSize})
+	if errstr != "" {
+		t.Fatal(errstr)
+	}
+	reslist := &BucketList{}
+	if err := json.Unmarshal(jsbytes, reslist); err != nil {
+		t.Fatal(err)
+	}
+	if len(reslist.Entries) != len(objects) {
+		t.Fatalf("list: %d entries, expected %d", len(reslist.Entries), len(objects))
+	}
+	for _, entry := range reslist.Entries {
+		if data, ok := objects[entry.Name]; !ok || entry.Size != int64(len(data)) {
+			t.Errorf("list: unexpected entry %s, size %d", entry.Name, entry.Size)
+		}
+	}
+
+	for objname, data := range objects {
+		fqn := filepath.Join(root, "cache", objname)
+		props, errstr, _ := fs.getobj(fqn, "bucket", objname)
+		if errstr != "" {
+			t.Fatalf("GET %s: %s", objname, errstr)
+		}
+		got, err := ioutil.ReadFile(fqn)
+		if err != nil || !bytes.Equal(got, data) || props.size != int64(len(data)) {
+			t.Errorf("GET %s: %d bytes (size %d), expected %d, err: %v", objname, len(got), props.size, len(data), err)
+		}
+	}
+	if errstr, _ := fs.deleteobj("bucket", "obj2"); errstr != "" {
+		t.Fatal(errstr)
+	}
+	if _, errstr, errcode := fs.getobj(filepath.Join(root, "cache", "obj2"), "bucket", "obj2"); errcode != http.StatusNotFound {
+		t.Errorf("GET deleted obj2: expected %d, got %d (%s)", http.StatusNotFound, errcode, errstr)
+	}
+}
@@ -0,0 +1,127 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// GET /v1/objects/bucket-name/object-name?what=locate: the proxy asks every
+// target to look for the object on all its mountpaths and returns the HRW
+// target's ObjectLocation along with the copies found elsewhere and the errors
+// of the targets that failed to reply
+//
+//======
+
+// httpobjlocate: the proxy
+func (p *proxyrunner) httpobjlocate(w http.ResponseWriter, r *http.Request, bucket, objname string, si *daemonInfo) {
+	type targetReply struct {
+		id  string
+		loc *ObjectLocation
+		err error
+	}
+	var (
+		islocal = p.islocalBucket(bucket)
+		ch      = make(chan *targetReply, len(p.smap.Smap))
+		wg      = &sync.WaitGroup{}
+	)
+	for _, tsi := range p.smap.Smap {
+		wg.Add(1)
+		go func(tsi *daemonInfo) {
+			defer wg.Done()
+			url := fmt.Sprintf("%s%s?%s=%t&%s=%s", tsi.DirectURL, r.URL.Path, URLParamLocal, islocal, URLParamWhat, GetWhatLocate)
+			outjson, err, _, status := p.call(tsi, url, http.MethodGet, nil, ctx.config.Timeout.Default)
+			if err != nil {
+				p.kalive.onerr(err, status)
+				ch <- &targetReply{id: tsi.DaemonID, err: err}
+				return
+			}
+			loc := &ObjectLocation{}
+			err = json.Unmarshal(outjson, loc)
+			ch <- &targetReply{id: tsi.DaemonID, loc: loc, err: err}
+		}(tsi)
+	}
+	wg.Wait()
+	close(ch)
+
+	var (
+		loc    *ObjectLocation
+		copies = make([]*ObjectCopy, 0)
+		errs   = make(map[string]string)
+	)
+	for reply := range ch {
+		if reply.err != nil {
+			glog.Errorf("Failed to locate %s/%s on target %s, err: %v", bucket, objname, reply.id, reply.err)
+			errs[reply.id] = reply.err.Error()
+			continue
+		}
+		if reply.id == si.DaemonID {
+			loc = reply.loc
+		} else if reply.loc.Present {
+			copies = append(copies, &ObjectCopy{DaemonID: reply.id, FQN: reply.loc.FQN, Size: reply.loc.Size})
+		}
+		copies = append(copies, reply.loc.Copies...)
+	}
+	// the HRW target is known even when it fails to reply - its mountpath is not
+	if loc == nil {
+		loc = &ObjectLocation{Bucket: bucket, Objname: objname, DaemonID: si.DaemonID}
+	}
+	if len(errs) > 0 {
+		loc.Errors = errs
+	}
+	sort.Slice(copies, func(i, j int) bool {
+		if copies[i].DaemonID != copies[j].DaemonID {
+			return copies[i].DaemonID < copies[j].DaemonID
+		}
+		return copies[i].FQN < copies[j].FQN
+	})
+	loc.Copies = copies
+	jsbytes, err := json.Marshal(loc)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "locate")
+}
+
+// httpobjlocate: the target looks for the object (and its replica) on all its mountpaths
+func (t *targetrunner) httpobjlocate(w http.ResponseWriter, r *http.Request, bucket, objname string, islocal bool) {
+	loc := &ObjectLocation{
+		Bucket:    bucket,
+		Objname:   objname,
+		DaemonID:  t.si.DaemonID,
+		Mountpath: hrwMpath(bucket + "/" + objname),
+		FQN:       t.fqn(bucket, objname),
+	}
+	for mpath := range ctx.mountpaths.Available {
+		dirs := []string{makePathCloud(mpath), makePathReplica(mpath)}
+		if islocal {
+			dirs[0] = makePathLocal(mpath)
+		}
+		for i, dir := range dirs {
+			fqn := filepath.Join(dir, bucket, objname)
+			finfo, err := os.Stat(fqn)
+			if err != nil || !finfo.Mode().IsRegular() {
+				continue
+			}
+			if fqn == loc.FQN {
+				loc.Present, loc.Size = true, finfo.Size()
+				continue
+			}
+			loc.Copies = append(loc.Copies, &ObjectCopy{DaemonID: t.si.DaemonID, FQN: fqn, Size: finfo.Size(), Replica: i == 1})
+		}
+	}
+	jsbytes, err := json.Marshal(loc)
+	assert(err == nil, err)
+	t.writeJSON(w, r, jsbytes, "locate")
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -36,6 +37,8 @@ type lructx struct {
 	h       *maxheap
 	oldwork []*fileinfo
 	t       *targetrunner
+	rootdir string
+	bucket  string // non-empty when the bucket has its own LRU configuration
 }
 
 func (t *targetrunner) runLRU() {
@@ -47,16 +50,35 @@ func (t *targetrunner) runLRU() {
 	fschkwg := &sync.WaitGroup{}
 
 	glog.Infof("LRU: %s started: dont-evict-time %v", xlru.tostring(), ctx.config.LRU.DontEvictTime)
-	for mpath := range ctx.mountpaths.Available {
-		fschkwg.Add(1)
-		go t.oneLRU(makePathLocal(mpath), fschkwg, xlru)
+	if ctx.config.LRU.LRUEnabled {
+		hwm, lwm := ctx.config.LRU.HighWM, ctx.config.LRU.LowWM
+		for mpath := range ctx.mountpaths.Available {
+			fschkwg.Add(1)
+			go t.oneLRU(makePathLocal(mpath), "", hwm, lwm, fschkwg, xlru)
+		}
+		fschkwg.Wait()
+		for mpath := range ctx.mountpaths.Available {
+			fschkwg.Add(1)
+			go t.oneLRU(makePathCloud(mpath), "", hwm, lwm, fschkwg, xlru)
+		}
+		fschkwg.Wait()
 	}
-	fschkwg.Wait()
-	for mpath := range ctx.mountpaths.Available {
-		fschkwg.Add(1)
-		go t.oneLRU(makePathCloud(mpath), fschkwg, xlru)
+	// buckets with their own LRU configuration (see BucketProps.LRUConf)
+	bucketsLRU := func(buckets map[string]BucketProps, makePath func(string) string) {
+		for bucket := range buckets {
+			conf := t.lruconf(bucket)
+			if conf == nil || !conf.LRUEnabled || t.pinned(bucket) {
+				continue
+			}
+			for mpath := range ctx.mountpaths.Available {
+				fschkwg.Add(1)
+				go t.oneLRU(filepath.Join(makePath(mpath), bucket), bucket, conf.HighWM, conf.LowWM, fschkwg, xlru)
+			}
+			fschkwg.Wait()
+		}
 	}
-	fschkwg.Wait()
+	bucketsLRU(t.lbmap.LBmap, makePathLocal)
+	bucketsLRU(t.lbmap.CBmap, makePathCloud)
 
 	// DEBUG
 	if glog.V(4) {
@@ -79,12 +101,23 @@ func (t *targetrunner) runLRU() {
 }
 
 // TODO: local-buckets-first LRU policy
-func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *xactLRU) {
+func (t *targetrunner) oneLRU(bucketdir, bucket string, hwm, lwm uint32, fschkwg *sync.WaitGroup, xlru *xactLRU) {
 	defer fschkwg.Done()
 	h := &maxheap{}
 	heap.Init(h)
 
-	toevict, err := getToEvict(bucketdir, ctx.config.LRU.HighWM, ctx.config.LRU.LowWM)
+	if _, err := os.Stat(bucketdir); err != nil && os.IsNotExist(err) {
+		return
+	}
+	var (
+		toevict int64
+		err     error
+	)
+	if bucket != "" {
+		toevict, err = getBucketToEvict(bucketdir, hwm, lwm)
+	} else {
+		toevict, err = getToEvict(bucketdir, hwm, lwm)
+	}
 	if err != nil {
 		return
 	}
@@ -92,7 +125,7 @@ func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *x
 
 	// init LRU context
 	var oldwork []*fileinfo
-	lctx := &lructx{totsize: toevict, xlru: xlru, h: h, oldwork: oldwork, t: t}
+	lctx := &lructx{totsize: toevict, xlru: xlru, h: h, oldwork: oldwork, t: t, rootdir: bucketdir, bucket: bucket}
 
 	if err = filepath.Walk(bucketdir, lctx.lruwalkfn); err != nil {
 		s := err.Error()
@@ -108,6 +141,46 @@ func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *x
 	}
 }
 
+// getBucketToEvict is getToEvict for a bucket with its own LRU configuration: the bucket's
+// watermarks apply to the space taken by the bucket itself (as a percentage of the mountpath's
+// capacity) - the mountpath's usage includes other buckets and would get this one emptied
+func getBucketToEvict(bucketdir string, hwm uint32, lwm uint32) (int64, error) {
+	statfs := syscall.Statfs_t{}
+	if err := syscall.Statfs(bucketdir, &statfs); err != nil {
+		glog.Errorf("Failed to statfs %q, err: %v", bucketdir, err)
+		return -1, err
+	}
+	var (
+		used     int64
+		capacity = int64(statfs.Blocks) * int64(statfs.Bsize)
+	)
+	walk := func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed in the meantime
+			}
+			return err
+		}
+		if !osfi.IsDir() {
+			used += osfi.Size()
+		}
+		return nil
+	}
+	if err := filepath.Walk(bucketdir, walk); err != nil {
+		glog.Errorf("Failed to traverse %q, err: %v", bucketdir, err)
+		return -1, err
+	}
+	if capacity == 0 {
+		return 0, nil
+	}
+	usedpct := used * 100 / capacity
+	glog.Infof("Bucket %s: used %d bytes (%d%%) hwm %d%% lwm %d%%", bucketdir, used, usedpct, hwm, lwm)
+	if usedpct < int64(hwm) {
+		return 0, nil // 0 to evict
+	}
+	return used - capacity*int64(lwm)/100, nil
+}
+
 // the walking callback is execited by the LRU xaction
 // (notice the receiver)
 func (lctx *lructx) lruwalkfn(fqn string, osfi os.FileInfo, err error) error {
@@ -116,6 +189,10 @@ func (lctx *lructx) lruwalkfn(fqn string, osfi os.FileInfo, err error) error {
 		return err
 	}
 	if osfi.Mode().IsDir() {
+		// skip the buckets that are pinned or have their own LRU configuration
+		if lctx.bucket == "" && filepath.Dir(fqn) == lctx.rootdir && lctx.t.ownlru(osfi.Name()) {
+			return filepath.SkipDir
+		}
 		return nil
 	}
 	var (
@@ -123,9 +200,15 @@ func (lctx *lructx) lruwalkfn(fqn string, osfi os.FileInfo, err error) error {
 		xlru, h       = lctx.xlru, lctx.h
 	)
 	if iswork, isold = lctx.t.isworkfile(fqn); iswork {
-		if !isold {
+		// interrupted downloads, including those of the previous target process, stay until expired
+		if ispartial(fqn) {
+			if !lctx.t.partialexpired(fqn, osfi) {
+				return nil
+			}
+		} else if !isold && !lctx.t.mpartexpired(fqn, osfi) {
 			return nil
 		}
+		isold = true
 	}
 	_, err = os.Stat(fqn)
 	if os.IsNotExist(err) {
@@ -236,7 +319,7 @@ func (t *targetrunner) lruEvict(fqn string) error {
 		return nil
 	}
 	uname := t.uname(bucket, objname)
-	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
 	defer t.rtnamemap.unlockname(uname, true)
 
 	if err := os.Remove(fqn); err != nil {
@@ -0,0 +1,493 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// implements cloudif in memory - for testing
+//
+// The mock Cloud is kept in the memory of the primary proxy and served to the
+// targets via /v1/mockcloud/bucket-name[/object-name], so that all targets see
+// the same Cloud regardless of cluster membership changes. Latency and failures
+// are injected by the targets as per ctx.config.MockCloud.
+//
+//======
+type mockimpl struct {
+	t *targetrunner
+}
+
+type mockobj struct {
+	data    []byte // immutable: PUT replaces the whole object
+	md5     string
+	version string
+	mtime   time.Time
+	cksum   cksumvalue        // dfc checksum, if provided at PUT time
+	meta    map[string]string // user-defined metadata
+}
+
+// the mock Cloud store: the primary proxy
+var mockcloud = struct {
+	sync.Mutex
+	buckets map[string]map[string]*mockobj
+	gen     int64
+}{buckets: make(map[string]map[string]*mockobj)}
+
+const mockHeaderMD5 = "HeaderDfcMockMD5" // the object's MD5 (intra-cluster)
+
+func init() {
+	registerCloudProvider(ProviderMock, func(t *targetrunner) cloudif { return &mockimpl{t} })
+}
+
+// mockinject delays the request and, with the configured probability, fails it
+func mockinject(op string) (errstr string, errcode int) {
+	mc := &ctx.config.MockCloud
+	if mc.Latency > 0 {
+		time.Sleep(mc.Latency)
+	}
+	if mc.ErrorPct > 0 && rand.Intn(100) < mc.ErrorPct {
+		errstr, errcode = fmt.Sprintf("mock Cloud: injected %s failure", op), http.StatusServiceUnavailable
+	}
+	return
+}
+
+// mockbody returns the reader of the object's data that, to inject a connection drop,
+// fails after mock_cloud.abort_after bytes
+func mockbody(data []byte) io.ReadCloser {
+	if n := ctx.config.MockCloud.AbortAfter; n > 0 && n < int64(len(data)) {
+		return ioutil.NopCloser(io.MultiReader(bytes.NewReader(data[:n]), &mockabort{}))
+	}
+	return ioutil.NopCloser(bytes.NewReader(data))
+}
+
+type mockabort struct{}
+
+func (*mockabort) Read([]byte) (int, error) {
+	return 0, errors.New("mock Cloud: injected connection drop")
+}
+
+// mockcall executes the mock Cloud request on the primary proxy
+func (mockimpl *mockimpl) mockcall(method, bucket, objname string, body io.Reader,
+	hdr http.Header) (resp *http.Response, errstr string, errcode int) {
+	t := mockimpl.t
+	url := ctx.config.Proxy.Primary.URL
+	if t.proxysi != nil && t.proxysi.DaemonID != "" {
+		url = t.proxysi.DirectURL
+	}
+	url += "/" + Rversion + "/" + Rmockcloud + "/" + bucket
+	if objname != "" {
+		url += "/" + objname
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		errstr = fmt.Sprintf("mock Cloud: failed to create %s request, err: %v", method, err)
+		return
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	if resp, err = t.httpclientLongTimeout.Do(req); err != nil {
+		errstr = fmt.Sprintf("mock Cloud: %s %s/%s failed, err: %v", method, bucket, objname, err)
+		return nil, errstr, http.StatusServiceUnavailable
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		errstr, errcode = strings.TrimSpace(string(b)), resp.StatusCode
+		if errstr == "" { // HEAD
+			errstr = fmt.Sprintf("mock Cloud: %s %s/%s failed, status %d", method, bucket, objname, errcode)
+		}
+		return nil, errstr, errcode
+	}
+	return
+}
+
+//==================
+//
+// bucket operations
+//
+//==================
+func (mockimpl *mockimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("listbucket %s", bucket)
+	}
+	if errstr, errcode = mockinject("listbucket"); errstr != "" {
+		return
+	}
+	msgjson, err := json.Marshal(msg)
+	assert(err == nil, err)
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodGet, bucket, "", bytes.NewReader(msgjson), nil)
+	if errstr != "" {
+		return
+	}
+	defer resp.Body.Close()
+	if jsbytes, err = ioutil.ReadAll(resp.Body); err != nil {
+		errstr = fmt.Sprintf("mock Cloud: failed to list bucket %s, err: %v", bucket, err)
+	}
+	return
+}
+
+func (mockimpl *mockimpl) headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headbucket %s", bucket)
+	}
+	if errstr, errcode = mockinject("headbucket"); errstr != "" {
+		return
+	}
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodHead, bucket, "", nil, nil)
+	if errstr != "" {
+		return
+	}
+	resp.Body.Close()
+	bucketprops = make(map[string]string)
+	if ctx.config.MockCloud.Versioning {
+		bucketprops[Versioning] = VersionCloud
+	} else {
+		bucketprops[Versioning] = VersionNone
+	}
+	return
+}
+
+func (mockimpl *mockimpl) getbucketnames() (buckets []string, errstr string, errcode int) {
+	if errstr, errcode = mockinject("getbucketnames"); errstr != "" {
+		return
+	}
+	buckets = make([]string, len(ctx.config.MockCloud.Buckets))
+	copy(buckets, ctx.config.MockCloud.Buckets)
+	return
+}
+
+//============
+//
+// object meta
+//
+//============
+func (mockimpl *mockimpl) headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int) {
+	if glog.V(4) {
+		glog.Infof("headobject %s/%s", bucket, objname)
+	}
+	if errstr, errcode = mockinject("headobject"); errstr != "" {
+		return
+	}
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodHead, bucket, objname, nil, nil)
+	if errstr != "" {
+		return
+	}
+	resp.Body.Close()
+	objmeta = make(map[string]string)
+	if version := resp.Header.Get(HeaderDfcObjVersion); version != "" {
+		objmeta["version"] = version
+	}
+	objmeta["size"] = strconv.FormatInt(resp.ContentLength, 10)
+	return
+}
+
+//=======================
+//
+// object data operations
+//
+//=======================
+func (mockimpl *mockimpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
+	if errstr, errcode = mockinject("GET"); errstr != "" {
+		return
+	}
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodGet, bucket, objname, nil, nil)
+	if errstr != "" {
+		return
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		errstr = fmt.Sprintf("mock Cloud: failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	var (
+		omd5  = resp.Header.Get(mockHeaderMD5)
+		cksum = newcksumvalue(resp.Header.Get(HeaderDfcChecksumType), resp.Header.Get(HeaderDfcChecksumVal))
+		size  = int64(len(data))
+	)
+	props = &objectProps{version: resp.Header.Get(HeaderDfcObjVersion)}
+	props.meta, _ = objmetaFromHeader(resp.Header)
+	dl := mockimpl.t.resumedownload(fqn, omd5, size)
+	if rangedcoldget(size) {
+		getrange := func(off, length int64) (io.ReadCloser, string) {
+			if errstr, _ := mockinject("GET range"); errstr != "" {
+				return nil, errstr
+			}
+			return mockbody(data[off : off+length]), ""
+		}
+		if props.nhobj, props.size, errstr = mockimpl.t.receiveranges(fqn, bucket, objname, omd5, cksum, dl,
+			nil, getrange); errstr != "" {
+			return
+		}
+	} else if _, props.nhobj, props.size, errstr = mockimpl.t.receiveresumable(fqn, false, bucket, objname, omd5,
+		cksum, dl, mockbody(data[dl.Offset:])); errstr != "" {
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("GET %s/%s", bucket, objname)
+	}
+	return
+}
+
+func (mockimpl *mockimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue,
+	meta map[string]string) (version string, errstr string, errcode int) {
+	if errstr, errcode = mockinject("PUT"); errstr != "" {
+		return
+	}
+	hdr := make(http.Header)
+	if ohash != nil {
+		htype, hval := ohash.get()
+		hdr.Set(HeaderDfcChecksumType, htype)
+		hdr.Set(HeaderDfcChecksumVal, hval)
+	}
+	objmetaToHeader(hdr, meta)
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodPut, bucket, objname, file, hdr)
+	if errstr != "" {
+		return
+	}
+	resp.Body.Close()
+	version = resp.Header.Get(HeaderDfcObjVersion)
+	if glog.V(4) {
+		glog.Infof("PUT %s/%s, version %s", bucket, objname, version)
+	}
+	return
+}
+
+func (mockimpl *mockimpl) deleteobj(bucket, objname string) (errstr string, errcode int) {
+	if errstr, errcode = mockinject("DELETE"); errstr != "" {
+		return
+	}
+	resp, errstr, errcode := mockimpl.mockcall(http.MethodDelete, bucket, objname, nil, nil)
+	if errstr != "" {
+		return
+	}
+	resp.Body.Close()
+	if glog.V(4) {
+		glog.Infof("DELETE %s/%s", bucket, objname)
+	}
+	return
+}
+
+//===========================================================================
+//
+// the mock Cloud store: the primary proxy
+//
+//===========================================================================
+
+// mockbucket returns the bucket's objects; must be called under lock
+func mockbucket(bucket string) (objs map[string]*mockobj, errstr string, errcode int) {
+	if objs = mockcloud.buckets[bucket]; objs != nil {
+		return
+	}
+	for _, name := range ctx.config.MockCloud.Buckets {
+		if name == bucket {
+			objs = make(map[string]*mockobj)
+			mockcloud.buckets[bucket] = objs
+			return
+		}
+	}
+	errstr, errcode = fmt.Sprintf("The bucket %s does not exist", bucket), http.StatusNotFound
+	return
+}
+
+// GET|HEAD|PUT|DELETE /Rversion/Rmockcloud/bucket-name[/object-name]
+func (p *proxyrunner) mockcloudhdlr(w http.ResponseWriter, r *http.Request) {
+	items := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"+Rversion+"/"+Rmockcloud+"/"), "/", 2)
+	bucket, objname := items[0], ""
+	if len(items) > 1 {
+		objname = items[1]
+	}
+	if bucket == "" || (objname == "" && r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.Error(w, "mock Cloud: invalid request "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	if objname == "" {
+		p.mockbucketop(w, r, bucket)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		mockcloud.Lock()
+		objs, errstr, errcode := mockbucket(bucket)
+		var obj *mockobj
+		if errstr == "" {
+			if obj = objs[objname]; obj == nil {
+				errstr, errcode = fmt.Sprintf("The object %s/%s does not exist", bucket, objname), http.StatusNotFound
+			}
+		}
+		mockcloud.Unlock()
+		if errstr != "" {
+			http.Error(w, errstr, errcode)
+			return
+		}
+		w.Header().Set(mockHeaderMD5, obj.md5)
+		if obj.version != "" {
+			w.Header().Set(HeaderDfcObjVersion, obj.version)
+		}
+		if obj.cksum != nil {
+			htype, hval := obj.cksum.get()
+			w.Header().Set(HeaderDfcChecksumType, htype)
+			w.Header().Set(HeaderDfcChecksumVal, hval)
+		}
+		objmetaToHeader(w.Header(), obj.meta)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("PUT %s/%s: failed to read, err: %v", bucket, objname, err), http.StatusBadRequest)
+			return
+		}
+		sum := md5.Sum(data)
+		obj := &mockobj{data: data, md5: hex.EncodeToString(sum[:]), mtime: time.Now()}
+		obj.cksum = newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
+		obj.meta, _ = objmetaFromHeader(r.Header)
+		mockcloud.Lock()
+		objs, errstr, errcode := mockbucket(bucket)
+		if errstr == "" {
+			if ctx.config.MockCloud.Versioning {
+				mockcloud.gen++
+				obj.version = strconv.FormatInt(mockcloud.gen, 10)
+			}
+			objs[objname] = obj
+		}
+		mockcloud.Unlock()
+		if errstr != "" {
+			http.Error(w, errstr, errcode)
+			return
+		}
+		if obj.version != "" {
+			w.Header().Set(HeaderDfcObjVersion, obj.version)
+		}
+	case http.MethodDelete:
+		mockcloud.Lock()
+		objs, errstr, errcode := mockbucket(bucket)
+		if errstr == "" {
+			if _, ok := objs[objname]; ok {
+				delete(objs, objname)
+			} else {
+				errstr, errcode = fmt.Sprintf("The object %s/%s does not exist", bucket, objname), http.StatusNotFound
+			}
+		}
+		mockcloud.Unlock()
+		if errstr != "" {
+			http.Error(w, errstr, errcode)
+		}
+	default:
+		invalhdlr(w, r)
+	}
+}
+
+// HEAD (exists?) and GET (list) the mock Cloud bucket
+func (p *proxyrunner) mockbucketop(w http.ResponseWriter, r *http.Request, bucket string) {
+	var msg GetMsg
+	if r.Method == http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, fmt.Sprintf("mock Cloud: failed to decode list request, err: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	pagesize := ctx.config.MockCloud.PageSize
+	if msg.GetPageSize != 0 && msg.GetPageSize < pagesize {
+		pagesize = msg.GetPageSize
+	}
+	mockcloud.Lock()
+	objs, errstr, errcode := mockbucket(bucket)
+	if errstr != "" || r.Method == http.MethodHead {
+		mockcloud.Unlock()
+		if errstr != "" {
+			http.Error(w, errstr, errcode)
+		}
+		return
+	}
+	names := make([]string, 0, len(objs))
+	for name := range objs {
+		if strings.HasPrefix(name, msg.GetPrefix) && !prevpage(name, msg.GetPageMarker, msg.GetDelimiter) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	// roll up into common prefixes: the names that share one are adjacent
+	prefixes := make(map[string]bool)
+	if msg.GetDelimiter != "" {
+		rolled := names[:0]
+		for _, name := range names {
+			if cp := commonprefix(name, msg.GetPrefix, msg.GetDelimiter); cp != "" {
+				if prefixes[cp] {
+					continue
+				}
+				prefixes[cp] = true
+				name = cp
+			}
+			rolled = append(rolled, name)
+		}
+		names = rolled
+	}
+	var reslist = BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
+	if len(names) > pagesize {
+		names = names[:pagesize]
+		reslist.PageMarker = names[pagesize-1]
+	}
+	for _, name := range names {
+		if prefixes[name] {
+			reslist.CommonPrefixes = append(reslist.CommonPrefixes, name)
+			continue
+		}
+		obj := objs[name]
+		entry := &BucketEntry{}
+		entry.Name = name
+		if strings.Contains(msg.GetProps, GetPropsSize) {
+			entry.Size = int64(len(obj.data))
+		}
+		if strings.Contains(msg.GetProps, GetPropsBucket) {
+			entry.Bucket = bucket
+		}
+		if strings.Contains(msg.GetProps, GetPropsCtime) {
+			switch msg.GetTimeFormat {
+			case "":
+				fallthrough
+			case RFC822:
+				entry.Ctime = obj.mtime.Format(time.RFC822)
+			default:
+				entry.Ctime = obj.mtime.Format(msg.GetTimeFormat)
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsChecksum) {
+			entry.Checksum = obj.md5
+		}
+		if strings.Contains(msg.GetProps, GetPropsVersion) {
+			entry.Version = obj.version
+		}
+		reslist.Entries = append(reslist.Entries, entry)
+	}
+	mockcloud.Unlock()
+	jsbytes, err := json.Marshal(reslist)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "mocklistbucket")
+}
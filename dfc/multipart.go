@@ -0,0 +1,413 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/golang/glog"
+)
+
+// Multipart upload:
+//   1. POST {"action": "mpinit"} /v1/objects/bucket-name/object-name => MultipartUpload (JSON)
+//   2. PUT /v1/objects/bucket-name/object-name?uploadid=<id>&partnum=<n>, n = 1, 2, ...
+//      (parts can be uploaded in any order and re-uploaded: each attempt is received
+//      into its own work file that replaces the previous one only when fully received)
+//   3. POST {"action": "mpcomplete", "name": <id>} /v1/objects/bucket-name/object-name
+//      or POST {"action": "mpabort", "name": <id>} ...
+//
+// Parts are stored as work files next to the object's fqn; upon completion the
+// parts are concatenated, in the part number order, into yet another work file
+// that then gets committed the same way as a regular PUT. Parts of abandoned
+// uploads are reclaimed by LRU once older than lru_config.multipart_expire_time.
+
+const (
+	mpartsep      = ".mpart."
+	mpartMaxParts = 10000
+)
+
+type mpart struct {
+	fqn   string
+	size  int64
+	nhobj cksumvalue
+}
+
+type mpupload struct {
+	id      string
+	bucket  string
+	objname string
+	started time.Time
+	touched time.Time // last part received
+	parts   map[int]*mpart
+}
+
+type mpuploads struct {
+	sync.Mutex
+	m   map[string]*mpupload
+	seq int64
+}
+
+func newmpuploads() *mpuploads {
+	return &mpuploads{m: make(map[string]*mpupload, 16)}
+}
+
+func (m *mpuploads) add(bucket, objname string) (upload *mpupload) {
+	m.Lock()
+	defer m.Unlock()
+	m.seq++
+	now := time.Now()
+	upload = &mpupload{
+		id:      strconv.FormatInt(now.UnixNano(), 16) + strconv.FormatInt(m.seq, 16),
+		bucket:  bucket,
+		objname: objname,
+		started: now,
+		touched: now,
+		parts:   make(map[int]*mpart, 16),
+	}
+	m.m[upload.id] = upload
+	return
+}
+
+// get returns the upload that must also belong to the bucket/objname
+func (m *mpuploads) get(id, bucket, objname string) (upload *mpupload, errstr string) {
+	m.Lock()
+	upload, ok := m.m[id]
+	m.Unlock()
+	if !ok {
+		errstr = fmt.Sprintf("Multipart upload %s does not exist (completed, aborted or expired?)", id)
+	} else if upload.bucket != bucket || upload.objname != objname {
+		errstr = fmt.Sprintf("Multipart upload %s belongs to %s/%s (not %s/%s)", id, upload.bucket, upload.objname, bucket, objname)
+		upload = nil
+	}
+	return
+}
+
+func (m *mpuploads) del(id string) (upload *mpupload) {
+	m.Lock()
+	upload = m.m[id]
+	delete(m.m, id)
+	m.Unlock()
+	return
+}
+
+// readd re-registers the upload that failed to complete, so that it can be completed
+// again (and its parts re-uploaded) or aborted
+func (m *mpuploads) readd(upload *mpupload) {
+	m.Lock()
+	upload.touched = time.Now()
+	m.m[upload.id] = upload
+	m.Unlock()
+}
+
+func (upload *mpupload) tostring() string {
+	return fmt.Sprintf("mpupload %s: %s/%s, parts %d, started %v", upload.id, upload.bucket, upload.objname,
+		len(upload.parts), upload.started.Format(time.StampMilli))
+}
+
+//===========================================================================
+//
+// multipart upload: handlers
+//
+//===========================================================================
+
+// POST {action: mpinit} /Rversion/Robjects/bucket-name/object-name
+func (t *targetrunner) mpinit(w http.ResponseWriter, r *http.Request) {
+	bucket, objname, ok := t.mpbckobj(w, r)
+	if !ok {
+		return
+	}
+	upload := t.mpuploads.add(bucket, objname)
+	jsbytes, err := json.Marshal(&MultipartUpload{UploadID: upload.id, Bucket: bucket, Objname: objname})
+	assert(err == nil, err)
+	if t.writeJSON(w, r, jsbytes, "mpinit") && bool(glog.V(3)) {
+		glog.Infof("Initiated %s", upload.tostring())
+	}
+}
+
+// PUT /Rversion/Robjects/bucket-name/object-name?uploadid=<id>&partnum=<n>
+func (t *targetrunner) mpputpart(w http.ResponseWriter, r *http.Request, bucket, objname, id string) {
+	started := time.Now()
+	query := r.URL.Query()
+	partnum, err := strconv.Atoi(query.Get(URLParamPartNum))
+	if err != nil || partnum < 1 || partnum > mpartMaxParts {
+		s := fmt.Sprintf("Invalid URL query parameter: %s=%s (expecting: 1 to %d)",
+			URLParamPartNum, query.Get(URLParamPartNum), mpartMaxParts)
+		t.invalmsghdlr(w, r, s)
+		return
+	}
+	upload, errstr := t.mpuploads.get(id, bucket, objname)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
+	t.mpuploads.Lock()
+	t.mpuploads.seq++
+	attempt := t.mpuploads.seq
+	t.mpuploads.Unlock()
+	partfqn := t.mpartfqn(bucket, objname, id, partnum, attempt)
+	hdhobj := newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
+	_, nhobj, written, errstr := t.receive(partfqn, false /*inmem*/, bucket, objname, "", hdhobj, r.Body)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	t.mpuploads.Lock()
+	if _, ok := t.mpuploads.m[id]; !ok {
+		t.mpuploads.Unlock()
+		if err := os.Remove(partfqn); err != nil {
+			glog.Errorf("Failed to remove %s, err: %v", partfqn, err)
+		}
+		s := fmt.Sprintf("Multipart upload %s was completed or aborted while receiving part %d", id, partnum)
+		t.invalmsghdlr(w, r, s, http.StatusConflict)
+		return
+	}
+	prev := upload.parts[partnum]
+	upload.parts[partnum] = &mpart{fqn: partfqn, size: written, nhobj: nhobj}
+	upload.touched = time.Now()
+	t.mpuploads.Unlock()
+	if prev != nil {
+		if err := os.Remove(prev.fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to remove re-uploaded part %d (%s), err: %v", partnum, prev.fqn, err)
+		}
+	}
+
+	if nhobj != nil {
+		htype, hval := nhobj.get()
+		w.Header().Add(HeaderDfcChecksumType, htype)
+		w.Header().Add(HeaderDfcChecksumVal, hval)
+	}
+	if glog.V(4) {
+		glog.Infof("PUT part %d: %s/%s (upload %s), %.2f MB, %d µs", partnum, bucket, objname, id,
+			float64(written)/MiB, time.Since(started)/1000)
+	}
+}
+
+// POST {action: mpcomplete, name: <id>} /Rversion/Robjects/bucket-name/object-name
+func (t *targetrunner) mpcomplete(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	started := time.Now()
+	bucket, objname, ok := t.mpbckobj(w, r)
+	if !ok {
+		return
+	}
+	upload, errstr := t.mpuploads.get(msg.Name, bucket, objname)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
+	// parts must be contiguous: 1, 2, ..., N; if so, claim the upload - once removed
+	// from the registry, its parts can no longer be (re-)uploaded; the upload that fails
+	// to complete is registered again and keeps its parts
+	t.mpuploads.Lock()
+	if t.mpuploads.m[upload.id] != upload {
+		t.mpuploads.Unlock()
+		t.invalmsghdlr(w, r, fmt.Sprintf("Multipart upload %s completed or aborted concurrently", upload.id))
+		return
+	}
+	nums := make([]int, 0, len(upload.parts))
+	for num := range upload.parts {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	if len(nums) == 0 || nums[len(nums)-1] != len(nums) {
+		s := fmt.Sprintf("Cannot complete %s: missing parts (have %v)", upload.tostring(), nums)
+		t.mpuploads.Unlock()
+		t.invalmsghdlr(w, r, s)
+		return
+	}
+	parts := make([]*mpart, len(nums))
+	for i, num := range nums {
+		parts[i] = upload.parts[num]
+	}
+	delete(t.mpuploads.m, upload.id)
+	t.mpuploads.Unlock()
+
+	fqn := t.fqn(bucket, objname)
+	putfqn := t.fqn2workfile(fqn)
+	nhobj, size, errstr := t.mpassemble(upload, parts, putfqn)
+	if errstr != "" {
+		t.mpuploads.readd(upload)
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	props := &objectProps{nhobj: nhobj, size: size}
+	if errstr, errcode := t.putCommit(bucket, objname, putfqn, fqn, props, false /*rebalance*/); errstr != "" {
+		t.mpuploads.readd(upload)
+		if errcode == 0 {
+			t.invalmsghdlr(w, r, errstr)
+		} else {
+			t.invalmsghdlr(w, r, errstr, errcode)
+		}
+		return
+	}
+	t.mpcleanup(upload)
+	if nhobj != nil {
+		htype, hval := nhobj.get()
+		w.Header().Add(HeaderDfcChecksumType, htype)
+		w.Header().Add(HeaderDfcChecksumVal, hval)
+	}
+	if props.version != "" {
+		w.Header().Add(HeaderDfcObjVersion, props.version)
+	}
+	lat := int64(time.Since(started) / 1000)
+	t.statsif.addMany("numput", int64(1), "putlatency", lat)
+	glog.Infof("Completed %s, %.2f MB, %d µs", upload.tostring(), float64(size)/MiB, lat)
+}
+
+// POST {action: mpabort, name: <id>} /Rversion/Robjects/bucket-name/object-name
+func (t *targetrunner) mpabort(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	bucket, objname, ok := t.mpbckobj(w, r)
+	if !ok {
+		return
+	}
+	upload, errstr := t.mpuploads.get(msg.Name, bucket, objname)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
+	if t.mpuploads.del(upload.id) == nil {
+		return
+	}
+	t.mpcleanup(upload)
+	glog.Infof("Aborted %s", upload.tostring())
+}
+
+//===========================================================================
+//
+// multipart upload: helpers
+//
+//===========================================================================
+
+func (t *targetrunner) mpbckobj(w http.ResponseWriter, r *http.Request) (bucket, objname string, ok bool) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname = apitems[0], strings.Join(apitems[1:], "/")
+	if _, errstr, errcode := t.checkLocalQueryParameter(bucket, r); errstr != "" {
+		t.invalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	ok = true
+	return
+}
+
+// concatenate parts (numbered 1, 2, ...) into putfqn and compute the resulting checksum
+func (t *targetrunner) mpassemble(upload *mpupload, parts []*mpart, putfqn string) (nhobj cksumvalue, size int64, errstr string) {
+	file, err := CreateFile(putfqn)
+	if err != nil {
+		t.runFSKeeper(fmt.Errorf("%s", putfqn))
+		errstr = fmt.Sprintf("Failed to create %s, err: %v", putfqn, err)
+		return
+	}
+	var (
+		writer io.Writer = file
+		xx     hash.Hash64
+	)
+	if t.cksumconf(upload.bucket).Checksum != ChecksumNone {
+		xx = xxhash.New64()
+		writer = io.MultiWriter(file, xx)
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer func() {
+		slab.free(buf)
+		if errstr == "" {
+			return
+		}
+		if err := os.Remove(putfqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Nested error %s => (remove %s => err: %v)", errstr, putfqn, err)
+		}
+	}()
+	for i, part := range parts {
+		num := i + 1
+		partfile, err := os.Open(part.fqn)
+		if err != nil {
+			errstr = fmt.Sprintf("Failed to open part %d (%s), err: %v", num, part.fqn, err)
+			file.Close()
+			return
+		}
+		written, err := io.CopyBuffer(writer, partfile, buf)
+		partfile.Close()
+		if err != nil || written != part.size {
+			t.runFSKeeper(fmt.Errorf("%s", putfqn))
+			errstr = fmt.Sprintf("Failed to append part %d (%s) to %s: %d/%d, err: %v",
+				num, part.fqn, putfqn, written, part.size, err)
+			file.Close()
+			return
+		}
+		size += written
+	}
+	if err = file.Close(); err != nil {
+		errstr = fmt.Sprintf("Failed to close %s, err: %v", putfqn, err)
+		return
+	}
+	if xx != nil {
+		hashInBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(hashInBytes, xx.Sum64())
+		nhobj = newcksumvalue(ChecksumXXHash, hex.EncodeToString(hashInBytes))
+	}
+	return
+}
+
+func (t *targetrunner) mpcleanup(upload *mpupload) {
+	for num, part := range upload.parts {
+		if err := os.Remove(part.fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to remove part %d (%s) of %s, err: %v", num, part.fqn, upload.tostring(), err)
+		}
+	}
+}
+
+// part's work file: <dir>/.~~~.<base>.mpart.<upload-id>.<part-num>.<attempt>.<pid>
+func (t *targetrunner) mpartfqn(bucket, objname, id string, partnum int, attempt int64) string {
+	dir, base := filepath.Split(t.fqn(bucket, objname))
+	return dir + workfileprefix + base + mpartsep + id + "." + strconv.Itoa(partnum) + "." +
+		strconv.FormatInt(attempt, 16) + "." + t.uxprocess.spid
+}
+
+// mpartexpired returns true if fqn is a part of an upload that hasn't been
+// updated for longer than multipart_expire_time; the upload gets dropped
+func (t *targetrunner) mpartexpired(fqn string, osfi os.FileInfo) bool {
+	base := filepath.Base(fqn)
+	i := strings.LastIndex(base, mpartsep)
+	if i < 0 {
+		return false
+	}
+	id := base[i+len(mpartsep):] // <upload-id>.<part-num>.<attempt>.<pid>
+	if i = strings.Index(id, "."); i < 0 {
+		return false
+	}
+	id = id[:i]
+	expire := ctx.config.LRU.MultipartExpireTime
+	if time.Since(osfi.ModTime()) < expire {
+		return false
+	}
+	t.mpuploads.Lock()
+	upload, ok := t.mpuploads.m[id]
+	if ok && time.Since(upload.touched) < expire {
+		t.mpuploads.Unlock()
+		return false
+	}
+	delete(t.mpuploads.m, id)
+	t.mpuploads.Unlock()
+	if ok {
+		glog.Infof("Expired %s", upload.tostring())
+	}
+	return true
+}
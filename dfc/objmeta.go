@@ -0,0 +1,87 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// user-defined object metadata: X-Dfc-Meta-<key>: <value> headers that are
+// stored with the object (JSON-encoded, in a single xattr) and propagated
+// to and from the Cloud provider's native metadata
+//
+//======
+
+// objmetaFromHeader returns the user-defined metadata carried by the request
+// headers, keyed by the lowercase names with the prefix trimmed
+func objmetaFromHeader(hdr http.Header) (meta map[string]string, errstr string) {
+	for name, values := range hdr {
+		if !strings.HasPrefix(name, HeaderDfcMetaPrefix) || len(name) == len(HeaderDfcMetaPrefix) {
+			continue
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[strings.ToLower(name[len(HeaderDfcMetaPrefix):])] = strings.Join(values, ",")
+	}
+	if meta != nil {
+		jsbytes, err := json.Marshal(meta)
+		assert(err == nil, err)
+		if len(jsbytes) >= maxAttrSize {
+			errstr = fmt.Sprintf("User-defined metadata is too large: %d bytes (max %d)", len(jsbytes), maxAttrSize-1)
+		}
+	}
+	return
+}
+
+// objmetaToHeader adds the user-defined metadata to the (response or request) headers
+func objmetaToHeader(hdr http.Header, meta map[string]string) {
+	for k, v := range meta {
+		hdr.Set(HeaderDfcMetaPrefix+k, v)
+	}
+}
+
+// objmeta returns the user-defined metadata of the object stored at fqn, if any
+func (t *targetrunner) objmeta(fqn string) (meta map[string]string) {
+	jsbytes, errstr := Getxattr(fqn, xattrObjMeta)
+	if errstr != "" {
+		glog.Errorln(errstr)
+		return
+	}
+	if jsbytes == nil {
+		return
+	}
+	if err := json.Unmarshal(jsbytes, &meta); err != nil {
+		glog.Errorf("Failed to unmarshal %q xattr %s, err: %v", fqn, xattrObjMeta, err)
+	}
+	return
+}
+
+// cloudmeta converts Cloud object's native metadata into user-defined metadata,
+// skipping DFC's own entries (checksum)
+func cloudmeta(md map[string]string, skip ...string) (meta map[string]string) {
+outer:
+	for k, v := range md {
+		k = strings.ToLower(k)
+		for _, s := range skip {
+			if k == strings.ToLower(s) {
+				continue outer
+			}
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[k] = v
+	}
+	return
+}
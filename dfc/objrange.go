@@ -0,0 +1,160 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerRange        = "Range"
+	headerContentRange = "Content-Range"
+	rangeUnitBytes     = "bytes="
+	maxHTTPRanges      = 16 // more (non-overlapping) ranges than that - the entire object is sent
+)
+
+// httpRange is a single byte range [start, start+length) of an object
+type httpRange struct {
+	start, length int64
+}
+
+func (hr *httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", hr.start, hr.start+hr.length-1, size)
+}
+
+// parseHTTPRange parses the Range header value (RFC 7233), e.g. "bytes=0-499,1000-", against
+// the object size. Overlapping and adjacent ranges are coalesced; if there are still more
+// than maxHTTPRanges, the header is ignored (no ranges, no error). Returns non-empty errstr
+// if the header is malformed or none of the ranges can be satisfied.
+func parseHTTPRange(s string, size int64) (ranges []httpRange, errstr string) {
+	if !strings.HasPrefix(s, rangeUnitBytes) {
+		errstr = fmt.Sprintf("Invalid range %q: expecting %q units", s, rangeUnitBytes)
+		return
+	}
+	for _, spec := range strings.Split(s[len(rangeUnitBytes):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			errstr = fmt.Sprintf("Invalid range %q", spec)
+			return
+		}
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		var hr httpRange
+		if first == "" {
+			// suffix range: the last N bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				errstr = fmt.Sprintf("Invalid range %q", spec)
+				return
+			}
+			if n > size {
+				n = size
+			}
+			hr.start, hr.length = size-n, n
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				errstr = fmt.Sprintf("Invalid range %q", spec)
+				return
+			}
+			if start >= size {
+				// unsatisfiable - skip it
+				continue
+			}
+			hr.start = start
+			if last == "" {
+				hr.length = size - start
+			} else {
+				end, err := strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					errstr = fmt.Sprintf("Invalid range %q", spec)
+					return
+				}
+				if end >= size {
+					end = size - 1
+				}
+				hr.length = end - start + 1
+			}
+		}
+		if hr.length > 0 {
+			ranges = append(ranges, hr)
+		}
+	}
+	if len(ranges) == 0 {
+		errstr = fmt.Sprintf("Range %q not satisfiable (object size %d)", s, size)
+		return
+	}
+	if ranges = coalesceranges(ranges); len(ranges) > maxHTTPRanges {
+		ranges = nil
+	}
+	return
+}
+
+// coalesceranges sorts the ranges by offset and merges those that overlap or are adjacent
+func coalesceranges(ranges []httpRange) []httpRange {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	merged := ranges[:1]
+	for _, hr := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if hr.start > last.start+last.length {
+			merged = append(merged, hr)
+			continue
+		}
+		if end := hr.start + hr.length; end > last.start+last.length {
+			last.length = end - last.start
+		}
+	}
+	return merged
+}
+
+// sendranges writes 206 (Partial Content) response: a single range is sent as is,
+// multiple ranges - as multipart/byteranges
+func (t *targetrunner) sendranges(w http.ResponseWriter, file *os.File, ranges []httpRange, size int64,
+	buf []byte) (written int64, err error) {
+	if len(ranges) == 1 {
+		hr := &ranges[0]
+		w.Header().Set(headerContentRange, hr.contentRange(size))
+		w.Header().Set("Content-Length", strconv.FormatInt(hr.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		return io.CopyBuffer(w, io.NewSectionReader(file, hr.start, hr.length), buf)
+	}
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+	for i := range ranges {
+		hr := &ranges[i]
+		var part io.Writer
+		part, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":     {"application/octet-stream"},
+			headerContentRange: {hr.contentRange(size)},
+		})
+		if err != nil {
+			return
+		}
+		var n int64
+		n, err = io.CopyBuffer(part, io.NewSectionReader(file, hr.start, hr.length), buf)
+		written += n
+		if err != nil {
+			return
+		}
+	}
+	err = mw.Close()
+	return
+}
@@ -8,6 +8,7 @@ package dfc
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -69,7 +70,7 @@ func (p *proxyrunner) run() error {
 
 	p.xactinp = newxactinp()
 	// local (aka cache-only) buckets
-	p.lbmap = &lbmap{LBmap: make(map[string]string)}
+	p.lbmap = &lbmap{LBmap: make(map[string]BucketProps)}
 	lbpathname := filepath.Join(p.confdir, lbname)
 	p.lbmap.lock()
 	if localLoad(lbpathname, p.lbmap) != nil {
@@ -131,6 +132,11 @@ func (p *proxyrunner) run() error {
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rcluster+"/", p.clusterhdlr) // FIXME
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rhealth, p.httphealth)
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rvote+"/", p.votehdlr)
+	p.httprunner.registerhdlr("/"+Rs3, p.s3hdlr)
+	p.httprunner.registerhdlr("/"+Rs3+"/", p.s3hdlr)
+	if ctx.config.CloudProvider == ProviderMock {
+		p.httprunner.registerhdlr("/"+Rversion+"/"+Rmockcloud+"/", p.mockcloudhdlr)
+	}
 	p.httprunner.registerhdlr("/", invalhdlr)
 	glog.Infof("Proxy %s is ready, primary=%t", p.si.DaemonID, p.primary)
 	glog.Flush()
@@ -205,6 +211,8 @@ func (p *proxyrunner) buckethdlr(w http.ResponseWriter, r *http.Request) {
 		p.httpbckdelete(w, r)
 	case http.MethodPost:
 		p.httpbckpost(w, r)
+	case http.MethodPut:
+		p.httpbckput(w, r)
 	case http.MethodHead:
 		p.httpbckhead(w, r)
 	default:
@@ -223,6 +231,8 @@ func (p *proxyrunner) objecthdlr(w http.ResponseWriter, r *http.Request) {
 		p.httpobjdelete(w, r)
 	case http.MethodPost:
 		p.httpobjpost(w, r)
+	case http.MethodHead:
+		p.httpobjhead(w, r)
 	default:
 		invalhdlr(w, r)
 	}
@@ -287,6 +297,15 @@ func (p *proxyrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 		p.invalmsghdlr(w, r, errstr)
 		return
 	}
+	switch what := r.URL.Query().Get(URLParamWhat); what {
+	case "":
+	case GetWhatLocate:
+		p.httpobjlocate(w, r, bucket, objname, si)
+		return
+	default:
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid URL query parameter: %s=%s (expecting: '' | %s)", URLParamWhat, what, GetWhatLocate))
+		return
+	}
 	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, URLParamLocal, p.islocalBucket(bucket))
 	if glog.V(4) {
 		glog.Infof("%s %s/%s => %s", r.Method, bucket, objname, si.DaemonID)
@@ -317,6 +336,11 @@ func (p *proxyrunner) httpobjput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, URLParamLocal, p.islocalBucket(bucket))
+	if query := r.URL.Query(); query.Get(URLParamUploadID) != "" {
+		// multipart upload part
+		redirecturl += fmt.Sprintf("&%s=%s&%s=%s", URLParamUploadID, query.Get(URLParamUploadID),
+			URLParamPartNum, query.Get(URLParamPartNum))
+	}
 	if glog.V(4) {
 		glog.Infof("%s %s/%s => %s", r.Method, bucket, objname, si.DaemonID)
 	}
@@ -395,14 +419,36 @@ func (p *proxyrunner) httpbckpost(w http.ResponseWriter, r *http.Request) {
 		if !p.checkPrimaryProxy("create local bucket", w, r) {
 			return
 		}
+		var props BucketProps
+		if msg.Value != nil {
+			jsbytes, err := json.Marshal(msg.Value)
+			assert(err == nil, err)
+			if err = json.Unmarshal(jsbytes, &props); err != nil {
+				s := fmt.Sprintf("Failed to unmarshal local bucket %s properties, err: %v", lbucket, err)
+				p.invalmsghdlr(w, r, s)
+				return
+			}
+		}
+		p.smap.lock()
+		errstr := props.validate(p.smap.count())
+		p.smap.unlock()
+		if errstr != "" {
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
 		p.lbmap.lock()
 		defer p.lbmap.unlock()
-		if !p.lbmap.add(lbucket) {
+		if !p.lbmap.add(lbucket, props) {
 			s := fmt.Sprintf("Local bucket %s already exists", lbucket)
 			p.invalmsghdlr(w, r, s)
 			return
 		}
 		p.synclbmap(w, r)
+	case ActRenameLB:
+		if !p.checkPrimaryProxy("rename local bucket", w, r) {
+			return
+		}
+		p.renameLocalBucket(w, r, lbucket, msg.Name)
 	case ActSyncLB:
 		if !p.checkPrimaryProxy("synchronize LBmap", w, r) {
 			return
@@ -410,7 +456,7 @@ func (p *proxyrunner) httpbckpost(w http.ResponseWriter, r *http.Request) {
 		p.lbmap.lock()
 		defer p.lbmap.unlock()
 		p.synclbmap(w, r)
-	case ActPrefetch:
+	case ActPrefetch, ActCopy, ActMove:
 		p.actionlistrange(w, r, &msg)
 	default:
 		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
@@ -418,6 +464,31 @@ func (p *proxyrunner) httpbckpost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// PUT { action } /v1/buckets/bucket-name
+func (p *proxyrunner) httpbckput(w http.ResponseWriter, r *http.Request) {
+	var msg ActionMsg
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 1, Rversion, Rbuckets); apitems == nil {
+		return
+	}
+	bucket := apitems[0]
+	if strings.Contains(bucket, "/") {
+		s := fmt.Sprintf("Invalid bucket name %s (contains '/')", bucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if p.readJSON(w, r, &msg) != nil {
+		return
+	}
+	switch msg.Action {
+	case ActSetProps:
+		p.setbucketprops(w, r, bucket, &msg)
+	default:
+		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
+		p.invalmsghdlr(w, r, s)
+	}
+}
+
 // POST { action } /v1/objects/bucket-name
 func (p *proxyrunner) httpobjpost(w http.ResponseWriter, r *http.Request) {
 	var msg ActionMsg
@@ -438,6 +509,12 @@ func (p *proxyrunner) httpobjpost(w http.ResponseWriter, r *http.Request) {
 	case ActRename:
 		p.filrename(w, r, &msg)
 		return
+	case ActCopy, ActMove:
+		p.filcopy(w, r, &msg)
+		return
+	case ActMultipartInit, ActMultipartComplete, ActMultipartAbort:
+		p.multipart(w, r, &msg)
+		return
 	default:
 		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
 		p.invalmsghdlr(w, r, s)
@@ -469,6 +546,30 @@ func (p *proxyrunner) httpbckhead(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 }
 
+// HEAD /Rversion/Robjects/bucket-name/object-name
+func (p *proxyrunner) httpobjhead(w http.ResponseWriter, r *http.Request) {
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], apitems[1]
+	if strings.Contains(bucket, "/") {
+		s := fmt.Sprintf("Invalid bucket name %s (contains '/')", bucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	si, errstr := hrwTarget(bucket+"/"+objname, p.smap)
+	if errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, URLParamLocal, p.islocalBucket(bucket))
+	if glog.V(3) {
+		glog.Infof("%s %s/%s => %s", r.Method, bucket, objname, si.DaemonID)
+	}
+	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+}
+
 //====================================================================================
 //
 // supporting methods and misc
@@ -664,6 +765,7 @@ func (p *proxyrunner) collectCachedFileList(bucket string, fileList *BucketList,
 	return
 }
 
+// getLocalBucketObjects lists the bucket on all targets and k-way merges the results (see listsort)
 func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (allentries *BucketList, err error) {
 	type targetReply struct {
 		resp *bucketResp
@@ -677,6 +779,11 @@ func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (
 	if err = json.Unmarshal(listmsgjson, msg); err != nil {
 		return
 	}
+	ls, errstr := parsesort(msg)
+	if errstr != "" {
+		err = errors.New(errstr)
+		return
+	}
 	pageSize := DefaultPageSize
 	if msg.GetPageSize != 0 {
 		pageSize = msg.GetPageSize
@@ -685,6 +792,16 @@ func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (
 	if pageSize > MaxPageSize {
 		glog.Warningf("Page size(%d) for local bucket %s exceeds the limit(%d)", msg.GetPageSize, bucket, MaxPageSize)
 	}
+	// to merge by time, have the targets return precise times - formatted as requested upon merge
+	tmsg := *msg
+	if ls.bytime() {
+		tmsg.GetTimeFormat = time.RFC3339Nano
+		if !strings.Contains(tmsg.GetProps, ls.field) {
+			tmsg.GetProps += "," + ls.field
+		}
+		listmsgjson, err = json.Marshal(&tmsg)
+		assert(err == nil, err)
+	}
 
 	chresult := make(chan *targetReply, len(p.smap.Smap))
 	wg := &sync.WaitGroup{}
@@ -703,7 +820,7 @@ func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (
 	close(chresult)
 
 	// combine results
-	allentries = &BucketList{Entries: make([]*BucketEntry, 0, pageSize)}
+	pages := make([]*BucketList, 0, len(p.smap.Smap))
 	for r := range chresult {
 		if r.err != nil {
 			err = r.err
@@ -718,32 +835,35 @@ func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (
 		if err = json.Unmarshal(r.resp.outjson, &bucketList); err != nil {
 			return
 		}
-
-		if len(bucketList.Entries) == 0 {
-			continue
-		}
-
-		allentries.Entries = append(allentries.Entries, bucketList.Entries...)
+		pages = append(pages, bucketList)
 	}
-
-	// return the list always sorted in alphabetical order
-	entryLess := func(i, j int) bool {
-		return allentries.Entries[i].Name < allentries.Entries[j].Name
+	allentries = ls.merge(pages, pageSize, tmsg.GetTimeFormat)
+	if tmsg != *msg {
+		retimeEntries(allentries.Entries, msg)
 	}
-	sort.Slice(allentries.Entries, entryLess)
+	return allentries, nil
+}
 
-	// shrink the result to `pageSize` entries. If the page is full than
-	// mark the result incomplete by setting PageMarker
-	if len(allentries.Entries) >= pageSize {
-		for i := pageSize; i < len(allentries.Entries); i++ {
-			allentries.Entries[i] = nil
+// retimeEntries formats the times of the merged entries as per the original request
+func retimeEntries(entries []*BucketEntry, msg *GetMsg) {
+	format := msg.GetTimeFormat
+	if format == "" {
+		format = RFC822
+	}
+	retime := func(s string, keep bool) string {
+		if !keep {
+			return ""
 		}
-
-		allentries.Entries = allentries.Entries[:pageSize]
-		allentries.PageMarker = allentries.Entries[pageSize-1].Name
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return s
+		}
+		return t.Format(format)
+	}
+	for _, entry := range entries {
+		entry.Atime = retime(entry.Atime, strings.Contains(msg.GetProps, GetPropsAtime))
+		entry.Ctime = retime(entry.Ctime, strings.Contains(msg.GetProps, GetPropsCtime))
 	}
-
-	return allentries, nil
 }
 
 func (p *proxyrunner) getCloudBucketObjects(bucket string, listmsgjson []byte) (allentries *BucketList, err error) {
@@ -761,6 +881,14 @@ func (p *proxyrunner) getCloudBucketObjects(bucket string, listmsgjson []byte) (
 	if msg.GetPageSize > MaxPageSize {
 		glog.Warningf("Page size(%d) for cloud bucket %s exceeds the limit(%d)", msg.GetPageSize, bucket, MaxPageSize)
 	}
+	// Cloud providers list objects by name only
+	if ls, errstr := parsesort(&msg); errstr != "" || !ls.isdefault() {
+		if errstr == "" {
+			errstr = fmt.Sprintf("Cloud bucket %s cannot be listed sorted as %q", bucket, msg.GetSort)
+		}
+		err = errors.New(errstr)
+		return
+	}
 
 	// first, get the cloud object list from a random target
 	for _, si := range p.smap.Smap {
@@ -813,6 +941,22 @@ func (p *proxyrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 		p.invalmsghdlr(w, r, s)
 		return
 	}
+	// bucket properties rather than the list of objects
+	if len(listmsgjson) > 0 {
+		var msg GetMsg
+		if err = json.Unmarshal(listmsgjson, &msg); err == nil && msg.GetWhat == GetWhatProps {
+			p.getbucketprops(w, r, bucket)
+			return
+		}
+		if err == nil && msg.GetWhat == GetWhatSummary {
+			p.bucketsummary(w, r, bucket, listmsgjson)
+			return
+		}
+		if _, errstr := newlistfilter(&msg); err == nil && errstr != "" {
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
+	}
 
 	if p.islocalBucket(bucket) {
 		allentries, err = p.getLocalBucketObjects(bucket, listmsgjson)
@@ -873,6 +1017,144 @@ func (p *proxyrunner) deleteLocalBucket(w http.ResponseWriter, r *http.Request,
 	p.synclbmap(w, r)
 }
 
+// renameLocalBucket renames the local bucket in two phases: first, every target renames
+// its bucket directories and installs the new lbmap; second, the targets migrate
+// the objects that, given the new name, belong elsewhere (the name is a part of the HRW key)
+func (p *proxyrunner) renameLocalBucket(w http.ResponseWriter, r *http.Request, lbucket, newbucket string) {
+	if newbucket == "" || newbucket == lbucket || strings.Contains(newbucket, "/") {
+		s := fmt.Sprintf("Invalid new name %q of the local bucket %s", newbucket, lbucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	_, ok := p.lbmap.LBmap[lbucket]
+	if !ok {
+		s := fmt.Sprintf("Local bucket %s does not exist", lbucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if !p.lbmap.rename(lbucket, newbucket) {
+		s := fmt.Sprintf("Local bucket %s already exists", newbucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+
+	// 1st phase: rename and install the new lbmap
+	msg := ActionMsg{Action: ActRenameLB, Name: newbucket, Value: p.lbmap}
+	jsbytes, err := json.Marshal(msg)
+	assert(err == nil, err)
+	if errstr := p.calltargets(lbucket, http.MethodPost, jsbytes, true); errstr != "" {
+		// roll back: the targets that have renamed the bucket rename it back
+		// upon receiving the newer lbmap (see setlbmap)
+		p.lbmap.rename(newbucket, lbucket)
+		p.synclbmap(w, r)
+		s := fmt.Sprintf("Failed to rename local bucket %s => %s: %s", lbucket, newbucket, errstr)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	p.synclbmap(w, r)
+	// 2nd phase: migrate
+	msg.Value = nil
+	jsbytes, err = json.Marshal(msg)
+	assert(err == nil, err)
+	if errstr := p.calltargets(lbucket, http.MethodPost, jsbytes, false); errstr != "" {
+		glog.Errorf("Failed to start migrating objects of the local bucket %s: %s", newbucket, errstr)
+	}
+	glog.Infof("Renamed local bucket %s => %s", lbucket, newbucket)
+}
+
+// calltargets sends the bucket action to all targets in parallel, with
+// ?prepare= to tell the phases apart, and returns the first error if any
+func (p *proxyrunner) calltargets(bucket, method string, jsbytes []byte, prepare bool) string {
+	p.smap.lock()
+	defer p.smap.unlock()
+	errch := make(chan string, len(p.smap.Smap))
+	wg := &sync.WaitGroup{}
+	for _, si := range p.smap.Smap {
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			url := fmt.Sprintf("%s/%s/%s/%s?%s=%t", si.DirectURL, Rversion, Rbuckets, bucket, URLParamPrepare, prepare)
+			if _, err, errstr, errcode := p.call(si, url, method, jsbytes); err != nil {
+				errch <- fmt.Sprintf("%s: %v (%d: %s)", si.DaemonID, err, errcode, errstr)
+			}
+		}(si)
+	}
+	wg.Wait()
+	close(errch)
+	return <-errch
+}
+
+// setbucketprops replaces the bucket's overrides of the cluster-wide configuration;
+// erasure coding and replication are set at creation time and cannot be changed
+func (p *proxyrunner) setbucketprops(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	if !p.checkPrimaryProxy("set bucket properties", w, r) {
+		return
+	}
+	var props BucketProps
+	if msg.Value != nil {
+		jsbytes, err := json.Marshal(msg.Value)
+		assert(err == nil, err)
+		if err = json.Unmarshal(jsbytes, &props); err != nil {
+			s := fmt.Sprintf("Failed to unmarshal bucket %s properties, err: %v", bucket, err)
+			p.invalmsghdlr(w, r, s)
+			return
+		}
+	}
+	if errstr := props.validateOverrides(); errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	curprops, ok := p.lbmap.LBmap[bucket]
+	if !ok {
+		p.setcloudbucketprops(w, r, bucket, props)
+		return
+	}
+	if (props.EC != ECConf{} && props.EC != curprops.EC) || (props.Copies != 0 && props.Copies != curprops.Copies) {
+		s := fmt.Sprintf("Cannot change erasure coding or replication of the existing local bucket %s", bucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	props.EC, props.Copies, props.CopiesAsync = curprops.EC, curprops.Copies, curprops.CopiesAsync
+	p.lbmap.set(bucket, props)
+	p.synclbmap(w, r)
+}
+
+// setcloudbucketprops adds the Cloud bucket to the cluster-wide bucket map or,
+// if there are no overrides, removes it; requires the caller to lock p.lbmap
+func (p *proxyrunner) setcloudbucketprops(w http.ResponseWriter, r *http.Request, bucket string, props BucketProps) {
+	if (props.EC != ECConf{}) || props.Copies != 0 || props.CopiesAsync {
+		s := fmt.Sprintf("Erasure coding and replication are supported for local buckets only (bucket %s)", bucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if props.NextTierURL != "" {
+		s := fmt.Sprintf("Next tier is supported for local buckets only (bucket %s)", bucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if p.lbmap.setcloud(bucket, props) {
+		p.synclbmap(w, r)
+	}
+}
+
+// getbucketprops: GET {what: props} /v1/buckets/bucket-name
+func (p *proxyrunner) getbucketprops(w http.ResponseWriter, r *http.Request, bucket string) {
+	p.lbmap.lock()
+	props, ok := p.lbmap.LBmap[bucket]
+	if !ok {
+		// Cloud bucket: the overrides, if any
+		props = p.lbmap.CBmap[bucket]
+	}
+	p.lbmap.unlock()
+	jsbytes, err := json.Marshal(&props)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "getbucketprops")
+}
+
 // synclbmap requires the caller to lock p.lbmap
 func (p *proxyrunner) synclbmap(w http.ResponseWriter, r *http.Request) {
 	lbpathname := p.confdir + "/" + lbname
@@ -916,6 +1198,64 @@ func (p *proxyrunner) filrename(w http.ResponseWriter, r *http.Request, msg *Act
 	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 }
 
+// multipart upload control: initiate, complete, abort - all executed by the object's target
+func (p *proxyrunner) multipart(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	si, errstr := hrwTarget(bucket+"/"+objname, p.smap)
+	if errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, URLParamLocal, p.islocalBucket(bucket))
+	if glog.V(3) {
+		glog.Infof("%s %s/%s (%s) => %s", msg.Action, bucket, objname, msg.Name, si.DaemonID)
+	}
+	// 307 to preserve the JSON payload
+	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+}
+
+func (p *proxyrunner) filcopy(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	var copymsg CopyMsg
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	jsbytes, err := json.Marshal(msg.Value)
+	assert(err == nil, err)
+	if err = json.Unmarshal(jsbytes, &copymsg); err != nil {
+		s := fmt.Sprintf("Failed to parse CopyMsg, err: %v", err)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if copymsg.DestBucket == "" || strings.Contains(copymsg.DestBucket, "/") {
+		s := fmt.Sprintf("Invalid destination bucket name %q", copymsg.DestBucket)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	if copymsg.DestBucket == bucket && (copymsg.DestObjname == "" || copymsg.DestObjname == objname) {
+		s := fmt.Sprintf("Cannot %s %s/%s onto itself", msg.Action, bucket, objname)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	si, errstr := hrwTarget(bucket+"/"+objname, p.smap)
+	if errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, URLParamLocal, p.islocalBucket(bucket))
+	if glog.V(3) {
+		glog.Infof("%s %s %s/%s => %s/%s (%s)", msg.Action, r.Method, bucket, objname, copymsg.DestBucket, copymsg.DestObjname, si.DaemonID)
+	}
+	p.statsif.add("numcopy", 1)
+	// 307 to keep the JSON payload (see filrename)
+	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+}
+
 func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, actionMsg *ActionMsg) {
 	var (
 		err    error
@@ -947,7 +1287,7 @@ func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, ac
 	switch actionMsg.Action {
 	case ActEvict, ActDelete:
 		method = http.MethodDelete
-	case ActPrefetch:
+	case ActPrefetch, ActCopy, ActMove:
 		method = http.MethodPost
 	default:
 		s := fmt.Sprintf("Action unavailable for List/Range Operations: %s", actionMsg.Action)
@@ -1100,7 +1440,7 @@ func (p *proxyrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, api
 
 func (p *proxyrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, apitems []string) {
 	curversion := p.lbmap.Version
-	newlbmap := &lbmap{LBmap: make(map[string]string)}
+	newlbmap := &lbmap{LBmap: make(map[string]BucketProps)}
 	if p.readJSON(w, r, newlbmap) != nil {
 		return
 	}
@@ -0,0 +1,236 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/golang/glog"
+)
+
+//======
+//
+// ranged cold GET (cold_get.part_size, cold_get.concurrency): a large object is
+// downloaded from the Cloud as concurrent range requests, each writing its part
+// into the work file at the part's offset. The parts are then checksummed in order
+// as they complete, so that the checksum validation (and the tee cold GET readers)
+// proceed while the rest of the object is still downloading
+//
+//======
+
+// rangegetter returns the reader of the object's bytes [off, off+length)
+type rangegetter func(off, length int64) (reader io.ReadCloser, errstr string)
+
+// offsetwriter writes into the file sequentially starting at the offset
+type offsetwriter struct {
+	file *os.File
+	off  int64
+}
+
+func (ow *offsetwriter) Write(p []byte) (n int, err error) {
+	n, err = ow.file.WriteAt(p, ow.off)
+	ow.off += int64(n)
+	return
+}
+
+// rangedcoldget returns true if the object of a given size is to be downloaded in ranges;
+// size < 0 (not known yet) - whether ranged cold GET is enabled
+func rangedcoldget(size int64) bool {
+	cg := &ctx.config.ColdGet
+	return cg.PartSize > 0 && cg.Concurrency > 1 && (size < 0 || size > cg.PartSize)
+}
+
+// parseContentRange returns the total size from the "bytes first-last/total" Content-Range
+func parseContentRange(contentRange string) (size int64, err error) {
+	i := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || i < 0 {
+		return 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	return strconv.ParseInt(contentRange[i+1:], 10, 64)
+}
+
+// receiveranges is the ranged counterpart of receiveresumable(): downloads the object
+// starting at the download progress offset; first, if not nil, reads the first part,
+// the rest of the parts are read via getrange
+func (t *targetrunner) receiveranges(fqn, bucket, objname, omd5 string, ohobj cksumvalue, dl *dlprogress,
+	first io.ReadCloser, getrange rangegetter) (nhobj cksumvalue, written int64, errstr string) {
+	var (
+		err         error
+		file        *os.File
+		h           hash.Hash // xxhash or, to validate cold GET, md5
+		interrupted bool      // failed to download a part - can be resumed
+		size        = dl.Size
+		partsize    = ctx.config.ColdGet.PartSize
+		nparts      = int((size - dl.Offset + partsize - 1) / partsize)
+		results     = make([]chan string, nparts)
+		sema        = make(chan struct{}, ctx.config.ColdGet.Concurrency)
+		stop        = make(chan struct{})
+		wg          = &sync.WaitGroup{}
+		cksumcfg    = t.cksumconf(bucket)
+	)
+	if cksumcfg.Checksum != ChecksumNone {
+		assert(cksumcfg.Checksum == ChecksumXXHash)
+		h = xxhash.New64()
+	} else if omd5 != "" && cksumcfg.ValidateColdGet {
+		h = md5.New()
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	if file, err = t.openpartial(fqn, dl, h, buf); err != nil {
+		slab.free(buf)
+		if first != nil {
+			first.Close()
+		}
+		t.runFSKeeper(fmt.Errorf("%s", fqn))
+		errstr = fmt.Sprintf("Failed to create %s, err: %s", fqn, err)
+		return
+	}
+	written = dl.Offset
+	c := t.coldgets.find(fqn)
+	if c != nil {
+		c.create()
+		c.wrote(written)
+	}
+	defer func() { // wait for the parts in progress, free & cleanup on err
+		close(stop)
+		wg.Wait()
+		slab.free(buf)
+		if errstr == "" {
+			return
+		}
+		if interrupted && t.savepartial(fqn, file, dl, written) {
+			return
+		}
+		t.runFSKeeper(fmt.Errorf("%s", fqn))
+		if err = file.Close(); err != nil {
+			glog.Errorf("Nested: failed to close received file %s, err: %v", fqn, err)
+		}
+		if err = os.Remove(fqn); err != nil {
+			glog.Errorf("Nested error %s => (remove %s => err: %v)", errstr, fqn, err)
+		}
+		removeprogress(fqn)
+	}()
+
+	// download
+	for i := range results {
+		results[i] = make(chan string, 1)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < nparts; i++ {
+			reader := first
+			if i > 0 {
+				reader = nil
+			}
+			select {
+			case sema <- struct{}{}:
+			case <-stop:
+				if reader != nil {
+					reader.Close()
+				}
+				return
+			}
+			wg.Add(1)
+			go func(i int, reader io.ReadCloser) {
+				defer func() { <-sema; wg.Done() }()
+				results[i] <- t.receivepart(file, bucket, objname, dl.Offset+int64(i)*partsize, size, reader, getrange)
+			}(i, reader)
+		}
+	}()
+
+	// checksum the parts in order
+	for i := 0; i < nparts; i++ {
+		if errstr = <-results[i]; errstr != "" {
+			interrupted = true
+			return
+		}
+		off := written
+		length := partsize
+		if off+length > size {
+			length = size - off
+		}
+		if h != nil {
+			if _, err = io.CopyBuffer(h, io.NewSectionReader(file, off, length), buf); err != nil {
+				errstr = fmt.Sprintf("Failed to checksum %s, err: %v", fqn, err)
+				return
+			}
+		}
+		written += length
+		if c != nil {
+			c.wrote(length)
+		}
+	}
+	if cksumcfg.Checksum != ChecksumNone {
+		hashInBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(hashInBytes, h.(hash.Hash64).Sum64())
+		nhval := hex.EncodeToString(hashInBytes)
+		nhobj = newcksumvalue(ChecksumXXHash, nhval)
+		if ohobj != nil {
+			ohtype, ohval := ohobj.get()
+			assert(ohtype == ChecksumXXHash)
+			if ohval != nhval {
+				errstr = fmt.Sprintf("Bad checksum: %s %s %s... != %s... computed for the %q",
+					objname, cksumcfg.Checksum, ohval[:8], nhval[:8], fqn)
+				t.statsif.addMany("numbadchecksum", int64(1), "bytesbadchecksum", written)
+				return
+			}
+		}
+	} else if h != nil {
+		md5hash := hex.EncodeToString(h.Sum(nil)[:16])
+		if omd5 != md5hash {
+			errstr = fmt.Sprintf("Bad checksum: cold GET %s md5 %s... != %s... computed for the %q",
+				objname, omd5[:8], md5hash[:8], fqn)
+			t.statsif.addMany("numbadchecksum", int64(1), "bytesbadchecksum", written)
+			return
+		}
+	}
+	if err = file.Close(); err != nil {
+		errstr = fmt.Sprintf("Failed to close received file %s, err: %v", fqn, err)
+		return
+	}
+	removeprogress(fqn)
+	return
+}
+
+// receivepart downloads the part that starts at the offset and writes it into the file
+func (t *targetrunner) receivepart(file *os.File, bucket, objname string, off, size int64, reader io.ReadCloser,
+	getrange rangegetter) (errstr string) {
+	length := ctx.config.ColdGet.PartSize
+	if off+length > size {
+		length = size - off
+	}
+	if reader == nil {
+		if reader, errstr = getrange(off, length); errstr != "" {
+			return
+		}
+	}
+	defer reader.Close()
+	slab := selectslab(length)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	n, err := io.CopyBuffer(&offsetwriter{file: file, off: off}, io.LimitReader(reader, length), buf)
+	if err != nil {
+		return fmt.Sprintf("Failed to GET %s/%s range [%d, %d), err: %v", bucket, objname, off, off+length, err)
+	}
+	if n != length {
+		return fmt.Sprintf("Failed to GET %s/%s range [%d, %d): received %d bytes", bucket, objname, off, off+length, n)
+	}
+	if glog.V(4) {
+		glog.Infof("GET %s/%s range [%d, %d)", bucket, objname, off, off+length)
+	}
+	return
+}
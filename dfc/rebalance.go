@@ -23,8 +23,9 @@ func (t *targetrunner) runRebalance() {
 		return
 	}
 	glog.Infoln(xreb.tostring())
+	aborted := false
 	for mpath := range ctx.mountpaths.Available {
-		aborted := t.oneRebalance(makePathCloud(mpath), xreb)
+		aborted = t.oneRebalance(makePathCloud(mpath), xreb)
 		if aborted {
 			break
 		}
@@ -33,6 +34,10 @@ func (t *targetrunner) runRebalance() {
 			break
 		}
 	}
+	// replicated local buckets: restore the replica count
+	if !aborted {
+		t.rebalanceReplicas(xreb)
+	}
 	xreb.etime = time.Now()
 	glog.Infoln(xreb.tostring())
 	t.xactinp.del(xreb.id)
@@ -93,12 +98,16 @@ func (xreb *xactRebalance) rewalkf(fqn string, osfi os.FileInfo, err error) erro
 		glog.Infof("rebalancing [%s %s] %s => %s", bucket, objname, t.si.DaemonID, si.DaemonID)
 		if s := xreb.targetrunner.sendfile(http.MethodPut, bucket, objname, si, osfi.Size(), ""); s != "" {
 			glog.Infof("Failed to rebalance [%s %s]: %s", bucket, objname, s)
+		} else if copies, _ := t.copies(bucket); copies > 1 && t.islocalBucket(bucket) {
+			t.rebalanceobj(bucket, objname, fqn, true /*moved*/)
 		} else {
 			// FIXME: TODO: delay the removal or (even) rely on the LRU
 			if err := os.Remove(fqn); err != nil {
 				glog.Errorf("Failed to delete the file %s that has moved, err: %v", fqn, err)
 			}
 		}
+	} else if copies, _ := t.copies(bucket); copies > 1 && t.islocalBucket(bucket) {
+		t.rebalanceobj(bucket, objname, fqn, false /*moved*/)
 	}
 	return nil
 }
@@ -0,0 +1,336 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+type xactRenameLB struct {
+	xactBase
+	targetrunner *targetrunner
+}
+
+//======
+//
+// local bucket rename: the primary proxy first has every target rename its
+// per-mountpath bucket directories (?prepare=true), and then - migrate the
+// objects that belong elsewhere under the new name (?prepare=false)
+//
+//======
+
+// lbdirs returns the local bucket's per-mountpath directories: objects, slices and replicas
+func lbdirs(mpath, bucket string) []string {
+	return []string{
+		filepath.Join(makePathLocal(mpath), bucket),
+		filepath.Join(makePathEC(mpath), bucket),
+		filepath.Join(makePathReplica(mpath), bucket),
+	}
+}
+
+// POST { action: renamelb, name: new-name[, value: lbmap] } /Rversion/Rbuckets/bucket-name?prepare=bool
+func (t *targetrunner) renamelb(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 1, Rversion, Rbuckets); apitems == nil {
+		return
+	}
+	bucket, newbucket := apitems[0], msg.Name
+	prepare, err := parsebool(r.URL.Query().Get(URLParamPrepare))
+	if err != nil {
+		s := fmt.Sprintf("Failed to parse %s URL Parameter: %v", URLParamPrepare, err)
+		t.invalmsghdlr(w, r, s)
+		return
+	}
+	if !prepare {
+		go t.migratelb(newbucket)
+		return
+	}
+	newlbmap := &lbmap{LBmap: make(map[string]BucketProps)}
+	jsbytes, err := json.Marshal(msg.Value)
+	assert(err == nil, err)
+	if err = json.Unmarshal(jsbytes, newlbmap); err != nil {
+		s := fmt.Sprintf("Failed to unmarshal lbmap, err: %v", err)
+		t.invalmsghdlr(w, r, s)
+		return
+	}
+	if _, ok := newlbmap.LBmap[newbucket]; !ok || newlbmap.Version <= t.lbmap.Version {
+		s := fmt.Sprintf("Rename %s => %s: unexpected lbmap version %d (current %d)",
+			bucket, newbucket, newlbmap.Version, t.lbmap.Version)
+		t.invalmsghdlr(w, r, s)
+		return
+	}
+	renamed := make([][2]string, 0, 3*len(ctx.mountpaths.Available))
+	for mpath := range ctx.mountpaths.Available {
+		froms, tos := lbdirs(mpath, bucket), lbdirs(mpath, newbucket)
+		for i, from := range froms {
+			to := tos[i]
+			// leftover of the destroyed local bucket that this target may not know about yet
+			if err := os.RemoveAll(to); err != nil {
+				glog.Errorf("Failed to remove %q, err: %v", to, err)
+			}
+			if err := os.Rename(from, to); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				// roll back, to keep the bucket intact under its current name
+				for j := len(renamed) - 1; j >= 0; j-- {
+					from, to := renamed[j][1], renamed[j][0]
+					if err := os.Rename(from, to); err != nil {
+						glog.Errorf("Failed to roll back the rename %q => %q, err: %v", from, to, err)
+					}
+				}
+				s := fmt.Sprintf("Failed to rename local bucket dir %q => %q, err: %v", from, to, err)
+				t.invalmsghdlr(w, r, s)
+				return
+			}
+			renamed = append(renamed, [2]string{from, to})
+		}
+	}
+	glog.Infof("Rename local bucket %s => %s: new lbmap version %d", bucket, newbucket, newlbmap.Version)
+	t.setlbmap(newlbmap)
+}
+
+// migratelb walks the renamed bucket and relocates the objects whose HRW target
+// or mountpath have changed; the objects' slices and replicas get rebuilt in the
+// process, which leaves the renamed ones that are no longer in place to remove
+func (t *targetrunner) migratelb(bucket string) {
+	xmig := t.xactinp.newRenameLB(t)
+	glog.Infoln(xmig.tostring())
+	defer func() {
+		xmig.etime = time.Now()
+		glog.Infoln(xmig.tostring())
+		t.xactinp.del(xmig.id)
+	}()
+	var mpath string
+	walkf := func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if osfi.IsDir() {
+			return nil
+		}
+		if iswork, _ := t.isworkfile(fqn); iswork {
+			return nil
+		}
+		select {
+		case <-xmig.abrt:
+			return errors.New(xmig.tostring() + " aborted")
+		default:
+		}
+		// NOTE: not using fqn2bckobj that fails on the objects that are yet to migrate
+		objname := strings.TrimPrefix(fqn, filepath.Join(makePathLocal(mpath), bucket)+"/")
+		if errstr := t.migrateobj(bucket, objname, fqn, osfi.Size()); errstr != "" {
+			glog.Errorf("Failed to migrate %q: %s", fqn, errstr)
+		}
+		return nil
+	}
+	for mpath = range ctx.mountpaths.Available {
+		dir := filepath.Join(makePathLocal(mpath), bucket)
+		if err := filepath.Walk(dir, walkf); err != nil {
+			glog.Errorf("Failed to traverse %q, err: %v", dir, err)
+			return
+		}
+	}
+	if conf, enabled := t.ecconf(bucket); enabled {
+		inplace := func(objname, fqn string) bool {
+			si, errstr := hrwTarget(bucket+"/"+objname, t.smap)
+			if errstr != "" {
+				return true
+			}
+			targets, errstr := t.slicetargets(bucket, objname, si, conf)
+			if errstr != "" {
+				return true
+			}
+			for _, si := range targets {
+				if si.DaemonID == t.si.DaemonID {
+					return fqn == t.slicefqn(bucket, objname)
+				}
+			}
+			return false
+		}
+		if !t.prunelb(xmig, bucket, makePathEC, t.sliceuname, inplace) {
+			return
+		}
+	}
+	if copies, _ := t.copies(bucket); copies > 1 {
+		inplace := func(objname, fqn string) bool {
+			_, rank, errstr := t.replicaTargets(bucket, objname, copies)
+			return errstr != "" || rank > 0 && fqn == t.replicafqn(bucket, objname)
+		}
+		t.prunelb(xmig, bucket, makePathReplica, t.replicauname, inplace)
+	}
+}
+
+// prunelb removes the renamed bucket's slices or replicas that are not in place
+func (t *targetrunner) prunelb(xmig *xactRenameLB, bucket string, makepath func(string) string,
+	uname func(string, string) string, inplace func(objname, fqn string) bool) bool {
+	var dir string
+	walkf := func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if osfi.IsDir() {
+			return nil
+		}
+		if iswork, _ := t.isworkfile(fqn); iswork {
+			return nil
+		}
+		select {
+		case <-xmig.abrt:
+			return errors.New(xmig.tostring() + " aborted")
+		default:
+		}
+		objname := strings.TrimPrefix(fqn, dir+"/")
+		if inplace(objname, fqn) {
+			return nil
+		}
+		name := uname(bucket, objname)
+		t.rtnamemap.lockname(name, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+		if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to remove %q, err: %v", fqn, err)
+		}
+		t.rtnamemap.unlockname(name, true)
+		return nil
+	}
+	for mpath := range ctx.mountpaths.Available {
+		dir = filepath.Join(makepath(mpath), bucket)
+		if err := filepath.Walk(dir, walkf); err != nil {
+			glog.Errorf("Failed to traverse %q, err: %v", dir, err)
+			return false
+		}
+	}
+	return true
+}
+
+// migrateobj moves the object to its HRW target and mountpath under the new bucket name
+// and rebuilds its slices and replicas: the owner re-encodes (re-replicates) the object
+// that stays, while the object that moves gets re-encoded (re-replicated) by the source
+func (t *targetrunner) migrateobj(bucket, objname, fqn string, size int64) (errstr string) {
+	si, errstr := hrwTarget(bucket+"/"+objname, t.smap)
+	if errstr != "" {
+		return
+	}
+	uname := t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	if _, err := os.Stat(fqn); err != nil {
+		t.rtnamemap.unlockname(uname, true)
+		return // deleted in the meantime
+	}
+	if si.DaemonID == t.si.DaemonID {
+		if dstfqn := t.fqn(bucket, objname); dstfqn != fqn {
+			if errstr = t.migratelocal(bucket, objname, fqn, dstfqn); errstr == "" {
+				if err := os.Remove(fqn); err != nil {
+					errstr = fmt.Sprintf("Failed to delete the file %s that has moved, err: %v", fqn, err)
+				}
+			}
+		}
+		t.rtnamemap.unlockname(uname, true)
+		if errstr != "" {
+			return
+		}
+		if errs := t.ecencode(bucket, objname); errs != "" {
+			glog.Errorln(errs)
+		}
+		if errs := t.replicate(bucket, objname, true); errs != "" {
+			glog.Errorln(errs)
+		}
+		return
+	}
+	defer t.rtnamemap.unlockname(uname, true)
+	url := si.DirectURL + "/" + Rversion + "/" + Robjects + "/" + bucket + "/" + objname
+	url += fmt.Sprintf("?%s=%s&%s=%s", URLParamFromID, t.si.DaemonID, URLParamToID, si.DaemonID)
+	if errstr = t.sendfqn(http.MethodPut, bucket, fqn, url, si, size); errstr != "" {
+		return
+	}
+	if glog.V(4) {
+		glog.Infof("Migrated %s/%s: %s => %s", bucket, objname, fqn, si.DaemonID)
+	}
+	if errs := t.ecmigrate(bucket, objname, fqn, si); errs != "" {
+		glog.Errorln(errs)
+	}
+	if copies, _ := t.copies(bucket); copies > 1 {
+		sis, _, errs := t.replicaTargets(bucket, objname, copies)
+		if errs == "" {
+			errs = t.sendreplicas(fqn, bucket, objname, sis[1:], true)
+		}
+		if errs != "" {
+			glog.Errorln(errs)
+		}
+		t.rebalanceobj(bucket, objname, fqn, true /*moved*/) // keeps the file as a replica or removes it
+		return
+	}
+	if err := os.Remove(fqn); err != nil {
+		errstr = fmt.Sprintf("Failed to delete the file %s that has moved, err: %v", fqn, err)
+	}
+	return
+}
+
+// migratelocal copies the object to its mountpath under the new bucket name - unless the
+// object has been PUT there in the meantime; the caller must hold the exclusive lock
+func (t *targetrunner) migratelocal(bucket, objname, fqn, dstfqn string) (errstr string) {
+	if _, err := os.Stat(dstfqn); err == nil {
+		return
+	}
+	var hdhobj cksumvalue
+	file, err := os.Open(fqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to open %q, err: %v", fqn, err)
+	}
+	defer file.Close()
+	if t.cksumconf(bucket).Checksum != ChecksumNone {
+		if hashbinary, errs := Getxattr(fqn, xattrXXHashVal); errs == "" && hashbinary != nil {
+			hdhobj = newcksumvalue(ChecksumXXHash, string(hashbinary))
+		}
+	}
+	var (
+		putfqn = t.fqn2workfile(dstfqn)
+		props  = &objectProps{meta: t.objmeta(fqn)}
+	)
+	if version, errs := Getxattr(fqn, xattrObjVersion); errs == "" {
+		props.version = string(version)
+	}
+	if _, props.nhobj, props.size, errstr = t.receive(putfqn, false, bucket, objname, "", hdhobj, file); errstr != "" {
+		return
+	}
+	if err = os.Rename(putfqn, dstfqn); err != nil {
+		removeworkfiles([]string{putfqn})
+		return fmt.Sprintf("Failed to rename %s => %s, err: %v", putfqn, dstfqn, err)
+	}
+	return t.finalizeobj(dstfqn, props)
+}
+
+func (q *xactInProgress) newRenameLB(t *targetrunner) *xactRenameLB {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	id := q.uniqueid()
+	xmig := &xactRenameLB{xactBase: *newxactBase(id, ActRenameLB), targetrunner: t}
+	q.add(xmig)
+	return xmig
+}
+
+func (xact *xactRenameLB) tostring() string {
+	start := xact.stime.Sub(xact.targetrunner.starttime())
+	if !xact.finished() {
+		return fmt.Sprintf("xaction %s:%d started %v", xact.kind, xact.id, start)
+	}
+	fin := time.Since(xact.targetrunner.starttime())
+	return fmt.Sprintf("xaction %s:%d started %v finished %v", xact.kind, xact.id, start, fin)
+}
@@ -0,0 +1,668 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/golang/glog"
+)
+
+// N-way replication of local buckets (BucketProps.Copies):
+//   1. PUT: once committed, the object is mirrored via sendfile to the next Copies-1
+//      targets in the HRW order - before the PUT returns or, if BucketProps.CopiesAsync,
+//      in background
+//   2. GET: the owner that does not have the object (e.g., the previous owner is down
+//      and has been removed from the cluster map) or, with validate_warm_get, whose copy
+//      fails checksum validation restores it from one of its replicas; the replica's
+//      checksum is validated before it becomes the object
+//   3. rebalance: once the objects are moved to their new owners, the targets walk
+//      their replicas and restore the replica count as per the new cluster map
+//   4. DELETE: the object's replicas are removed cluster-wide
+//
+// Replicas are stored under <mountpath>/replicas/<bucket>/<object> with the same
+// xattrs (checksum, version) as the objects; intra-cluster requests that address
+// replicas carry URLParamReplica.
+
+const replicasDir = "replicas"
+
+// builds fqn of directory for replicas from mountpath
+func makePathReplica(basePath string) string {
+	return filepath.Join(basePath, replicasDir)
+}
+
+func (t *targetrunner) replicafqn(bucket, objname string) string {
+	mpath := hrwMpath(bucket + "/" + objname)
+	return filepath.Join(makePathReplica(mpath), bucket, objname)
+}
+
+// the target that owns an object may also store its replica (e.g., when the
+// cluster map changes) - hence, separate lock names
+func (t *targetrunner) replicauname(bucket, objname string) string {
+	return "replica:" + t.uname(bucket, objname)
+}
+
+func (t *targetrunner) replicaurl(si *daemonInfo, bucket, objname string) string {
+	return si.DirectURL + "/" + Rversion + "/" + Robjects + "/" + bucket + "/" + objname + "?" + URLParamReplica + "=true"
+}
+
+// isreplicareq returns true if the request addresses a replica rather than the object
+func isreplicareq(r *http.Request) bool {
+	replica, _ := parsebool(r.URL.Query().Get(URLParamReplica))
+	return replica
+}
+
+// the opposite of replicafqn
+func (t *targetrunner) replicafqn2bckobj(fqn string) (bucket, objname string, ok bool) {
+	for mpath := range ctx.mountpaths.Available {
+		dir := makePathReplica(mpath) + "/"
+		if !strings.HasPrefix(fqn, dir) {
+			continue
+		}
+		items := strings.SplitN(fqn[len(dir):], "/", 2)
+		if len(items) == 2 && items[1] != "" {
+			bucket, objname = items[0], items[1]
+			ok = t.islocalBucket(bucket) && t.replicafqn(bucket, objname) == fqn
+		}
+		return
+	}
+	return
+}
+
+func (t *targetrunner) copies(bucket string) (copies int, async bool) {
+	props, ok := t.lbmap.LBmap[bucket]
+	if !ok {
+		return
+	}
+	return props.Copies, props.CopiesAsync
+}
+
+// replicaTargets returns the first copies targets in the HRW order (the first one
+// being the owner) and the rank of self among them (-1 if self is not one of them)
+func (t *targetrunner) replicaTargets(bucket, objname string, copies int) (sis []*daemonInfo, rank int, errstr string) {
+	rank = -1
+	if sis, errstr = hrwTargetList(bucket+"/"+objname, t.smap); errstr != "" {
+		return
+	}
+	if copies < len(sis) {
+		sis = sis[:copies]
+	}
+	for i, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			rank = i
+		}
+	}
+	return
+}
+
+// cksumvalid validates the file against its stored checksum, if any
+func (t *targetrunner) cksumvalid(bucket, fqn string) (exists, valid bool) {
+	file, err := os.Open(fqn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to open %s, err: %v", fqn, err)
+		}
+		return
+	}
+	defer file.Close()
+	exists = true
+	hashbinary, errstr := Getxattr(fqn, xattrXXHashVal)
+	if errstr != "" || len(hashbinary) == 0 || t.cksumconf(bucket).Checksum == ChecksumNone {
+		valid = true // nothing to validate against
+		return
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	xxhashval, errstr := ComputeXXHash(file, buf, xxhash.New64())
+	slab.free(buf)
+	if errstr != "" {
+		glog.Errorln(errstr)
+		return
+	}
+	if valid = xxhashval == string(hashbinary); !valid {
+		glog.Errorf("Bad checksum: %s %s %s... != %s...", fqn, ChecksumXXHash, string(hashbinary)[:8], xxhashval[:8])
+		if finfo, err := file.Stat(); err == nil {
+			t.statsif.addMany("numbadchecksum", int64(1), "bytesbadchecksum", finfo.Size())
+		}
+	}
+	return
+}
+
+//============================================================
+//
+// replicas: intra-cluster requests with URLParamReplica
+//
+//============================================================
+
+// GET: replica along with its checksum and version
+func (t *targetrunner) httpreplicaget(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn, uname := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+
+	file, err := os.Open(fqn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No replica %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to open replica %s, err: %v", fqn, err))
+		}
+		return
+	}
+	defer file.Close()
+	t.replicaheaders(w, fqn)
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	if _, err = io.CopyBuffer(w, file, buf); err != nil {
+		glog.Errorf("Failed to send replica %s, err: %v", fqn, err)
+	}
+}
+
+// HEAD: replica's checksum and version, or 404
+func (t *targetrunner) httpreplicahead(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn, uname := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+	if _, err := os.Stat(fqn); err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No replica %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to fstat replica %s, err: %v", fqn, err))
+		}
+		return
+	}
+	t.replicaheaders(w, fqn)
+}
+
+func (t *targetrunner) replicaheaders(w http.ResponseWriter, fqn string) {
+	if hashbinary, errstr := Getxattr(fqn, xattrXXHashVal); errstr == "" && len(hashbinary) > 0 {
+		w.Header().Add(HeaderDfcChecksumType, ChecksumXXHash)
+		w.Header().Add(HeaderDfcChecksumVal, string(hashbinary))
+	}
+	if version, errstr := Getxattr(fqn, xattrObjVersion); errstr == "" && len(version) > 0 {
+		w.Header().Add(HeaderDfcObjVersion, string(version))
+	}
+	objmetaToHeader(w.Header(), t.objmeta(fqn))
+}
+
+// DELETE: 404 if there's no replica
+func (t *targetrunner) httpreplicadelete(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn, uname := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	err := os.Remove(fqn)
+	t.rtnamemap.unlockname(uname, true)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("No replica %s/%s", bucket, objname), http.StatusNotFound)
+		} else {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to remove replica %s, err: %v", fqn, err))
+		}
+	}
+}
+
+// replicarecv receives replica sent by sendreplicas (see dorebalance)
+func (t *targetrunner) replicarecv(r *http.Request, bucket, objname string) (errstr string) {
+	var (
+		fqn     = t.replicafqn(bucket, objname)
+		uname   = t.replicauname(bucket, objname)
+		workfqn = t.fqn2workfile(fqn)
+		hdhobj  = newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
+		props   = &objectProps{version: r.Header.Get(HeaderDfcObjVersion)}
+		size    int64
+	)
+	props.meta, _ = objmetaFromHeader(r.Header)
+	if _, props.nhobj, size, errstr = t.receive(workfqn, false, bucket, objname, "", hdhobj, r.Body); errstr != "" {
+		return
+	}
+	if props.nhobj == nil {
+		props.nhobj = hdhobj
+	}
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, true)
+	if err := os.Rename(workfqn, fqn); err != nil {
+		removeworkfiles([]string{workfqn})
+		return fmt.Sprintf("Failed to rename %s => %s, err: %v", workfqn, fqn, err)
+	}
+	if errstr = t.finalizeobj(fqn, props); errstr != "" {
+		return
+	}
+	t.statsif.addMany("numrecvfiles", int64(1), "numrecvbytes", size)
+	if glog.V(4) {
+		glog.Infof("Replica %s/%s, %.2f MB", bucket, objname, float64(size)/MiB)
+	}
+	return
+}
+
+//============================================================
+//
+// replicate, restore, delete
+//
+//============================================================
+
+// replicate mirrors the object to the other targets in the HRW order; with check,
+// only the replicas that are missing or differ get (re)sent
+func (t *targetrunner) replicate(bucket, objname string, check bool) (errstr string) {
+	copies, _ := t.copies(bucket)
+	if copies < 2 {
+		return
+	}
+	sis, _, errstr := t.replicaTargets(bucket, objname, copies)
+	if errstr != "" {
+		return
+	}
+	fqn, uname := t.fqn(bucket, objname), t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+	return t.sendreplicas(fqn, bucket, objname, sis, check)
+}
+
+// sendreplicas sends the local file fqn as the object's replica to the given targets
+// (self excluded) - must be called under lock
+func (t *targetrunner) sendreplicas(fqn, bucket, objname string, sis []*daemonInfo, check bool) (errstr string) {
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		if !os.IsNotExist(err) { // otherwise, deleted in the meantime
+			errstr = fmt.Sprintf("Replication: failed to fstat %s, err: %v", fqn, err)
+		}
+		return
+	}
+	var (
+		cksum, version string
+		wg             = &sync.WaitGroup{}
+		errch          = make(chan string, len(sis))
+	)
+	if check {
+		if b, errs := Getxattr(fqn, xattrXXHashVal); errs == "" {
+			cksum = string(b)
+		}
+		if b, errs := Getxattr(fqn, xattrObjVersion); errs == "" {
+			version = string(b)
+		}
+	}
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			if check && t.hasreplica(si, bucket, objname, cksum, version) {
+				return
+			}
+			url := t.replicaurl(si, bucket, objname)
+			url += fmt.Sprintf("&%s=%s&%s=%s", URLParamFromID, t.si.DaemonID, URLParamToID, si.DaemonID)
+			if errstr := t.sendfqn(http.MethodPut, bucket, fqn, url, si, finfo.Size()); errstr != "" {
+				errch <- errstr
+			}
+		}(si)
+	}
+	wg.Wait()
+	close(errch)
+	for errstr = range errch {
+		glog.Errorln(errstr)
+	}
+	if errstr != "" {
+		errstr = fmt.Sprintf("Replication %s/%s: failed to send replica(s), last err: %s", bucket, objname, errstr)
+	}
+	return
+}
+
+func (t *targetrunner) hasreplica(si *daemonInfo, bucket, objname, cksum, version string) bool {
+	response, err := t.httpclient.Head(t.replicaurl(si, bucket, objname))
+	if err != nil {
+		glog.Errorf("Failed to HEAD replica %s/%s at %s, err: %v", bucket, objname, si.DaemonID, err)
+		return false
+	}
+	ReadToNull(response.Body)
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return false
+	}
+	return response.Header.Get(HeaderDfcChecksumVal) == cksum && response.Header.Get(HeaderDfcObjVersion) == version
+}
+
+// replicacheck makes sure that the object is present, restoring it from a replica otherwise;
+// the object is also validated against its checksum only if validate_warm_get is enabled
+// (cluster-wide or for the bucket) - that doubles the I/O of the GET
+func (t *targetrunner) replicacheck(bucket, objname, fqn string) (errstr string, errcode int) {
+	validate := t.versionconf(bucket).ValidateWarmGet
+	if !validate {
+		if _, err := os.Stat(fqn); err == nil || !os.IsNotExist(err) {
+			return
+		}
+	} else {
+		uname := t.uname(bucket, objname)
+		t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+		exists, valid := t.cksumvalid(bucket, fqn)
+		t.rtnamemap.unlockname(uname, false)
+		if exists && valid {
+			return
+		}
+	}
+	return t.replicarestore(bucket, objname, fqn, validate)
+}
+
+// replicarestore restores the object that is missing or (if validate) fails checksum validation
+// from one of its replicas: the local one, if present, or the first one found in the HRW order
+func (t *targetrunner) replicarestore(bucket, objname, fqn string, validate bool) (errstr string, errcode int) {
+	var exists, valid bool
+	uname := t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, true)
+	if validate {
+		exists, valid = t.cksumvalid(bucket, fqn)
+	} else {
+		_, err := os.Stat(fqn)
+		exists = err == nil || !os.IsNotExist(err)
+		valid = exists
+	}
+	if exists && valid {
+		return // restored or PUT in the meantime
+	}
+	started := time.Now()
+	from := t.si.DaemonID
+	restored := t.promotereplica(bucket, objname, fqn)
+	if !restored {
+		sis, errs := hrwTargetList(bucket+"/"+objname, t.smap)
+		if errs != "" {
+			return errs, http.StatusInternalServerError
+		}
+		for _, si := range sis {
+			if si.DaemonID == t.si.DaemonID {
+				continue
+			}
+			var errs string
+			if restored, errs = t.fetchreplica(si, bucket, objname, fqn); restored {
+				from = si.DaemonID
+				break
+			}
+			if errs != "" {
+				glog.Errorln(errs)
+			}
+		}
+	}
+	if !restored {
+		if exists {
+			errstr = fmt.Sprintf("GET local: object %s/%s fails checksum validation and has no valid replicas",
+				bucket, objname)
+			errcode = http.StatusInternalServerError
+		} else {
+			errstr = fmt.Sprintf("GET local: file %s (object %s/%s) does not exist", fqn, bucket, objname)
+			errcode = http.StatusNotFound
+		}
+		return
+	}
+	glog.Infof("Restored %s/%s from the replica at %s, %d µs", bucket, objname, from, time.Since(started)/1000)
+	t.statsif.add("numreplrestore", 1)
+	go func() {
+		if errstr := t.replicate(bucket, objname, true); errstr != "" {
+			glog.Errorln(errstr)
+		}
+	}()
+	return
+}
+
+// promotereplica turns the local replica (if valid) into the object - must be called under lock
+func (t *targetrunner) promotereplica(bucket, objname, fqn string) (promoted bool) {
+	rfqn, runame := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(runame, true, &pendinginfo{Time: time.Now(), fqn: rfqn})
+	defer t.rtnamemap.unlockname(runame, true)
+	if exists, valid := t.cksumvalid(bucket, rfqn); !exists || !valid {
+		return
+	}
+	if err := CreateDir(filepath.Dir(fqn)); err != nil {
+		glog.Errorf("Failed to create dir for %s, err: %v", fqn, err)
+		return
+	}
+	if err := os.Rename(rfqn, fqn); err != nil {
+		glog.Errorf("Failed to rename %s => %s, err: %v", rfqn, fqn, err)
+		return
+	}
+	return true
+}
+
+func (t *targetrunner) fetchreplica(si *daemonInfo, bucket, objname, fqn string) (fetched bool, errstr string) {
+	response, err := t.httpclient.Get(t.replicaurl(si, bucket, objname))
+	if err != nil {
+		errstr = fmt.Sprintf("Failed to GET replica %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		ReadToNull(response.Body)
+		if response.StatusCode != http.StatusNotFound {
+			errstr = fmt.Sprintf("Failed to GET replica %s/%s from %s, status %d",
+				bucket, objname, si.DaemonID, response.StatusCode)
+		}
+		return
+	}
+	var (
+		workfqn = t.fqn2workfile(fqn)
+		hdhobj  = newcksumvalue(response.Header.Get(HeaderDfcChecksumType), response.Header.Get(HeaderDfcChecksumVal))
+		props   = &objectProps{version: response.Header.Get(HeaderDfcObjVersion)}
+	)
+	props.meta, _ = objmetaFromHeader(response.Header)
+	if _, props.nhobj, props.size, errstr = t.receive(workfqn, false, bucket, objname, "", hdhobj, response.Body); errstr != "" {
+		return
+	}
+	if props.nhobj == nil {
+		props.nhobj = hdhobj
+	}
+	if err = os.Rename(workfqn, fqn); err != nil {
+		removeworkfiles([]string{workfqn})
+		errstr = fmt.Sprintf("Failed to rename %s => %s, err: %v", workfqn, fqn, err)
+		return
+	}
+	if errstr = t.finalizeobj(fqn, props); errstr == "" {
+		fetched = true
+	}
+	return
+}
+
+// replicadelete removes the object's replicas cluster-wide and returns the number of removed replicas
+func (t *targetrunner) replicadelete(bucket, objname string) (removed int) {
+	var (
+		mu = &sync.Mutex{}
+		wg = &sync.WaitGroup{}
+	)
+	fqn, uname := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	if err := os.Remove(fqn); err == nil {
+		removed++
+	} else if !os.IsNotExist(err) {
+		glog.Errorf("Failed to remove replica %s, err: %v", fqn, err)
+	}
+	t.rtnamemap.unlockname(uname, true)
+
+	for _, si := range t.smap.Smap {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			url := t.replicaurl(si, bucket, objname)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			if err != nil {
+				glog.Errorf("Unexpected failure to create DELETE request %s, err: %v", url, err)
+				return
+			}
+			response, err := t.httpclient.Do(request)
+			if err != nil {
+				glog.Errorf("Failed to DELETE replica %s/%s at %s, err: %v", bucket, objname, si.DaemonID, err)
+				return
+			}
+			ReadToNull(response.Body)
+			response.Body.Close()
+			switch {
+			case response.StatusCode < http.StatusBadRequest:
+				mu.Lock()
+				removed++
+				mu.Unlock()
+			case response.StatusCode != http.StatusNotFound:
+				glog.Errorf("Failed to DELETE replica %s/%s at %s, status %d",
+					bucket, objname, si.DaemonID, response.StatusCode)
+			}
+		}(si)
+	}
+	wg.Wait()
+	return
+}
+
+//============================================================
+//
+// rebalance
+//
+//============================================================
+
+// rebalanceobj is called by rebalance for a local object of a replicated bucket:
+// the owner makes sure that all replicas are in place, while the previous owner
+// keeps the object as a replica if it is still one of the replica targets
+func (t *targetrunner) rebalanceobj(bucket, objname, fqn string, moved bool) {
+	copies, _ := t.copies(bucket)
+	if copies < 2 {
+		return
+	}
+	if !moved {
+		if errstr := t.replicate(bucket, objname, true); errstr != "" {
+			glog.Errorln(errstr)
+		}
+		return
+	}
+	_, rank, errstr := t.replicaTargets(bucket, objname, copies)
+	if errstr != "" || rank < 1 {
+		if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to delete the file %s that has moved, err: %v", fqn, err)
+		}
+		return
+	}
+	rfqn, runame := t.replicafqn(bucket, objname), t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(runame, true, &pendinginfo{Time: time.Now(), fqn: rfqn})
+	defer t.rtnamemap.unlockname(runame, true)
+	if err := CreateDir(filepath.Dir(rfqn)); err != nil {
+		glog.Errorf("Failed to create dir for %s, err: %v", rfqn, err)
+		return
+	}
+	if err := os.Rename(fqn, rfqn); err != nil {
+		glog.Errorf("Failed to rename %s => %s, err: %v", fqn, rfqn, err)
+	}
+}
+
+// replicated returns true if there's at least one replicated local bucket
+func (t *targetrunner) replicated() bool {
+	for bucket := range t.lbmap.LBmap {
+		if copies, _ := t.copies(bucket); copies > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *targetrunner) rebalanceReplicas(xreb *xactRebalance) {
+	if !t.replicated() {
+		return
+	}
+	for mpath := range ctx.mountpaths.Available {
+		dir := makePathReplica(mpath)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := filepath.Walk(dir, xreb.rewalkreplicaf); err != nil {
+			s := err.Error()
+			if strings.Contains(s, "xaction") {
+				glog.Infof("Stopping replicas %q traversal: %s", dir, s)
+			} else {
+				glog.Errorf("Failed to traverse replicas %q, err: %v", dir, err)
+			}
+			return
+		}
+	}
+}
+
+// the walking callback: a replica that is no longer needed where it is gets either
+// promoted (this target is now the owner) or sent to the replica targets that miss it
+func (xreb *xactRebalance) rewalkreplicaf(fqn string, osfi os.FileInfo, err error) error {
+	if err != nil {
+		glog.Errorf("rewalkreplicaf callback invoked with err: %v", err)
+		return err
+	}
+	t := xreb.targetrunner
+	if osfi.Mode().IsDir() {
+		return nil
+	}
+	if iswork, _ := t.isworkfile(fqn); iswork {
+		return nil
+	}
+	select {
+	case <-xreb.abrt:
+		s := fmt.Sprintf("%s aborted, exiting rewalkreplicaf", xreb.tostring())
+		glog.Infoln(s)
+		return errors.New(s)
+	default:
+	}
+	if xreb.finished() {
+		return fmt.Errorf("%s aborted - exiting rewalkreplicaf", xreb.tostring())
+	}
+	bucket, objname, ok := t.replicafqn2bckobj(fqn)
+	copies, _ := t.copies(bucket)
+	if !ok || copies < 2 {
+		// local bucket destroyed, replication disabled or mountpaths changed
+		glog.Infof("Removing replica %q", fqn)
+		if err := os.Remove(fqn); err != nil {
+			glog.Errorf("Failed to remove replica %s, err: %v", fqn, err)
+		}
+		return nil
+	}
+	sis, rank, errstr := t.replicaTargets(bucket, objname, copies)
+	if errstr != "" {
+		return errors.New(errstr)
+	}
+	if rank > 0 {
+		return nil // in place
+	}
+	if rank == 0 {
+		// the owner: promote unless the object is already here
+		ofqn, ouname := t.fqn(bucket, objname), t.uname(bucket, objname)
+		t.rtnamemap.lockname(ouname, true, &pendinginfo{Time: time.Now(), fqn: ofqn})
+		if _, err := os.Stat(ofqn); err != nil && os.IsNotExist(err) {
+			if t.promotereplica(bucket, objname, ofqn) {
+				glog.Infof("Promoted replica %s/%s", bucket, objname)
+			}
+		}
+		t.rtnamemap.unlockname(ouname, true)
+		if _, err := os.Stat(fqn); err == nil {
+			if err := os.Remove(fqn); err != nil {
+				glog.Errorf("Failed to remove replica %s, err: %v", fqn, err)
+			}
+		}
+		if errstr := t.replicate(bucket, objname, true); errstr != "" {
+			glog.Errorln(errstr)
+		}
+		return nil
+	}
+	// out of place: hand it over to the replica targets
+	uname := t.replicauname(bucket, objname)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, true)
+	if errstr := t.sendreplicas(fqn, bucket, objname, sis[1:], true); errstr != "" {
+		glog.Errorln(errstr)
+		return nil // keep it
+	}
+	if err := os.Remove(fqn); err != nil {
+		glog.Errorf("Failed to remove replica %s that has moved, err: %v", fqn, err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// resumable cold GET: the object is downloaded into <dir>/.~~~.<base>.partial.<pid>;
+// if the download fails midway, the work file is kept along with its progress sidecar
+// <dir>/.~~~.<base>.progress.<pid> that records the object's version and size and the
+// offset downloaded and checksummed so far. The next cold GET (or prefetch) of the same
+// version of the object then re-checksums the downloaded part and requests the rest -
+// after a target restart as well: the new process adopts the previous process's work file.
+// Idle partial downloads expire after cold_get.partial_expire_time - see lru.go
+//
+//======
+
+const (
+	partialsep  = ".partial."
+	progresssep = ".progress."
+)
+
+// dlprogress is the progress sidecar of the partially downloaded object
+type dlprogress struct {
+	Version string `json:"version"` // the object's version in the Cloud: ETag, generation, etc.
+	Size    int64  `json:"size"`
+	Offset  int64  `json:"offset"` // downloaded and checksummed
+}
+
+// partialfqn returns the work file to download the object into
+func (t *targetrunner) partialfqn(fqn string) string {
+	dir, base := filepath.Split(fqn)
+	return dir + workfileprefix + base + partialsep + t.uxprocess.spid
+}
+
+// globescaper escapes the glob metacharacters
+var globescaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+// adoptpartial renames the object's interrupted download left by a previous target process,
+// if any, to become this process's work file - must be called under the object's lock
+func (t *targetrunner) adoptpartial(fqn, workfqn string) {
+	if t.resumable(workfqn) {
+		return
+	}
+	dir, base := filepath.Split(fqn)
+	partials, err := filepath.Glob(globescaper.Replace(dir+workfileprefix+base+partialsep) + "*")
+	if err != nil {
+		glog.Errorf("Failed to look up interrupted downloads of %s, err: %v", fqn, err)
+		return
+	}
+	for _, partial := range partials {
+		if partial == workfqn || !t.resumable(partial) {
+			continue
+		}
+		if err := os.Rename(progressfqn(partial), progressfqn(workfqn)); err != nil {
+			glog.Errorf("Failed to adopt %s progress, err: %v", partial, err)
+			continue
+		}
+		if err := os.Rename(partial, workfqn); err != nil {
+			glog.Errorf("Failed to adopt %s, err: %v", partial, err)
+			removeprogress(workfqn)
+			continue
+		}
+		glog.Infof("Adopted interrupted download %s => %s", partial, workfqn)
+		return
+	}
+}
+
+func progressfqn(workfqn string) string {
+	i := strings.LastIndex(workfqn, partialsep)
+	assert(i > 0, workfqn)
+	return workfqn[:i] + progresssep + workfqn[i+len(partialsep):]
+}
+
+// resumable returns true if the work file holds an interrupted download
+func (t *targetrunner) resumable(workfqn string) bool {
+	_, err := os.Stat(progressfqn(workfqn))
+	return err == nil
+}
+
+// resumedownload returns the progress of the interrupted download of the object of a given
+// version and size or, if there is none, of the new download
+func (t *targetrunner) resumedownload(workfqn, version string, size int64) *dlprogress {
+	var (
+		dl       = &dlprogress{}
+		progress = progressfqn(workfqn)
+	)
+	if err := localLoad(progress, dl); err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to load %s, err: %v", progress, err)
+		}
+		return &dlprogress{Version: version, Size: size}
+	}
+	if dl.Version == version && dl.Size == size && dl.Offset < size {
+		if finfo, err := os.Stat(workfqn); err == nil && finfo.Size() >= dl.Offset {
+			return dl
+		}
+	}
+	glog.Infof("Discarding %s: version %s, size %d (the object's: version %s, size %d)",
+		workfqn, dl.Version, dl.Size, version, size)
+	removeprogress(workfqn)
+	return &dlprogress{Version: version, Size: size}
+}
+
+// openpartial creates the work file or, to resume the download, opens the work file and
+// feeds its downloaded part to the hash
+func (t *targetrunner) openpartial(workfqn string, dl *dlprogress, h hash.Hash, buf []byte) (file *os.File, err error) {
+	if dl == nil || dl.Offset == 0 {
+		return CreateFile(workfqn)
+	}
+	if file, err = os.OpenFile(workfqn, os.O_RDWR, 0644); err != nil {
+		return
+	}
+	if err = file.Truncate(dl.Offset); err == nil && h != nil {
+		_, err = io.CopyBuffer(h, io.NewSectionReader(file, 0, dl.Offset), buf)
+	}
+	if err == nil {
+		_, err = file.Seek(dl.Offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	glog.Infof("Resuming download %s at %d out of %d bytes", workfqn, dl.Offset, dl.Size)
+	return
+}
+
+// savepartial syncs the work file and saves the progress for the next cold GET to resume;
+// returns false if there is nothing to resume
+func (t *targetrunner) savepartial(workfqn string, file *os.File, dl *dlprogress, written int64) bool {
+	if dl == nil || written <= 0 {
+		return false
+	}
+	err := file.Sync()
+	if err == nil {
+		dl.Offset = written
+		jsbytes, _ := json.Marshal(dl)
+		err = ioutil.WriteFile(progressfqn(workfqn), jsbytes, 0644)
+	}
+	if err != nil {
+		glog.Errorf("Failed to save %s progress, err: %v", workfqn, err)
+		return false
+	}
+	if err = file.Close(); err != nil {
+		glog.Errorf("Nested: failed to close received file %s, err: %v", workfqn, err)
+	}
+	glog.Infof("Interrupted download %s: %d out of %d bytes", workfqn, written, dl.Size)
+	return true
+}
+
+func removeprogress(workfqn string) {
+	if err := os.Remove(progressfqn(workfqn)); err != nil && !os.IsNotExist(err) {
+		glog.Errorf("Failed to remove %s progress, err: %v", workfqn, err)
+	}
+}
+
+// partialexpired returns true if fqn is an interrupted download (or its progress)
+// that hasn't been resumed for longer than partial_expire_time
+func (t *targetrunner) partialexpired(fqn string, osfi os.FileInfo) bool {
+	if !ispartial(fqn) {
+		return false
+	}
+	return time.Since(osfi.ModTime()) >= ctx.config.ColdGet.PartialExpireTime
+}
+
+// ispartial returns true if fqn is the work file of a download or its progress
+func ispartial(fqn string) bool {
+	base := filepath.Base(fqn)
+	return strings.Contains(base, partialsep) || strings.Contains(base, progresssep)
+}
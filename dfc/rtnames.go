@@ -6,125 +6,180 @@
 package dfc
 
 import (
-	"math/rand"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/OneOfOne/xxhash"
 	"github.com/golang/glog"
 )
 
+//======
+//
+// name locks: read/write locks of object names sharded by name hash; a lock that
+// cannot be taken right away waits on the name's condition variable and is woken up
+// by the unlock (or downgrade) - no polling
+//
+//======
+
+const (
+	rtnameshards  = 64          // power of two
+	rtnamewaitlog = time.Second // log the lock owner if waited longer than that
+)
+
 type rtnamemap struct {
+	shards [rtnameshards]rtnameshard
+}
+
+type rtnameshard struct {
 	sync.Mutex
-	m    map[string]*pendinginfo
-	abrt chan struct{}
+	m map[string]*pendinginfo
 }
 
 // in-progress file/object GETs, PUTs and DELETEs
 type pendinginfo struct {
-	time.Time
+	time.Time // when locked by the (first) owner
 	fqn       string
 	rc        int
 	exclusive bool
+	waiters   int        // number of lockname() callers waiting for the lock
+	cond      *sync.Cond // created upon the first wait; uses the shard's mutex
 }
 
 //
 // methods
 //
 func (info *pendinginfo) String() string {
-	return info.fqn
+	if info.exclusive {
+		return fmt.Sprintf("%s: exclusive since %s, %d waiting", info.fqn, info.Format(time.RFC3339Nano), info.waiters)
+	}
+	return fmt.Sprintf("%s: shared(%d) since %s, %d waiting", info.fqn, info.rc, info.Format(time.RFC3339Nano), info.waiters)
+}
+
+// trylock must be called under the shard's lock
+func (info *pendinginfo) trylock(exclusive bool, owner *pendinginfo) bool {
+	if info.exclusive || (exclusive && info.rc > 0) {
+		return false
+	}
+	if info.rc == 0 {
+		info.Time, info.fqn = owner.Time, owner.fqn
+	}
+	if exclusive {
+		info.exclusive = true
+	} else {
+		info.rc++
+	}
+	return true
+}
+
+// wakeup must be called under the shard's lock; returns false if the name is unused
+func (info *pendinginfo) wakeup() bool {
+	if info.waiters > 0 {
+		info.cond.Broadcast()
+		return true
+	}
+	return info.rc > 0 || info.exclusive
 }
 
 func newrtnamemap(size int) *rtnamemap {
-	m := make(map[string]*pendinginfo, size)
-	return &rtnamemap{m: m, abrt: make(chan struct{})}
+	rtnamemap := &rtnamemap{}
+	for i := range rtnamemap.shards {
+		rtnamemap.shards[i].m = make(map[string]*pendinginfo, size/rtnameshards+1)
+	}
+	return rtnamemap
+}
+
+func (rtnamemap *rtnamemap) shard(name string) *rtnameshard {
+	return &rtnamemap.shards[xxhash.ChecksumString32S(name, mLCG32)&(rtnameshards-1)]
+}
+
+// lookup returns the name's lock info adding the new one if need be; must be called under the shard's lock
+func (s *rtnameshard) lookup(name string, info *pendinginfo) *pendinginfo {
+	realinfo, found := s.m[name]
+	if !found {
+		info.rc, info.exclusive, info.waiters = 0, false, 0
+		s.m[name] = info
+		realinfo = info
+	}
+	return realinfo
 }
 
 func (rtnamemap *rtnamemap) trylockname(name string, exclusive bool, info *pendinginfo) bool {
-	rtnamemap.Lock()
-	defer rtnamemap.Unlock()
+	s := rtnamemap.shard(name)
+	s.Lock()
+	defer s.Unlock()
 
-	_, found := rtnamemap.m[name]
-	if found && exclusive {
-		return false
+	return s.lookup(name, info).trylock(exclusive, info)
+}
+
+// FIXME: TODO: support timeout
+func (rtnamemap *rtnamemap) lockname(name string, exclusive bool, info *pendinginfo) {
+	s := rtnamemap.shard(name)
+	s.Lock()
+	defer s.Unlock()
+
+	realinfo := s.lookup(name, info)
+	if realinfo.trylock(exclusive, info) {
+		return
 	}
-	if exclusive {
-		rtnamemap.m[name] = info
-		info.exclusive = true
-		info.rc = 0
-		return true
+	if realinfo.cond == nil {
+		realinfo.cond = sync.NewCond(&s.Mutex)
 	}
-	// rlock
-	var realinfo *pendinginfo
-	if found {
-		realinfo = rtnamemap.m[name]
-		if realinfo.exclusive {
-			return false
+	started, owner := time.Now(), realinfo.String()
+	realinfo.waiters++
+	for {
+		realinfo.cond.Wait()
+		if realinfo.trylock(exclusive, info) {
+			break
 		}
-	} else {
-		rtnamemap.m[name] = info // the 1st rlock
-		realinfo = info
-		realinfo.exclusive = false
-		realinfo.rc = 0
 	}
-	realinfo.rc++
-	return true
+	realinfo.waiters--
+	if waited := time.Since(started); waited > rtnamewaitlog {
+		glog.Infof("rtnamemap: lockname %s (%v) waited %v for %s", info.fqn, exclusive, waited, owner)
+	}
 }
 
 func (rtnamemap *rtnamemap) downgradelock(name string) {
-	rtnamemap.Lock()
-	defer rtnamemap.Unlock()
+	s := rtnamemap.shard(name)
+	s.Lock()
+	defer s.Unlock()
 
-	info, found := rtnamemap.m[name]
+	info, found := s.m[name]
 	assert(found)
+	assert(info.exclusive)
 	info.exclusive = false
 	info.rc++
 	assert(info.rc == 1)
+	info.wakeup() // readers
 }
 
 func (rtnamemap *rtnamemap) unlockname(name string, exclusive bool) {
-	rtnamemap.Lock()
-	defer rtnamemap.Unlock()
+	s := rtnamemap.shard(name)
+	s.Lock()
+	defer s.Unlock()
 
-	info, ok := rtnamemap.m[name]
+	info, ok := s.m[name]
 	assert(ok)
 	if exclusive {
 		assert(info.exclusive)
-		delete(rtnamemap.m, name)
-		return
-	}
-	info.rc--
-	if info.rc == 0 {
-		delete(rtnamemap.m, name)
-	}
-}
-
-// FIXME: TODO: support timeout
-func (rtnamemap *rtnamemap) lockname(name string, exclusive bool, info *pendinginfo, poll time.Duration) {
-	if rtnamemap.trylockname(name, exclusive, info) {
-		return
+		info.exclusive = false
+	} else {
+		assert(info.rc > 0)
+		info.rc--
 	}
-	ticker := time.NewTicker(poll)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if rtnamemap.trylockname(name, exclusive, info) {
-				glog.Infof("rtnamemap: lockname %s (%v) done", info.fqn, exclusive)
-				return
-			}
-			glog.Infof("rtnamemap: lockname %s (%v) - retrying...", info.fqn, exclusive)
-			time.Sleep(time.Millisecond * time.Duration(rand.Intn(10)+1))
-		case <-rtnamemap.abrt:
-			return
-		}
+	if !info.wakeup() {
+		delete(s.m, name)
 	}
 }
 
 // log pending
 func (rtnamemap *rtnamemap) log() {
-	rtnamemap.Lock()
-	defer rtnamemap.Unlock()
-	for name, info := range rtnamemap.m {
-		glog.Infof("rtnamemap: %s => %s", name, info.String())
+	for i := range rtnamemap.shards {
+		s := &rtnamemap.shards[i]
+		s.Lock()
+		for name, info := range s.m {
+			glog.Infof("rtnamemap: %s => %s", name, info.String())
+		}
+		s.Unlock()
 	}
 }
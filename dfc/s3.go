@@ -0,0 +1,435 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// S3-compatible REST front-end (path-style addressing only):
+//
+//   GET    /s3/                               ListBuckets
+//   GET    /s3/bucket-name?list-type=2        ListObjectsV2
+//   GET    /s3/bucket-name/object-name        GetObject
+//   PUT    /s3/bucket-name/object-name        PutObject
+//   HEAD   /s3/bucket-name/object-name        HeadObject
+//   DELETE /s3/bucket-name/object-name        DeleteObject
+//   POST   /s3/bucket-name?delete             DeleteObjects
+//
+// Object operations are redirected to the HRW target via the native /v1/objects API;
+// bucket listings are executed by the proxy and converted from BucketList to XML.
+
+const (
+	s3Namespace    = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3TimeFormat   = "2006-01-02T15:04:05.000Z"
+	s3StorageClass = "STANDARD"
+	s3MaxKeys      = 1000
+	s3MaxDelete    = 1000
+)
+
+// S3 URL query parameters
+const (
+	s3ParamListType      = "list-type"
+	s3ParamPrefix        = "prefix"
+	s3ParamDelimiter     = "delimiter"
+	s3ParamMaxKeys       = "max-keys"
+	s3ParamContToken     = "continuation-token"
+	s3ParamStartAfter    = "start-after"
+	s3ParamDeleteObjects = "delete"
+)
+
+// S3 error codes
+const (
+	s3ErrNoSuchBucket     = "NoSuchBucket"
+	s3ErrInvalidArgument  = "InvalidArgument"
+	s3ErrInvalidRequest   = "InvalidRequest"
+	s3ErrMalformedXML     = "MalformedXML"
+	s3ErrNotImplemented   = "NotImplemented"
+	s3ErrInternalError    = "InternalError"
+	s3ErrServiceUnavail   = "ServiceUnavailable"
+	s3ErrMethodNotAllowed = "MethodNotAllowed"
+)
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified,omitempty"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListObjectsV2Result struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int              `xml:"KeyCount"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3DeleteObject struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+}
+
+type s3DeleteRequest struct {
+	XMLName xml.Name         `xml:"Delete"`
+	Quiet   bool             `xml:"Quiet"`
+	Objects []s3DeleteObject `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string `xml:"Key"`
+}
+
+type s3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type s3DeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []s3Deleted     `xml:"Deleted"`
+	Errors  []s3DeleteError `xml:"Error"`
+}
+
+//===========================================================================
+//
+// S3 front-end: proxy handlers
+//
+//===========================================================================
+
+// verb /Rs3/
+func (p *proxyrunner) s3hdlr(w http.ResponseWriter, r *http.Request) {
+	apitems := p.restAPIItems(r.URL.Path, 4) // "", Rs3, bucket, object
+	if len(apitems) == 0 || apitems[0] != Rs3 {
+		p.s3errhdlr(w, r, s3ErrInvalidRequest, "Invalid S3 API path "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	apitems = apitems[1:]
+	if p.smap.count() < 1 {
+		p.s3errhdlr(w, r, s3ErrServiceUnavail, "No registered targets yet", http.StatusServiceUnavailable)
+		return
+	}
+	switch len(apitems) {
+	case 0:
+		if r.Method != http.MethodGet {
+			p.s3errhdlr(w, r, s3ErrMethodNotAllowed, "Unsupported method "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+		p.s3listbuckets(w, r)
+	case 1:
+		bucket := apitems[0]
+		switch r.Method {
+		case http.MethodGet:
+			p.s3listobjects(w, r, bucket)
+		case http.MethodPost:
+			if _, ok := r.URL.Query()[s3ParamDeleteObjects]; !ok {
+				p.s3errhdlr(w, r, s3ErrNotImplemented, "Unsupported bucket operation", http.StatusNotImplemented)
+				return
+			}
+			p.s3deleteobjects(w, r, bucket)
+		default:
+			p.s3errhdlr(w, r, s3ErrNotImplemented, "Unsupported bucket operation "+r.Method, http.StatusNotImplemented)
+		}
+	default:
+		bucket, objname := apitems[0], apitems[1]
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			p.s3redirect(w, r, bucket, objname, http.StatusMovedPermanently)
+		case http.MethodPut, http.MethodDelete:
+			p.s3redirect(w, r, bucket, objname, http.StatusTemporaryRedirect)
+		default:
+			p.s3errhdlr(w, r, s3ErrNotImplemented, "Unsupported object operation "+r.Method, http.StatusNotImplemented)
+		}
+	}
+}
+
+// GET /Rs3/
+func (p *proxyrunner) s3listbuckets(w http.ResponseWriter, r *http.Request) {
+	var (
+		si          *daemonInfo
+		bucketnames = &BucketNames{}
+	)
+	for _, si = range p.smap.Smap {
+		break
+	}
+	url := si.DirectURL + "/" + Rversion + "/" + Rbuckets + "/*"
+	outjson, err, errstr, status := p.call(si, url, http.MethodGet, nil)
+	if err != nil {
+		p.kalive.onerr(err, status)
+		p.s3errhdlr(w, r, s3ErrInternalError, errstr, http.StatusInternalServerError)
+		return
+	}
+	if err = json.Unmarshal(outjson, bucketnames); err != nil {
+		s := fmt.Sprintf("Failed to unmarshal bucket names, err: %v", err)
+		p.s3errhdlr(w, r, s3ErrInternalError, s, http.StatusInternalServerError)
+		return
+	}
+	created := p.starttime.UTC().Format(s3TimeFormat)
+	result := &s3ListBucketsResult{
+		Xmlns:   s3Namespace,
+		Owner:   s3Owner{ID: p.si.DaemonID, DisplayName: p.si.DaemonID},
+		Buckets: make([]s3Bucket, 0, len(bucketnames.Cloud)+len(bucketnames.Local)),
+	}
+	for _, bucket := range bucketnames.Local {
+		result.Buckets = append(result.Buckets, s3Bucket{Name: bucket, CreationDate: created})
+	}
+	for _, bucket := range bucketnames.Cloud {
+		result.Buckets = append(result.Buckets, s3Bucket{Name: bucket, CreationDate: created})
+	}
+	p.writeXML(w, r, result, "s3listbuckets")
+}
+
+// GET /Rs3/bucket-name?list-type=2
+func (p *proxyrunner) s3listobjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	var (
+		allentries *BucketList
+		started    = time.Now()
+		query      = r.URL.Query()
+		maxkeys    = s3MaxKeys
+	)
+	if lt := query.Get(s3ParamListType); lt != "" && lt != "2" {
+		p.s3errhdlr(w, r, s3ErrNotImplemented, "Only ListObjectsV2 (list-type=2) is supported", http.StatusNotImplemented)
+		return
+	}
+	if s := query.Get(s3ParamMaxKeys); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			p.s3errhdlr(w, r, s3ErrInvalidArgument, "Invalid max-keys "+s, http.StatusBadRequest)
+			return
+		}
+		if n < maxkeys {
+			maxkeys = n
+		}
+	}
+	result := &s3ListObjectsV2Result{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            query.Get(s3ParamPrefix),
+		Delimiter:         query.Get(s3ParamDelimiter),
+		StartAfter:        query.Get(s3ParamStartAfter),
+		ContinuationToken: query.Get(s3ParamContToken),
+		MaxKeys:           maxkeys,
+		Contents:          []s3Object{},
+	}
+	if maxkeys == 0 {
+		p.writeXML(w, r, result, "s3listobjects")
+		return
+	}
+	msg := &GetMsg{
+		GetProps:      strings.Join([]string{GetPropsSize, GetPropsCtime, GetPropsChecksum, GetPropsVersion}, ","),
+		GetTimeFormat: RFC3339,
+		GetPrefix:     result.Prefix,
+		GetDelimiter:  result.Delimiter,
+		GetPageMarker: result.StartAfter,
+		GetPageSize:   maxkeys,
+	}
+	if result.ContinuationToken != "" {
+		msg.GetPageMarker = result.ContinuationToken
+	}
+	listmsgjson, err := json.Marshal(msg)
+	assert(err == nil, err)
+	if p.islocalBucket(bucket) {
+		allentries, err = p.getLocalBucketObjects(bucket, listmsgjson)
+	} else {
+		allentries, err = p.getCloudBucketObjects(bucket, listmsgjson)
+	}
+	if err != nil {
+		p.s3errhdlr(w, r, s3ErrNoSuchBucket, err.Error(), http.StatusNotFound)
+		return
+	}
+	for _, entry := range allentries.Entries {
+		obj := s3Object{Key: entry.Name, Size: entry.Size, StorageClass: s3StorageClass}
+		if entry.Ctime != "" {
+			if t, err := time.Parse(RFC3339, entry.Ctime); err == nil {
+				obj.LastModified = t.UTC().Format(s3TimeFormat)
+			}
+		}
+		if entry.Checksum != "" {
+			obj.ETag = strconv.Quote(entry.Checksum)
+		}
+		result.Contents = append(result.Contents, obj)
+	}
+	for _, cp := range allentries.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: cp})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+	if allentries.PageMarker != "" {
+		result.IsTruncated = true
+		result.NextContinuationToken = allentries.PageMarker
+	}
+	if p.writeXML(w, r, result, "s3listobjects") {
+		lat := int64(time.Since(started) / 1000)
+		p.statsif.addMany("numlist", int64(1), "listlatency", lat)
+		if glog.V(3) {
+			glog.Infof("S3 LIST: %s, %d keys, %d µs", bucket, result.KeyCount, lat)
+		}
+	}
+}
+
+// GET|PUT|HEAD|DELETE /Rs3/bucket-name/object-name => /Rversion/Robjects/bucket-name/object-name
+func (p *proxyrunner) s3redirect(w http.ResponseWriter, r *http.Request, bucket, objname string, status int) {
+	started := time.Now()
+	si, errstr := hrwTarget(bucket+"/"+objname, p.smap)
+	if errstr != "" {
+		p.s3errhdlr(w, r, s3ErrInternalError, errstr, http.StatusInternalServerError)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/"+Rs3)
+	redirecturl := fmt.Sprintf("%s/%s/%s%s?%s=%t", si.DirectURL, Rversion, Robjects, path, URLParamLocal, p.islocalBucket(bucket))
+	if glog.V(4) {
+		glog.Infof("S3 %s %s/%s => %s", r.Method, bucket, objname, si.DaemonID)
+	}
+	http.Redirect(w, r, redirecturl, status)
+	switch r.Method {
+	case http.MethodGet:
+		p.statsif.addMany("numget", int64(1), "getlatency", int64(time.Since(started)/1000))
+	case http.MethodPut:
+		p.statsif.addMany("numput", int64(1), "putlatency", int64(time.Since(started)/1000))
+	case http.MethodDelete:
+		p.statsif.add("numdelete", 1)
+	}
+}
+
+// POST /Rs3/bucket-name?delete
+// Deletes each object by calling its HRW target directly; the result carries per-key status.
+func (p *proxyrunner) s3deleteobjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	var req s3DeleteRequest
+	b, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = xml.Unmarshal(b, &req)
+	}
+	if err != nil {
+		p.s3errhdlr(w, r, s3ErrMalformedXML, fmt.Sprintf("Failed to parse request, err: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > s3MaxDelete {
+		s := fmt.Sprintf("Number of objects to delete must be in the range [1, %d]", s3MaxDelete)
+		p.s3errhdlr(w, r, s3ErrMalformedXML, s, http.StatusBadRequest)
+		return
+	}
+	var (
+		islocal = p.islocalBucket(bucket)
+		errs    = make([]string, len(req.Objects))
+		wg      = &sync.WaitGroup{}
+	)
+	for i, obj := range req.Objects {
+		wg.Add(1)
+		go func(i int, objname string) {
+			defer wg.Done()
+			si, errstr := hrwTarget(bucket+"/"+objname, p.smap)
+			if errstr != "" {
+				errs[i] = errstr
+				return
+			}
+			url := fmt.Sprintf("%s/%s/%s/%s/%s?%s=%t", si.DirectURL, Rversion, Robjects, bucket, objname, URLParamLocal, islocal)
+			if _, err, errstr, status := p.call(si, url, http.MethodDelete, nil); err != nil {
+				p.kalive.onerr(err, status)
+				errs[i] = errstr
+			}
+		}(i, obj.Key)
+	}
+	wg.Wait()
+
+	result := &s3DeleteResult{Xmlns: s3Namespace}
+	for i, obj := range req.Objects {
+		if errs[i] != "" {
+			result.Errors = append(result.Errors, s3DeleteError{Key: obj.Key, Code: s3ErrInternalError, Message: errs[i]})
+			continue
+		}
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, s3Deleted{Key: obj.Key})
+		}
+	}
+	p.statsif.add("numdelete", int64(len(req.Objects)-len(result.Errors)))
+	p.writeXML(w, r, result, "s3deleteobjects")
+}
+
+//===========================================================================
+//
+// S3 front-end: helpers
+//
+//===========================================================================
+
+func (p *proxyrunner) writeXML(w http.ResponseWriter, r *http.Request, v interface{}, tag string) (ok bool) {
+	xmlbytes, err := xml.Marshal(v)
+	assert(err == nil, err)
+	w.Header().Set("Content-Type", "application/xml")
+	if _, err = w.Write([]byte(xml.Header)); err == nil {
+		_, err = w.Write(xmlbytes)
+	}
+	if err != nil {
+		glog.Errorf("%s: failed to write xml, err: %v", tag, err)
+		p.statsif.add("numerr", 1)
+		return
+	}
+	ok = true
+	return
+}
+
+func (p *proxyrunner) s3errhdlr(w http.ResponseWriter, r *http.Request, code, specific string, status int) {
+	s := http.StatusText(status) + ": " + specific
+	s += ": " + r.Method + " " + r.URL.Path + " from " + r.RemoteAddr
+	glog.Errorln(s)
+	glog.Flush()
+	xmlbytes, err := xml.Marshal(&s3Error{Code: code, Message: specific, Resource: r.URL.Path})
+	assert(err == nil, err)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(xml.Header))
+		w.Write(xmlbytes)
+	}
+	p.statsif.add("numerr", 1)
+}
@@ -0,0 +1,29 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"io"
+	"net/http"
+)
+
+//======
+//
+// zero-copy GET: the entire object is handed over to the HTTP/1.x response's
+// io.ReaderFrom which, given the Content-Length (no chunking), passes the file
+// on to the TCP connection's ReadFrom - sendfile(2) on Linux. HTTPS and HTTP/2
+// (h2c) GETs and range GETs are copied through the slab buffer as before
+//
+//======
+
+// zerocopy returns the response's io.ReaderFrom if the object can be sent zero-copy
+func zerocopy(w http.ResponseWriter, r *http.Request) (rf io.ReaderFrom, ok bool) {
+	if ctx.config.Net.HTTP.UseHTTPS || r.TLS != nil || r.ProtoMajor != 1 {
+		return
+	}
+	rf, ok = w.(io.ReaderFrom)
+	return
+}
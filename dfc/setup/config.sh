@@ -3,6 +3,7 @@
 	"confdir":                	"$CONFDIR",
 	"cloudprovider":		"${CLDPROVIDER}",
 	"cloud_buckets":		"cloud",
+	"cloud_fs_root":		"${CLDFSROOT}",
 	"local_buckets":		"local",
 	"log": {
 		"logdir":		"$LOGDIR",
@@ -39,6 +40,7 @@
 		"atime_cache_max":	65536,
 		"dont_evict_time":	"120m",
 		"capacity_upd_time":	"10m",
+		"multipart_expire_time":	"24h",
 		"lru_enabled":  	true
 	},
 	"rebalance_conf": {
@@ -49,6 +51,11 @@
                  "checksum":		"xxhash",
                  "validate_cold_get":	true
 	},
+	"cold_get": {
+		"part_size":		16777216,
+		"concurrency":		8,
+		"partial_expire_time":	"1h"
+	},
 	"version_config": {
 		"validate_warm_get":	false,
 		"versioning":		"all"
@@ -81,7 +88,16 @@ $FSPATHS
 	},
 	"experimental": {
 		"ack_put":		"disk",
-		"max_mem_mb":		16
+		"max_mem_mb":		16,
+		"tee_cold_get":		true
+	},
+	"mock_cloud": {
+		"buckets":		["mockbucket"],
+		"versioning":		true,
+		"page_size":		1000,
+		"latency":		"0s",
+		"error_pct":		0,
+		"abort_after":		0
 	},
 	"h2c": 				false
 }
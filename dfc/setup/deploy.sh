@@ -73,6 +73,9 @@ TESTFSPATHCOUNT=$testfspathcnt
 echo Select Cloud Provider:
 echo  1: Amazon Cloud
 echo  2: Google Cloud
+echo  3: Azure Cloud
+echo  4: Local filesystem
+echo  5: In-memory mock Cloud
 echo Enter your choice:
 read cldprovider
 if [ $cldprovider -eq 1 ]
@@ -81,8 +84,21 @@ then
 elif [ $cldprovider -eq 2 ]
 then
 	CLDPROVIDER="gcp"
+elif [ $cldprovider -eq 3 ]
+then
+	CLDPROVIDER="azure"
+elif [ $cldprovider -eq 4 ]
+then
+	CLDPROVIDER="fs"
+	echo "Root directory of the filesystem-based buckets (default $LOGROOT/cloudfs):"
+	read CLDFSROOT
+	CLDFSROOT=${CLDFSROOT:-$LOGROOT/cloudfs}
+	mkdir -p $CLDFSROOT
+elif [ $cldprovider -eq 5 ]
+then
+	CLDPROVIDER="mock"
 else
-	echo "Error: '$cldprovider' is not a valid input, can be either 1 or 2"; exit 1
+	echo "Error: '$cldprovider' is not a valid input, can be 1 through 5"; exit 1
 fi
 
 mkdir -p $CONFDIR
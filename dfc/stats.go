@@ -52,6 +52,7 @@ type proxyCoreStats struct {
 	Numpost     int64 `json:"numpost"`
 	Numdelete   int64 `json:"numdelete"`
 	Numrename   int64 `json:"numrename"`
+	Numcopy     int64 `json:"numcopy"`
 	Numlist     int64 `json:"numlist"`
 	Getlatency  int64 `json:"getlatency"`  // microseconds
 	Putlatency  int64 `json:"putlatency"`  // ---/---
@@ -80,6 +81,8 @@ type targetCoreStats struct {
 	Bytesvchanged    int64 `json:"bytesvchanged"`
 	Numbadchecksum   int64 `json:"numbadchecksum"`
 	Bytesbadchecksum int64 `json:"bytesbadchecksum"`
+	Numecrestore     int64 `json:"numecrestore"`
+	Numreplrestore   int64 `json:"numreplrestore"`
 }
 
 type statsrunner struct {
@@ -247,6 +250,8 @@ func (r *proxystatsrunner) addLocked(name string, val int64) {
 		v = &s.Numdelete
 	case "numrename":
 		v = &s.Numrename
+	case "numcopy":
+		v = &s.Numcopy
 	case "numlist":
 		v = &s.Numlist
 	case "getlatency":
@@ -348,7 +353,7 @@ func (r *storstatsrunner) log() (runlru bool) {
 func (r *storstatsrunner) housekeep(runlru bool) {
 	t := gettarget()
 
-	if runlru && ctx.config.LRU.LRUEnabled {
+	if runlru {
 		go t.runLRU()
 	}
 
@@ -425,7 +430,9 @@ func (r *storstatsrunner) removeOlderLogs(tot, maxtotal int64, filteredInfos []o
 	}
 }
 
+// updateCapacity returns true if LRU must run - see also lruhighwm
 func (r *storstatsrunner) updateCapacity() (runlru bool) {
+	hwm, enabled := gettarget().lruhighwm()
 	for _, mpath := range r.fsmap {
 		statfs := &syscall.Statfs_t{}
 		if err := syscall.Statfs(mpath, statfs); err != nil {
@@ -434,7 +441,7 @@ func (r *storstatsrunner) updateCapacity() (runlru bool) {
 		}
 		fscapacity := r.Capacity[mpath]
 		r.fillfscap(fscapacity, statfs)
-		if fscapacity.Usedpct >= ctx.config.LRU.HighWM {
+		if enabled && fscapacity.Usedpct >= hwm {
 			runlru = true
 		}
 	}
@@ -508,6 +515,8 @@ func (r *storstatsrunner) addLocked(name string, val int64) {
 		v = &s.Numdelete
 	case "numrename":
 		v = &s.Numrename
+	case "numcopy":
+		v = &s.Numcopy
 	case "numlist":
 		v = &s.Numlist
 	case "getlatency":
@@ -550,6 +559,10 @@ func (r *storstatsrunner) addLocked(name string, val int64) {
 		v = &s.Numbadchecksum
 	case "bytesbadchecksum":
 		v = &s.Bytesbadchecksum
+	case "numecrestore":
+		v = &s.Numecrestore
+	case "numreplrestore":
+		v = &s.Numreplrestore
 	default:
 		assert(false, "Invalid stats name "+name)
 	}
@@ -10,13 +10,14 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,7 +41,7 @@ type mountPath struct {
 }
 
 type allfinfos struct {
-	files        []*BucketEntry
+	files        []*listitem
 	fileCount    int
 	rootLength   int
 	prefix       string
@@ -55,6 +56,11 @@ type allfinfos struct {
 	t            *targetrunner
 	bucket       string
 	limit        int
+	delimiter    string
+	prefixes     map[string]bool // common prefixes - see GetMsg.GetDelimiter
+	sort         *listsort
+	after        *listitem   // page marker
+	filter       *listfilter // regex, range, size and ctime filters, if any
 }
 
 type uxprocess struct {
@@ -76,15 +82,19 @@ type targetrunner struct {
 	lbmap         *lbmap
 	rtnamemap     *rtnamemap
 	prefetchQueue chan filesWithDeadline
+	mpuploads     *mpuploads // multipart uploads in progress
+	coldgets      *coldgets  // tee cold GETs in progress
 }
 
 // start target runner
 func (t *targetrunner) run() error {
 	t.httprunner.init(getstorstatsrunner(), false)
 	t.httprunner.kalive = gettargetkalive()
-	t.xactinp = newxactinp()                         // extended actions
-	t.lbmap = &lbmap{LBmap: make(map[string]string)} // local (cache-only) buckets
-	t.rtnamemap = newrtnamemap(128)                  // lock/unlock name
+	t.xactinp = newxactinp()                              // extended actions
+	t.lbmap = &lbmap{LBmap: make(map[string]BucketProps)} // local (cache-only) buckets
+	t.rtnamemap = newrtnamemap(128)                       // lock/unlock name
+	t.mpuploads = newmpuploads()                          // multipart uploads
+	t.coldgets = newcoldgets()                            // tee cold GETs in progress
 
 	if status, err := t.register(0); err != nil {
 		glog.Errorf("Target %s failed to register with proxy, err: %v", t.si.DaemonID, err)
@@ -105,13 +115,9 @@ func (t *targetrunner) run() error {
 	t.startupMpaths()
 
 	// cloud provider
-	if ctx.config.CloudProvider == ProviderAmazon {
-		// TODO: sessions
-		t.cloudif = &awsimpl{t}
-
-	} else {
-		assert(ctx.config.CloudProvider == ProviderGoogle)
-		t.cloudif = &gcpimpl{t}
+	var errstr string
+	if t.cloudif, errstr = newCloudProvider(ctx.config.CloudProvider, t); errstr != "" {
+		return fmt.Errorf("%s", errstr)
 	}
 	// init capacity
 	rr := getstorstatsrunner()
@@ -127,6 +133,7 @@ func (t *targetrunner) run() error {
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon, t.daemonhdlr)
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon+"/", t.daemonhdlr) // FIXME
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rpush+"/", t.pushhdlr)
+	t.httprunner.registerhdlr("/"+Rversion+"/"+Rslices+"/", t.slicehdlr)
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rhealth, t.httphealth)
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rvote+"/", t.votehdlr)
 	t.httprunner.registerhdlr("/", invalhdlr)
@@ -141,7 +148,6 @@ func (t *targetrunner) run() error {
 func (t *targetrunner) stop(err error) {
 	glog.Infof("Stopping %s, err: %v", t.name, err)
 	sleep := t.xactinp.abortAll()
-	close(t.rtnamemap.abrt)
 	if t.httprunner.h != nil {
 		t.unregister() // ignore errors
 	}
@@ -224,6 +230,8 @@ func (t *targetrunner) objecthdlr(w http.ResponseWriter, r *http.Request) {
 		t.httpobjdelete(w, r)
 	case http.MethodPost:
 		t.httpobjpost(w, r)
+	case http.MethodHead:
+		t.httpobjhead(w, r)
 	default:
 		invalhdlr(w, r)
 	}
@@ -274,8 +282,6 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 		coldget, vchanged      bool
 	)
 	started = time.Now()
-	cksumcfg := &ctx.config.Cksum
-	versioncfg := &ctx.config.Ver
 	apitems := t.restAPIItems(r.URL.Path, 5)
 	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
 		return
@@ -291,11 +297,45 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 		t.invalmsghdlr(w, r, errstr, errcode)
 		return
 	}
+	if islocal && isreplicareq(r) {
+		t.httpreplicaget(w, r, bucket, objname)
+		return
+	}
+	if r.URL.Query().Get(URLParamWhat) == GetWhatLocate {
+		t.httpobjlocate(w, r, bucket, objname, islocal)
+		return
+	}
+	cksumcfg, versioncfg := t.cksumconf(bucket), t.versionconf(bucket)
+	fqn, uname = t.fqn(bucket, objname), t.uname(bucket, objname)
+	// erasure coded local bucket: restore the missing object from its slices;
+	// replicated local bucket: from one of its replicas
+	if islocal {
+		if _, enabled := t.ecconf(bucket); enabled {
+			errstr, errcode = t.ecrestore(bucket, objname, fqn)
+		} else if copies, _ := t.copies(bucket); copies > 1 {
+			errstr, errcode = t.replicacheck(bucket, objname, fqn)
+		}
+		// not present in this cluster: GET from the next tier, if configured
+		if nexttier := t.nexttier(bucket); nexttier != "" && (errstr == "" || errcode == http.StatusNotFound) {
+			errstr, errcode = t.nexttierget(bucket, objname, fqn, nexttier)
+		}
+		if errstr != "" {
+			t.invalmsghdlr(w, r, errstr, errcode)
+			return
+		}
+	}
+	// tee cold GET in progress: attach
+	tee := t.teeget(r, islocal)
+	if tee {
+		if c := t.coldgets.attach(uname); c != nil {
+			t.teesend(w, r, c, bucket, objname, started)
+			return
+		}
+	}
 	//
 	// lockname(ro)
 	//
-	fqn, uname = t.fqn(bucket, objname), t.uname(bucket, objname)
-	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
 	// existence, access & versioning
 	if coldget, size, version, errstr = t.isObjectCached(bucket, objname, fqn); errstr != "" {
 		t.runFSKeeper(fmt.Errorf("%s", fqn))
@@ -314,7 +354,11 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 	}
 	if coldget {
 		t.rtnamemap.unlockname(uname, false)
-		if props, errstr, errcode = t.coldget(bucket, objname, false); errstr != "" {
+		if tee {
+			t.teesend(w, r, t.teecoldget(bucket, objname), bucket, objname, started)
+			return
+		}
+		if props, errstr, errcode = t.coldget(bucket, objname, false, nil); errstr != "" {
 			if errcode == 0 {
 				t.invalmsghdlr(w, r, errstr)
 			} else {
@@ -342,7 +386,35 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 			nhobj = newcksumvalue(cksumcfg.Checksum, string(hashbinary))
 		}
 	}
-	if nhobj != nil {
+	// conditional GET
+	if props != nil {
+		version = props.version
+	}
+	if etag := objetag(nhobj, version); etag != "" {
+		w.Header().Set(headerETag, etag)
+	}
+	if finfo, err := os.Stat(fqn); err == nil {
+		w.Header().Set(headerLastModified, finfo.ModTime().UTC().Format(http.TimeFormat))
+		if status := checkpreconds(r, w.Header().Get(headerETag), finfo.ModTime()); status == http.StatusNotModified {
+			w.WriteHeader(status)
+			return
+		} else if status != 0 {
+			errstr = fmt.Sprintf("GET %s/%s: precondition failed", bucket, objname)
+			t.invalmsghdlr(w, r, errstr, status)
+			return
+		}
+	}
+	// note: cold GET has already fetched the entire object - serve the requested range(s) only
+	var ranges []httpRange
+	if rangehdr := r.Header.Get(headerRange); rangehdr != "" {
+		if ranges, errstr = parseHTTPRange(rangehdr, size); errstr != "" {
+			w.Header().Set(headerContentRange, fmt.Sprintf("bytes */%d", size))
+			t.invalmsghdlr(w, r, errstr, http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+	}
+	// checksum is computed over the entire object and is not returned with partial content
+	if nhobj != nil && len(ranges) == 0 {
 		htype, hval := nhobj.get()
 		w.Header().Add(HeaderDfcChecksumType, htype)
 		w.Header().Add(HeaderDfcChecksumVal, hval)
@@ -350,6 +422,7 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 	if props != nil && props.version != "" {
 		w.Header().Add(HeaderDfcObjVersion, props.version)
 	}
+	objmetaToHeader(w.Header(), t.objmeta(fqn))
 
 	file, err := os.Open(fqn)
 	if err != nil {
@@ -364,11 +437,21 @@ func (t *targetrunner) httpobjget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	defer file.Close()
-	slab := selectslab(size)
-	buf := slab.alloc()
-	defer slab.free(buf)
 	// copy
-	written, err := io.CopyBuffer(w, file, buf)
+	var written int64
+	if rf, ok := zerocopy(w, r); ok && len(ranges) == 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		written, err = rf.ReadFrom(file)
+	} else {
+		slab := selectslab(size)
+		buf := slab.alloc()
+		if len(ranges) > 0 {
+			written, err = t.sendranges(w, file, ranges, size, buf)
+		} else {
+			written, err = io.CopyBuffer(w, file, buf)
+		}
+		slab.free(buf)
+	}
 	if err != nil {
 		errstr = fmt.Sprintf("Failed to send file %s, err: %v", fqn, err)
 		t.invalmsghdlr(w, r, errstr)
@@ -407,6 +490,9 @@ func (t *targetrunner) httpobjput(w http.ResponseWriter, r *http.Request) {
 		if errstr := t.dorebalance(r, from, to, bucket, objname); errstr != "" {
 			t.invalmsghdlr(w, r, errstr)
 		}
+	} else if uploadid := query.Get(URLParamUploadID); uploadid != "" {
+		// multipart upload: PUT part
+		t.mpputpart(w, r, bucket, objname, uploadid)
 	} else {
 		// PUT
 		errstr, errcode := t.doput(w, r, bucket, objname)
@@ -477,6 +563,10 @@ func (t *targetrunner) httpobjdelete(w http.ResponseWriter, r *http.Request) {
 	}
 	bucket = apitems[0]
 	objname = strings.Join(apitems[1:], "/")
+	if isreplicareq(r) {
+		t.httpreplicadelete(w, r, bucket, objname)
+		return
+	}
 
 	b, err := ioutil.ReadAll(r.Body)
 	defer func() {
@@ -522,6 +612,10 @@ func (t *targetrunner) httpbckpost(w http.ResponseWriter, r *http.Request) {
 	switch msg.Action {
 	case ActPrefetch:
 		t.prefetchfiles(w, r, msg)
+	case ActCopy, ActMove:
+		t.copyfiles(w, r, msg)
+	case ActRenameLB:
+		t.renamelb(w, r, msg)
 	default:
 		t.invalmsghdlr(w, r, "Unexpected action "+msg.Action)
 	}
@@ -536,6 +630,14 @@ func (t *targetrunner) httpobjpost(w http.ResponseWriter, r *http.Request) {
 	switch msg.Action {
 	case ActRename:
 		t.renamefile(w, r, msg)
+	case ActCopy, ActMove:
+		t.copyfile(w, r, msg)
+	case ActMultipartInit:
+		t.mpinit(w, r)
+	case ActMultipartComplete:
+		t.mpcomplete(w, r, msg)
+	case ActMultipartAbort:
+		t.mpabort(w, r, msg)
 	default:
 		t.invalmsghdlr(w, r, "Unexpected action "+msg.Action)
 	}
@@ -575,6 +677,7 @@ func (t *targetrunner) httpbckhead(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		bucketprops[CloudProvider] = ctx.config.CloudProvider
 	} else {
 		bucketprops = make(map[string]string)
 		bucketprops[CloudProvider] = ProviderDfc
@@ -588,6 +691,121 @@ func (t *targetrunner) httpbckhead(w http.ResponseWriter, r *http.Request) {
 	for k, v := range bucketprops {
 		w.Header().Add(k, v)
 	}
+	t.bucketpropsHeaders(w, bucket)
+}
+
+// HEAD /Rversion/Robjects/bucket-name/object-name
+func (t *targetrunner) httpobjhead(w http.ResponseWriter, r *http.Request) {
+	var (
+		bucket, objname, errstr, version string
+		size                             int64
+		errcode                          int
+		coldget                          bool
+		objmeta                          map[string]string
+	)
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Robjects); apitems == nil {
+		return
+	}
+	bucket, objname = apitems[0], apitems[1]
+	islocal, errstr, errcode := t.checkLocalQueryParameter(bucket, r)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	if islocal && isreplicareq(r) {
+		t.httpreplicahead(w, r, bucket, objname)
+		return
+	}
+	fqn, uname := t.fqn(bucket, objname), t.uname(bucket, objname)
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
+	defer t.rtnamemap.unlockname(uname, false)
+
+	if coldget, size, version, errstr = t.isObjectCached(bucket, objname, fqn); errstr != "" {
+		errcode = http.StatusInternalServerError
+		if islocal {
+			errcode = http.StatusNotFound
+		}
+		t.invalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	if !coldget {
+		objmeta = make(map[string]string)
+		objmeta["size"] = strconv.FormatInt(size, 10)
+		if version != "" {
+			objmeta["version"] = version
+		}
+		var nhobj cksumvalue
+		if cksumcfg := t.cksumconf(bucket); cksumcfg.Checksum != ChecksumNone {
+			if hashbinary, errstr := Getxattr(fqn, xattrXXHashVal); errstr == "" && hashbinary != nil {
+				w.Header().Add(HeaderDfcChecksumType, cksumcfg.Checksum)
+				w.Header().Add(HeaderDfcChecksumVal, string(hashbinary))
+				nhobj = newcksumvalue(cksumcfg.Checksum, string(hashbinary))
+			}
+		}
+		if etag := objetag(nhobj, version); etag != "" {
+			w.Header().Set(headerETag, etag)
+		}
+		if finfo, err := os.Stat(fqn); err == nil {
+			w.Header().Set(headerLastModified, finfo.ModTime().UTC().Format(http.TimeFormat))
+		}
+		if atime, ok := t.objatime(fqn); ok {
+			w.Header().Add(HeaderDfcObjAtime, atime.Format(RFC822))
+		}
+		objmetaToHeader(w.Header(), t.objmeta(fqn))
+	} else if objmeta, errstr, errcode = getcloudif().headobject(bucket, objname); errstr != "" {
+		if errcode == 0 {
+			t.invalmsghdlr(w, r, errstr)
+		} else {
+			t.invalmsghdlr(w, r, errstr, errcode)
+		}
+		return
+	}
+	if s, ok := objmeta["size"]; ok {
+		w.Header().Set("Content-Length", s)
+	}
+	if v, ok := objmeta["version"]; ok {
+		w.Header().Add(HeaderDfcObjVersion, v)
+	}
+	w.Header().Add(HeaderDfcObjIsCached, strconv.FormatBool(!coldget))
+	if glog.V(4) {
+		glog.Infof("HEAD: %s/%s (cached %t)", bucket, objname, !coldget)
+	}
+}
+
+// objexists returns true if the object is cached or, for a Cloud bucket, exists in the Cloud;
+// only the Cloud's 404 means that the object does not exist - other errors are returned
+func (t *targetrunner) objexists(bucket, objname, fqn string) (exists bool, errstr string, errcode int) {
+	if _, err := os.Stat(fqn); err == nil {
+		return true, "", 0
+	}
+	if t.islocalBucket(bucket) {
+		return
+	}
+	_, errstr, errcode = getcloudif().headobject(bucket, objname)
+	switch {
+	case errstr == "":
+		exists = true
+	case errcode == http.StatusNotFound:
+		errstr, errcode = "", 0
+	case errcode == 0:
+		errcode = http.StatusServiceUnavailable
+	}
+	return
+}
+
+// objatime returns the access time of the cached object: atimerunner's,
+// if the object was recently accessed, or else the file's
+func (t *targetrunner) objatime(fqn string) (atime time.Time, ok bool) {
+	if atime, ok = getatimerunner().atime(fqn); ok {
+		return
+	}
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		return
+	}
+	atime, _, _ = getAmTimes(finfo)
+	return atime, true
 }
 
 //====================================================================================
@@ -614,14 +832,16 @@ func (t *targetrunner) checkCloudVersion(bucket, objname, version string) (vchan
 	return
 }
 
-func (t *targetrunner) coldget(bucket, objname string, prefetch bool) (props *objectProps, errstr string, errcode int) {
+// coldget downloads the object from the Cloud; c, if not nil, is the tee cold GET - see teeget.go
+func (t *targetrunner) coldget(bucket, objname string, prefetch bool, c *inflight) (props *objectProps, errstr string, errcode int) {
 	var (
 		fqn        = t.fqn(bucket, objname)
 		uname      = t.uname(bucket, objname)
-		getfqn     = t.fqn2workfile(fqn)
-		versioncfg = &ctx.config.Ver
+		getfqn     = t.partialfqn(fqn)
+		versioncfg = t.versionconf(bucket)
 		errv       = ""
 		vchanged   = false
+		err        error
 	)
 	// one cold GET at a time
 	if prefetch {
@@ -630,7 +850,7 @@ func (t *targetrunner) coldget(bucket, objname string, prefetch bool) (props *ob
 			return nil, "skip", 0
 		}
 	} else {
-		t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+		t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
 	}
 	// existence, access & versioning
 	coldget, size, version, eexists := t.isObjectCached(bucket, objname, fqn)
@@ -644,13 +864,17 @@ func (t *targetrunner) coldget(bucket, objname string, prefetch bool) (props *ob
 		props = &objectProps{version: version, size: size}
 		xxhashval, _ := Getxattr(fqn, xattrXXHashVal)
 		if xxhashval != nil {
-			cksumcfg := &ctx.config.Cksum
+			cksumcfg := t.cksumconf(bucket)
 			props.nhobj = newcksumvalue(cksumcfg.Checksum, string(xxhashval))
 		}
 		glog.Infof("cold GET race: %s/%s, size=%d, version=%s - nothing to do", bucket, objname, size, version)
 		goto ret
 	}
 	// cold
+	t.adoptpartial(fqn, getfqn)
+	if c != nil {
+		t.coldgets.setwork(c, getfqn)
+	}
 	if props, errstr, errcode = getcloudif().getobj(getfqn, bucket, objname); errstr != "" {
 		t.rtnamemap.unlockname(uname, true)
 		return
@@ -664,7 +888,12 @@ func (t *targetrunner) coldget(bucket, objname string, prefetch bool) (props *ob
 			t.runFSKeeper(fmt.Errorf("%s", fqn))
 		}
 	}()
-	if err := os.Rename(getfqn, fqn); err != nil {
+	if c != nil {
+		err = c.rename(getfqn, fqn)
+	} else {
+		err = os.Rename(getfqn, fqn)
+	}
+	if err != nil {
 		errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", getfqn, fqn, err)
 		return
 	}
@@ -795,6 +1024,20 @@ func (t *targetrunner) prepareLocalObjectList(bucket string, msg *GetMsg) (bucke
 		infos *allfinfos
 		err   error
 	}
+	ls, errstr := parsesort(msg)
+	var (
+		after  *listitem
+		filter *listfilter
+	)
+	if errstr == "" {
+		after, errstr = ls.marker(msg.GetPageMarker)
+	}
+	if errstr == "" {
+		filter, errstr = newlistfilter(msg)
+	}
+	if errstr != "" {
+		return nil, errors.New(errstr)
+	}
 	ch := make(chan *mresp, len(ctx.mountpaths.Available))
 	wg := &sync.WaitGroup{}
 	isLocal := t.islocalBucket(bucket)
@@ -802,7 +1045,7 @@ func (t *targetrunner) prepareLocalObjectList(bucket string, msg *GetMsg) (bucke
 	// function to traverse one mountpoint
 	fn := func(fqn string) {
 		defer wg.Done()
-		r := &mresp{t.newFileWalk(bucket, msg), nil}
+		r := &mresp{t.newFileWalk(bucket, msg, ls, after, filter), nil}
 
 		if _, err = os.Stat(fqn); err != nil {
 			if !os.IsNotExist(err) {
@@ -840,43 +1083,28 @@ func (t *targetrunner) prepareLocalObjectList(bucket string, msg *GetMsg) (bucke
 	wg.Wait()
 	close(ch)
 
-	// combine results into one long list
-	// real size of page is set in newFileWalk, so read it from any of results inside loop
+	// combine results into one long list, sort it and return the first `pageSize` items
 	pageSize := DefaultPageSize
-	allfinfos := make([]*BucketEntry, 0, 0)
-	fileCount := 0
+	if msg.GetPageSize != 0 {
+		pageSize = msg.GetPageSize
+	}
+	items := make([]*listitem, 0)
+	prefixes := make(map[string]bool)
 	for r := range ch {
 		if r.err != nil {
 			t.runFSKeeper(r.err)
 			return nil, r.err
 		}
 
-		pageSize = r.infos.limit
-		allfinfos = append(allfinfos, r.infos.files...)
-		fileCount += r.infos.fileCount
-	}
-
-	// sort the result and return only first `pageSize` entries
-	marker := ""
-	if fileCount > pageSize {
-		ifLess := func(i, j int) bool {
-			return allfinfos[i].Name < allfinfos[j].Name
-		}
-		sort.Slice(allfinfos, ifLess)
-		// set extra infos to nil to avoid memory leaks
-		// see NOTE on https://github.com/golang/go/wiki/SliceTricks
-		for i := pageSize; i < fileCount; i++ {
-			allfinfos[i] = nil
+		items = append(items, r.infos.files...)
+		for cp := range r.infos.prefixes {
+			if !prefixes[cp] {
+				prefixes[cp] = true
+				items = append(items, &listitem{name: cp})
+			}
 		}
-		allfinfos = allfinfos[:pageSize]
-		marker = allfinfos[pageSize-1].Name
-	}
-
-	bucketList = &BucketList{
-		Entries:    allfinfos,
-		PageMarker: marker,
 	}
-	return bucketList, nil
+	return ls.page(items, pageSize), nil
 }
 
 func (t *targetrunner) getbucketnames(w http.ResponseWriter, r *http.Request) {
@@ -935,6 +1163,10 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 	if t.readJSON(w, r, msg) != nil {
 		return
 	}
+	if msg.GetWhat == GetWhatSummary {
+		t.bucketsummary(w, r, bucket, islocal)
+		return
+	}
 	if islocal {
 		tag = "local"
 		if errstr, ok = t.doLocalBucketList(w, r, bucket, msg); errstr != "" {
@@ -948,7 +1180,7 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 		jsbytes, errstr, errcode = t.listCachedObjects(bucket, msg)
 	} else {
 		tag = "cloud"
-		jsbytes, errstr, errcode = getcloudif().listbucket(bucket, msg)
+		jsbytes, errstr, errcode = t.listCloudObjects(bucket, msg)
 	}
 	if errstr != "" {
 		if errcode == 0 {
@@ -962,13 +1194,31 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 	return
 }
 
-func (t *targetrunner) newFileWalk(bucket string, msg *GetMsg) *allfinfos {
+// listCloudObjects lists the Cloud bucket and filters the provider's page - see listfilter
+func (t *targetrunner) listCloudObjects(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	filter, errstr := newlistfilter(msg)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	if filter == nil {
+		return getcloudif().listbucket(bucket, msg)
+	}
+	cloudmsg := filter.cloudmsg(msg)
+	if jsbytes, errstr, errcode = getcloudif().listbucket(bucket, cloudmsg); errstr != "" {
+		return
+	}
+	jsbytes, errstr = filter.filtercloud(jsbytes, msg, cloudmsg)
+	return
+}
+
+func (t *targetrunner) newFileWalk(bucket string, msg *GetMsg, ls *listsort, after *listitem, filter *listfilter) *allfinfos {
 	// Split a marker into separate directory list to make pagination
 	// more effective. All directories in the list are skipped by
 	// filepath.Walk. The last directory of the marker must be excluded
 	// from the list because the marker can point to the middle of it
 	markerDirs := make([]string, 0)
-	if msg.GetPageMarker != "" && strings.Contains(msg.GetPageMarker, "/") {
+	if ls.isdefault() && msg.GetPageMarker != "" && strings.Contains(msg.GetPageMarker, "/") {
 		idx := strings.LastIndex(msg.GetPageMarker, "/")
 		markerDirs = strings.Split(msg.GetPageMarker[:idx], "/")
 		markerDirs = markerDirs[:len(markerDirs)-1]
@@ -976,7 +1226,7 @@ func (t *targetrunner) newFileWalk(bucket string, msg *GetMsg) *allfinfos {
 
 	// A small optimization: set boolean variables need* to avoid
 	// doing string search(strings.Contains) for every entry.
-	ci := &allfinfos{make([]*BucketEntry, 0, DefaultPageSize),
+	ci := &allfinfos{make([]*listitem, 0, DefaultPageSize),
 		0,                 // fileCount
 		0,                 // rootLength
 		msg.GetPrefix,     // prefix
@@ -986,11 +1236,16 @@ func (t *targetrunner) newFileWalk(bucket string, msg *GetMsg) *allfinfos {
 		strings.Contains(msg.GetProps, GetPropsCtime),    // needCtime
 		strings.Contains(msg.GetProps, GetPropsChecksum), // needChkSum
 		strings.Contains(msg.GetProps, GetPropsVersion),  // needVersion
-		msg,             // GetMsg
-		"",              // lastFilePath - next page marker
-		t,               // targetrunner
-		bucket,          // bucket
-		DefaultPageSize, // limit - maximun number of objects to return
+		msg,                   // GetMsg
+		"",                    // lastFilePath - next page marker
+		t,                     // targetrunner
+		bucket,                // bucket
+		DefaultPageSize,       // limit - maximun number of objects to return
+		msg.GetDelimiter,      // delimiter
+		make(map[string]bool), // prefixes
+		ls,                    // sort
+		after,                 // after
+		filter,                // filter
 	}
 
 	if msg.GetPageSize != 0 {
@@ -1031,11 +1286,23 @@ func (ci *allfinfos) processDir(fqn string) error {
 		}
 	}
 
+	// all objects in the directory roll up into the same common prefix:
+	// either returned by one of the previous pages or already added
+	if ci.delimiter != "" {
+		dirname := relname + "/"
+		if strings.HasSuffix(ci.marker, ci.delimiter) && strings.HasPrefix(dirname, ci.marker) {
+			return filepath.SkipDir
+		}
+		if cp := commonprefix(dirname, ci.prefix, ci.delimiter); cp != "" && ci.prefixes[cp] {
+			return filepath.SkipDir
+		}
+	}
 	return nil
 }
 
 // Adds an info about cached object to the list if:
 //  - its name starts with prefix (if prefix is set)
+//  - it passes the filter (if set)
 //  - it has not been already returned by previous page request
 //  - this target responses getobj request for the object
 func (ci *allfinfos) processRegularFile(fqn string, osfi os.FileInfo) error {
@@ -1043,8 +1310,12 @@ func (ci *allfinfos) processRegularFile(fqn string, osfi os.FileInfo) error {
 	if ci.prefix != "" && !strings.HasPrefix(relname, ci.prefix) {
 		return nil
 	}
+	if ci.filter != nil && !ci.filter.accept(relname, osfi.Size(), osfi.ModTime()) {
+		return nil
+	}
 
-	if ci.marker != "" && relname <= ci.marker {
+	item := &listitem{key: ci.sort.keyof(osfi), name: relname}
+	if ci.sort.listed(item, ci.after, ci.delimiter) {
 		return nil
 	}
 
@@ -1057,6 +1328,13 @@ func (ci *allfinfos) processRegularFile(fqn string, osfi os.FileInfo) error {
 		// this target is not responsible for returning this object
 		return nil
 	}
+	if cp := commonprefix(relname, ci.prefix, ci.delimiter); cp != "" {
+		if !ci.prefixes[cp] {
+			ci.prefixes[cp] = true
+			ci.fileCount++
+		}
+		return nil
+	}
 
 	// the file passed all checks - add it to the batch
 	ci.fileCount++
@@ -1095,7 +1373,8 @@ func (ci *allfinfos) processRegularFile(fqn string, osfi os.FileInfo) error {
 		}
 	}
 	fileInfo.Size = osfi.Size()
-	ci.files = append(ci.files, fileInfo)
+	item.entry = fileInfo
+	ci.files = append(ci.files, item)
 	ci.lastFilePath = fqn
 	return nil
 }
@@ -1108,7 +1387,8 @@ func (ci *allfinfos) listwalkf(fqn string, osfi os.FileInfo, err error) error {
 		glog.Errorf("listwalkf callback invoked with err: %v", err)
 		return err
 	}
-	if ci.fileCount >= ci.limit {
+	// sorted other than by name (ascending), the page is only known after walking all the files
+	if ci.sort.isdefault() && ci.fileCount >= ci.limit {
 		return filepath.SkipDir
 	}
 	if osfi.IsDir() {
@@ -1143,13 +1423,38 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 		started                    time.Time
 	)
 	started = time.Now()
-	cksumcfg := &ctx.config.Cksum
+	cksumcfg := t.cksumconf(bucket)
 	fqn := t.fqn(bucket, objname)
 	putfqn := t.fqn2workfile(fqn)
 	hdhobj = newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
 	if hdhobj != nil {
 		htype, hval = hdhobj.get()
 	}
+	meta, errstr := objmetaFromHeader(r.Header)
+	if errstr != "" {
+		return
+	}
+	// create-only: fail early if the object exists (and again, upon commit - see putCommit);
+	// create-only PUTs of the same object are serialized, so that for a Cloud bucket the one
+	// that checks existence after another one's upload fails instead of overwriting it
+	createonly := strings.TrimSpace(r.Header.Get(headerIfNoneMatch)) == etagAny
+	if createonly {
+		cuname := "createonly:" + t.uname(bucket, objname)
+		t.rtnamemap.lockname(cuname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
+		defer t.rtnamemap.unlockname(cuname, true)
+	}
+	if createonly {
+		var exists bool
+		if exists, errstr, errcode = t.objexists(bucket, objname, fqn); errstr != "" {
+			errstr = fmt.Sprintf("PUT %s/%s: failed to check whether the object exists: %s", bucket, objname, errstr)
+			return
+		}
+		if exists {
+			errstr = fmt.Sprintf("PUT %s/%s: object already exists", bucket, objname)
+			errcode = http.StatusPreconditionFailed
+			return
+		}
+	}
 	// optimize out if the checksums do match
 	if hdhobj != nil && cksumcfg.Checksum != ChecksumNone {
 		file, err = os.Open(fqn)
@@ -1178,8 +1483,8 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 			}
 		}
 	}
-	inmem := (ctx.config.Experimental.AckPut == AckWhenInMem)
-	if sgl, nhobj, _, errstr = t.receive(putfqn, inmem, objname, "", hdhobj, r.Body); errstr != "" {
+	inmem := ctx.config.Experimental.AckPut == AckWhenInMem && !createonly // create-only commits synchronously
+	if sgl, nhobj, _, errstr = t.receive(putfqn, inmem, bucket, objname, "", hdhobj, r.Body); errstr != "" {
 		return
 	}
 	if nhobj != nil {
@@ -1192,7 +1497,7 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 		return
 	}
 	// commit
-	props := &objectProps{nhobj: nhobj}
+	props := &objectProps{nhobj: nhobj, createonly: createonly, meta: meta}
 	if sgl == nil {
 		errstr, errcode = t.putCommit(bucket, objname, putfqn, fqn, props, false /*rebalance*/)
 		if errstr == "" {
@@ -1275,7 +1580,7 @@ func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string,
 			errstr = fmt.Sprintf("Failed to reopen %s err: %v", putfqn, err)
 			return
 		}
-		if objprops.version, errstr, errcode = getcloudif().putobj(file, bucket, objname, objprops.nhobj); errstr != "" {
+		if objprops.version, errstr, errcode = getcloudif().putobj(file, bucket, objname, objprops.nhobj, objprops.meta); errstr != "" {
 			_ = file.Close()
 			return
 		}
@@ -1292,10 +1597,48 @@ func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string,
 		}
 	}
 
+	// erasure coding: split and distribute the object once committed and unlocked;
+	// the PUT is complete at this point and does not fail if the encoding does
+	if _, enabled := t.ecconf(bucket); enabled && isBucketLocal && !rebalance {
+		defer func() {
+			if errstr != "" {
+				return
+			}
+			go func() {
+				if errstr := t.ecencode(bucket, objname); errstr != "" {
+					glog.Errorln(errstr)
+				}
+			}()
+		}()
+	}
+	// replication: mirror the object once committed and unlocked
+	if copies, async := t.copies(bucket); copies > 1 && isBucketLocal && !rebalance {
+		defer func() {
+			if errstr != "" {
+				return
+			}
+			if !async {
+				errstr = t.replicate(bucket, objname, false)
+				return
+			}
+			go func() {
+				if errstr := t.replicate(bucket, objname, false); errstr != "" {
+					glog.Errorln(errstr)
+				}
+			}()
+		}()
+	}
 	// when all set and done:
 	uname := t.uname(bucket, objname)
-	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
 	defer t.rtnamemap.unlockname(uname, true)
+	if objprops.createonly {
+		if _, err := os.Stat(fqn); err == nil {
+			errstr = fmt.Sprintf("PUT %s/%s: object already exists", bucket, objname)
+			errcode = http.StatusPreconditionFailed
+			return
+		}
+	}
 	if err = os.Rename(putfqn, fqn); err != nil {
 		errstr = fmt.Sprintf("Failed to rename %s => %s, err: %v", putfqn, fqn, err)
 		return
@@ -1321,7 +1664,7 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 		// the source
 		//
 		uname := t.uname(bucket, objname)
-		t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+		t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn})
 		defer t.rtnamemap.unlockname(uname, false)
 
 		finfo, err := os.Stat(fqn)
@@ -1348,6 +1691,9 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 		//
 		// the destination
 		//
+		if isreplicareq(r) {
+			return t.replicarecv(r, bucket, objname)
+		}
 		if glog.V(3) {
 			glog.Infof("Rebalance %s/%s from %s to %s (self)", bucket, objname, from, to)
 		}
@@ -1362,7 +1708,10 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 			inmem  = false // TODO
 			props  = &objectProps{version: r.Header.Get(HeaderDfcObjVersion)}
 		)
-		if _, props.nhobj, size, errstr = t.receive(putfqn, inmem, objname, "", hdhobj, r.Body); errstr != "" {
+		if props.meta, errstr = objmetaFromHeader(r.Header); errstr != "" {
+			return
+		}
+		if _, props.nhobj, size, errstr = t.receive(putfqn, inmem, bucket, objname, "", hdhobj, r.Body); errstr != "" {
 			return
 		}
 		if props.nhobj != nil {
@@ -1392,7 +1741,7 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 	uname := t.uname(bucket, objname)
 	localbucket := t.islocalBucket(bucket)
 
-	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
 	defer t.rtnamemap.unlockname(uname, true)
 
 	if !localbucket && !evict {
@@ -1410,6 +1759,14 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			if localbucket && !evict {
+				// erasure coded object may exist only as slices - e.g., after losing its target
+				if _, enabled := t.ecconf(bucket); enabled && t.ecdelete(bucket, objname) > 0 {
+					return nil
+				}
+				// ditto replicas
+				if copies, _ := t.copies(bucket); copies > 1 && t.replicadelete(bucket, objname) > 0 {
+					return nil
+				}
 				return fmt.Errorf("DELETE local: file %s (local bucket %s, object %s) does not exist",
 					fqn, bucket, objname)
 			}
@@ -1426,6 +1783,14 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 			t.statsif.addMany("filesevicted", int64(1), "bytesevicted", finfo.Size())
 		}
 	}
+	if localbucket && !evict {
+		if _, enabled := t.ecconf(bucket); enabled {
+			t.ecdelete(bucket, objname)
+		}
+		if copies, _ := t.copies(bucket); copies > 1 {
+			t.replicadelete(bucket, objname)
+		}
+	}
 	return nil
 }
 
@@ -1439,7 +1804,7 @@ func (t *targetrunner) renamefile(w http.ResponseWriter, r *http.Request, msg Ac
 	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
 	newobjname := msg.Name
 	fqn, uname := t.fqn(bucket, objname), t.uname(bucket, objname)
-	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn})
 	defer t.rtnamemap.unlockname(uname, true)
 
 	finfo, err := os.Stat(fqn)
@@ -1535,15 +1900,9 @@ func (t *targetrunner) deletefiles(w http.ResponseWriter, r *http.Request, msg A
 // xattrs then the sender adds to HTTP header object version. A receiver side
 // reads version from headers and set xattrs if the version is not empty
 func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonInfo, size int64, newobjname string) string {
-	var (
-		xxhashval string
-		errstr    string
-		version   []byte
-	)
 	if size == 0 {
 		return fmt.Sprintf("Unexpected: %s/%s size is zero", bucket, objname)
 	}
-	cksumcfg := &ctx.config.Cksum
 	if newobjname == "" {
 		newobjname = objname
 	}
@@ -1552,7 +1911,17 @@ func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonIn
 	url += bucket + "/" + newobjname
 	url += fmt.Sprintf("?%s=%s&%s=%s", URLParamFromID, fromid, URLParamToID, toid)
 
-	fqn := t.fqn(bucket, objname)
+	return t.sendfqn(method, bucket, t.fqn(bucket, objname), url, destsi, size)
+}
+
+// sendfqn sends the local file fqn along with its checksum and version (see sendfile)
+func (t *targetrunner) sendfqn(method, bucket, fqn, url string, destsi *daemonInfo, size int64) string {
+	var (
+		xxhashval string
+		errstr    string
+		version   []byte
+	)
+	cksumcfg := t.cksumconf(bucket)
 	file, err := os.Open(fqn)
 	if err != nil {
 		return fmt.Sprintf("Failed to open %q, err: %v", fqn, err)
@@ -1592,6 +1961,7 @@ func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonIn
 	if len(version) != 0 {
 		request.Header.Set(HeaderDfcObjVersion, string(version))
 	}
+	objmetaToHeader(request.Header, t.objmeta(fqn))
 	response, err := t.httpclient.Do(request)
 	if err != nil {
 		return fmt.Sprintf("Failed to send %q from %s, err: %v", fqn, t.si.DaemonID, err)
@@ -1610,6 +1980,10 @@ func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonIn
 			}
 			return s
 		}
+		if response.StatusCode >= http.StatusBadRequest {
+			return fmt.Sprintf("Failed to send %q from %s to %s, status %d",
+				fqn, t.si.DaemonID, destsi.DaemonID, response.StatusCode)
+		}
 	}
 	t.statsif.addMany("numsentfiles", int64(1), "numsentbytes", size)
 	return ""
@@ -1772,7 +2146,8 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 			return
 		}
 	}
-	if isSubset {
+	// targets leaving the cluster take their replicas along - hence, rebalance
+	if isSubset && !t.replicated() {
 		if newlen != oldlen {
 			assert(newlen < oldlen)
 			glog.Infoln("nothing to rebalance: new Smap is a strict subset of the old")
@@ -1788,7 +2163,7 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 
 func (t *targetrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, apitems []string) {
 	curversion := t.lbmap.Version
-	newlbmap := &lbmap{LBmap: make(map[string]string)}
+	newlbmap := &lbmap{LBmap: make(map[string]BucketProps)}
 	if t.readJSON(w, r, newlbmap) != nil {
 		return
 	}
@@ -1800,16 +2175,59 @@ func (t *targetrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, a
 		return
 	}
 	glog.Infof("%s: new lbmap version %d (old %d)", apitems[0], newlbmap.Version, curversion)
+	t.setlbmap(newlbmap)
+}
+
+// setlbmap installs the new lbmap and creates, renames and destroys local bucket directories accordingly
+func (t *targetrunner) setlbmap(newlbmap *lbmap) {
+	// renamelb: this target may not have renamed the bucket itself (see renameLocalBucket)
+	migrate := make([]string, 0)
+	for bucket := range t.lbmap.LBmap {
+		if _, ok := newlbmap.LBmap[bucket]; ok {
+			continue
+		}
+		newbucket := newlbmap.renamedto(bucket)
+		if newbucket == "" {
+			continue
+		}
+		for mpath := range ctx.mountpaths.Available {
+			froms, tos := lbdirs(mpath, bucket), lbdirs(mpath, newbucket)
+			for i, from := range froms {
+				to := tos[i]
+				if _, err := os.Stat(from); err != nil {
+					continue
+				}
+				if _, err := os.Stat(to); err == nil {
+					glog.Errorf("Local bucket %s renamed => %s: keeping %q as %q exists", bucket, newbucket, from, to)
+					continue
+				}
+				if err := os.Rename(from, to); err != nil {
+					glog.Errorf("Failed to rename local bucket dir %q => %q, err: %v", from, to, err)
+					continue
+				}
+				glog.Infof("Local bucket %s renamed => %s: %q", bucket, newbucket, to)
+				migrate = append(migrate, newbucket)
+			}
+		}
+	}
 	// destroylb
 	for bucket := range t.lbmap.LBmap {
 		_, ok := newlbmap.LBmap[bucket]
-		if !ok {
+		if !ok && newlbmap.renamedto(bucket) == "" {
 			glog.Infof("Destroy local bucket %s", bucket)
 			for mpath := range ctx.mountpaths.Available {
 				localbucketfqn := filepath.Join(makePathLocal(mpath), bucket)
 				if err := os.RemoveAll(localbucketfqn); err != nil {
 					glog.Errorf("Failed to destroy local bucket dir %q, err: %v", localbucketfqn, err)
 				}
+				slicesfqn := filepath.Join(makePathEC(mpath), bucket)
+				if err := os.RemoveAll(slicesfqn); err != nil {
+					glog.Errorf("Failed to destroy local bucket slices dir %q, err: %v", slicesfqn, err)
+				}
+				replicasfqn := filepath.Join(makePathReplica(mpath), bucket)
+				if err := os.RemoveAll(replicasfqn); err != nil {
+					glog.Errorf("Failed to destroy local bucket replicas dir %q, err: %v", replicasfqn, err)
+				}
 			}
 		}
 	}
@@ -1822,6 +2240,11 @@ func (t *targetrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, a
 			}
 		}
 	}
+	for i, bucket := range migrate {
+		if i == 0 || migrate[i-1] != bucket {
+			go t.migratelb(bucket)
+		}
+	}
 }
 
 func (t *targetrunner) httpdaesetprimaryproxy(w http.ResponseWriter, r *http.Request, apitems []string) {
@@ -1903,26 +2326,33 @@ func (t *targetrunner) httpdaepost(w http.ResponseWriter, r *http.Request) {
 // xxhash is always preferred over md5
 //
 //==============================================================================================
-func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, ohobj cksumvalue,
+func (t *targetrunner) receive(fqn string, inmem bool, bucket, objname, omd5 string, ohobj cksumvalue,
 	reader io.Reader) (sgl *SGLIO, nhobj cksumvalue, written int64, errstr string) {
+	return t.receiveresumable(fqn, inmem, bucket, objname, omd5, ohobj, nil, reader)
+}
+
+// receiveresumable receives the object into the work file; given the download progress (dl),
+// resumes the interrupted download and, if interrupted again, keeps the work file - see resume.go
+func (t *targetrunner) receiveresumable(fqn string, inmem bool, bucket, objname, omd5 string, ohobj cksumvalue,
+	dl *dlprogress, reader io.Reader) (sgl *SGLIO, nhobj cksumvalue, written int64, errstr string) {
 	var (
 		err                  error
 		file                 *os.File
 		filewriter           io.Writer
 		ohtype, ohval, nhval string
-		cksumcfg             = &ctx.config.Cksum
+		h                    hash.Hash // xxhash or, to validate cold GET, md5
+		hashes               []hash.Hash
+		interrupted          bool // failed to receive - can be resumed
+		cksumcfg             = t.cksumconf(bucket)
 	)
-	// ack policy = memory
-	if inmem {
-		sgl = NewSGLIO(0)
-		filewriter = sgl
-	} else {
-		if file, err = CreateFile(fqn); err != nil {
-			t.runFSKeeper(fmt.Errorf("%s", fqn))
-			errstr = fmt.Sprintf("Failed to create %s, err: %s", fqn, err)
-			return
-		}
-		filewriter = file
+	if cksumcfg.Checksum != ChecksumNone {
+		assert(cksumcfg.Checksum == ChecksumXXHash)
+		h = xxhash.New64()
+	} else if omd5 != "" && cksumcfg.ValidateColdGet {
+		h = md5.New()
+	}
+	if h != nil {
+		hashes = []hash.Hash{h}
 	}
 	slab := selectslab(0)
 	buf := slab.alloc()
@@ -1931,6 +2361,9 @@ func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, oho
 		if errstr == "" {
 			return
 		}
+		if interrupted && t.savepartial(fqn, file, dl, written) {
+			return
+		}
 		t.runFSKeeper(fmt.Errorf("%s", fqn))
 		if err = file.Close(); err != nil {
 			glog.Errorf("Nested: failed to close received file %s, err: %v", fqn, err)
@@ -1938,15 +2371,40 @@ func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, oho
 		if err = os.Remove(fqn); err != nil {
 			glog.Errorf("Nested error %s => (remove %s => err: %v)", errstr, fqn, err)
 		}
+		if dl != nil {
+			removeprogress(fqn)
+		}
 	}()
-	// receive and checksum
-	if cksumcfg.Checksum != ChecksumNone {
-		assert(cksumcfg.Checksum == ChecksumXXHash)
-		xx := xxhash.New64()
-		if written, errstr = ReceiveAndChecksum(filewriter, reader, buf, xx); errstr != "" {
+	// ack policy = memory
+	if inmem {
+		sgl = NewSGLIO(0)
+		filewriter = sgl
+	} else {
+		if file, err = t.openpartial(fqn, dl, h, buf); err != nil {
+			t.runFSKeeper(fmt.Errorf("%s", fqn))
+			errstr = fmt.Sprintf("Failed to create %s, err: %s", fqn, err)
 			return
 		}
-		hashIn64 := xx.Sum64()
+		filewriter = file
+		if dl != nil {
+			written = dl.Offset
+		}
+		// tee cold GET: the readers follow the work file as it grows
+		if c := t.coldgets.find(fqn); c != nil {
+			c.create()
+			c.wrote(written)
+			filewriter = &teewriter{file: file, c: c}
+		}
+	}
+	// receive and checksum
+	n, errstr := ReceiveAndChecksum(filewriter, reader, buf, hashes...)
+	written += n
+	if errstr != "" {
+		interrupted = true
+		return
+	}
+	if cksumcfg.Checksum != ChecksumNone {
+		hashIn64 := h.(hash.Hash64).Sum64()
 		hashInBytes := make([]byte, 8)
 		binary.BigEndian.PutUint64(hashInBytes, hashIn64)
 		nhval = hex.EncodeToString(hashInBytes)
@@ -1961,23 +2419,15 @@ func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, oho
 				return
 			}
 		}
-	} else if omd5 != "" && cksumcfg.ValidateColdGet {
-		md5 := md5.New()
-		if written, errstr = ReceiveAndChecksum(filewriter, reader, buf, md5); errstr != "" {
-			return
-		}
-		hashInBytes := md5.Sum(nil)[:16]
+	} else if h != nil {
+		hashInBytes := h.Sum(nil)[:16]
 		md5hash := hex.EncodeToString(hashInBytes)
 		if omd5 != md5hash {
 			errstr = fmt.Sprintf("Bad checksum: cold GET %s md5 %s... != %s... computed for the %q",
-				objname, ohval[:8], nhval[:8], fqn)
+				objname, omd5[:8], md5hash[:8], fqn)
 			t.statsif.addMany("numbadchecksum", int64(1), "bytesbadchecksum", written)
 			return
 		}
-	} else {
-		if written, errstr = ReceiveAndChecksum(filewriter, reader, buf); errstr != "" {
-			return
-		}
 	}
 	// close and done
 	if inmem {
@@ -1985,6 +2435,10 @@ func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, oho
 	}
 	if err = file.Close(); err != nil {
 		errstr = fmt.Sprintf("Failed to close received file %s, err: %v", fqn, err)
+		return
+	}
+	if dl != nil {
+		removeprogress(fqn)
 	}
 	return
 }
@@ -2223,7 +2677,7 @@ func (t *targetrunner) startupMpaths() {
 //    versioning is unsupported even if versioning is 'all' or 'cloud'.
 func (t *targetrunner) versioningConfigured(bucket string) bool {
 	islocal := t.islocalBucket(bucket)
-	versioning := ctx.config.Ver.Versioning
+	versioning := t.versionconf(bucket).Versioning
 	if islocal {
 		return versioning == VersionAll || versioning == VersionLocal
 	}
@@ -2240,7 +2694,18 @@ func (t *targetrunner) finalizeobj(fqn string, objprops *objectProps) (errstr st
 		}
 	}
 	if objprops.version != "" {
-		errstr = Setxattr(fqn, xattrObjVersion, []byte(objprops.version))
+		if errstr = Setxattr(fqn, xattrObjVersion, []byte(objprops.version)); errstr != "" {
+			return
+		}
+	}
+	if len(objprops.meta) > 0 {
+		jsbytes, err := json.Marshal(objprops.meta)
+		assert(err == nil, err)
+		if len(jsbytes) >= maxAttrSize {
+			glog.Errorf("%s: user-defined metadata is too large (%d bytes) - not storing", fqn, len(jsbytes))
+			return
+		}
+		errstr = Setxattr(fqn, xattrObjMeta, jsbytes)
 	}
 	return
 }
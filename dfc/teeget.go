@@ -0,0 +1,236 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// tee cold GET (experimental.tee_cold_get): the object is downloaded in background
+// while the GET streams it from the work file as it grows. Concurrent GETs of the
+// same object attach to the download in progress rather than wait on the name lock.
+// The size, the checksum and the version are only known once downloaded - hence,
+// the response is chunked and carries the checksum, version and user-defined
+// metadata as trailers; a failed download aborts the response
+//
+//======
+
+type inflight struct {
+	sync.Mutex
+	cond    *sync.Cond
+	uname   string
+	workfqn string
+	fqn     string // the file to read: the work file while downloading, the object once downloaded
+	created bool   // the work file has been created
+	written int64
+	done    bool
+	props   *objectProps
+	errstr  string
+	errcode int
+}
+
+// coldgets is the registry of the downloads in progress
+type coldgets struct {
+	sync.Mutex
+	byuname map[string]*inflight
+	byfqn   map[string]*inflight // by work file
+}
+
+func newcoldgets() *coldgets {
+	return &coldgets{byuname: make(map[string]*inflight), byfqn: make(map[string]*inflight)}
+}
+
+// teewriter writes into the work file and wakes up the readers
+type teewriter struct {
+	file *os.File
+	c    *inflight
+}
+
+func (tw *teewriter) Write(p []byte) (n int, err error) {
+	n, err = tw.file.Write(p)
+	tw.c.wrote(int64(n))
+	return
+}
+
+// teeget returns true if the GET is to be served in tee mode
+func (t *targetrunner) teeget(r *http.Request, islocal bool) bool {
+	return ctx.config.Experimental.TeeColdGet && !islocal && r.Header.Get(headerRange) == "" && !isconditional(r)
+}
+
+// attach returns the download in progress, if any
+func (cg *coldgets) attach(uname string) *inflight {
+	cg.Lock()
+	defer cg.Unlock()
+	return cg.byuname[uname]
+}
+
+// setwork registers the work file the object is being downloaded into - see receive()
+func (cg *coldgets) setwork(c *inflight, workfqn string) {
+	cg.Lock()
+	cg.byfqn[workfqn] = c
+	cg.Unlock()
+	c.Lock()
+	c.workfqn, c.fqn = workfqn, workfqn
+	c.Unlock()
+}
+
+func (cg *coldgets) find(workfqn string) *inflight {
+	cg.Lock()
+	defer cg.Unlock()
+	return cg.byfqn[workfqn]
+}
+
+// finish wakes up the readers and removes the download from the registry
+func (cg *coldgets) finish(c *inflight, fqn string, props *objectProps, errstr string, errcode int) {
+	cg.Lock()
+	delete(cg.byuname, c.uname)
+	delete(cg.byfqn, c.workfqn)
+	cg.Unlock()
+	c.Lock()
+	c.done, c.props, c.errstr, c.errcode = true, props, errstr, errcode
+	if !c.created && errstr == "" {
+		// cold GET race: the object has been downloaded by someone else
+		c.fqn, c.written = fqn, props.size
+	}
+	c.cond.Broadcast()
+	c.Unlock()
+}
+
+// create is called by receive() (or receiveranges()) once the work file is created
+func (c *inflight) create() {
+	c.Lock()
+	c.created = true
+	c.cond.Broadcast()
+	c.Unlock()
+}
+
+// wrote advances the downloaded (contiguous) part of the work file
+func (c *inflight) wrote(n int64) {
+	c.Lock()
+	c.written += n
+	c.cond.Broadcast()
+	c.Unlock()
+}
+
+// rename renames the downloaded work file while no reader is opening it
+func (c *inflight) rename(workfqn, fqn string) (err error) {
+	c.Lock()
+	if err = os.Rename(workfqn, fqn); err == nil {
+		c.fqn = fqn
+	}
+	c.Unlock()
+	return
+}
+
+// teecoldget attaches to the download in progress or starts a new one
+func (t *targetrunner) teecoldget(bucket, objname string) (c *inflight) {
+	uname := t.uname(bucket, objname)
+	t.coldgets.Lock()
+	if c = t.coldgets.byuname[uname]; c != nil {
+		t.coldgets.Unlock()
+		return
+	}
+	c = &inflight{uname: uname}
+	c.cond = sync.NewCond(c)
+	t.coldgets.byuname[uname] = c
+	t.coldgets.Unlock()
+
+	go func() {
+		props, errstr, errcode := t.coldget(bucket, objname, false, c)
+		t.coldgets.finish(c, t.fqn(bucket, objname), props, errstr, errcode)
+		// note: coldget() keeps the read lock if successful
+		if errstr == "" {
+			t.rtnamemap.unlockname(uname, false)
+		}
+	}()
+	return
+}
+
+// teesend streams the object from the file that is being downloaded
+func (t *targetrunner) teesend(w http.ResponseWriter, r *http.Request, c *inflight, bucket, objname string, started time.Time) {
+	c.Lock()
+	for !c.created && !c.done {
+		c.cond.Wait()
+	}
+	if c.errstr != "" {
+		errstr, errcode := c.errstr, c.errcode
+		c.Unlock()
+		if errcode == 0 {
+			t.invalmsghdlr(w, r, errstr)
+		} else {
+			t.invalmsghdlr(w, r, errstr, errcode)
+		}
+		return
+	}
+	fqn := c.fqn
+	file, err := os.Open(fqn)
+	c.Unlock()
+	if err != nil {
+		errstr := fmt.Sprintf("Failed to open %s, err: %v", fqn, err)
+		t.invalmsghdlr(w, r, errstr, http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// declare the trailers; user-defined metadata (not known in advance) is sent
+	// as undeclared trailers - see http.TrailerPrefix
+	w.Header().Set("Trailer", HeaderDfcChecksumType+", "+HeaderDfcChecksumVal+", "+HeaderDfcObjVersion)
+
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	var off int64
+	for {
+		c.Lock()
+		for off >= c.written && !c.done {
+			c.cond.Wait()
+		}
+		written, done, errstr := c.written, c.done, c.errstr
+		fqn = c.fqn
+		c.Unlock()
+		if errstr != "" {
+			glog.Errorf("GET %s/%s: aborting after %d bytes, err: %s", bucket, objname, off, errstr)
+			panic(http.ErrAbortHandler) // the client must not take the partial content for the object
+		}
+		if off >= written && done {
+			break
+		}
+		n, err := io.CopyBuffer(w, io.NewSectionReader(file, off, written-off), buf)
+		off += n
+		if err != nil {
+			glog.Errorf("Failed to send %s/%s, err: %v", bucket, objname, err)
+			return
+		}
+	}
+	if c.props.nhobj != nil {
+		htype, hval := c.props.nhobj.get()
+		w.Header().Set(HeaderDfcChecksumType, htype)
+		w.Header().Set(HeaderDfcChecksumVal, hval)
+	}
+	if c.props.version != "" {
+		w.Header().Set(HeaderDfcObjVersion, c.props.version)
+	}
+	meta := c.props.meta
+	if meta == nil {
+		meta = t.objmeta(fqn)
+	}
+	for k, v := range meta {
+		w.Header().Set(http.TrailerPrefix+HeaderDfcMetaPrefix+k, v)
+	}
+	if glog.V(4) {
+		glog.Infof("GET: %s/%s, %.2f MB, %d µs (tee)", bucket, objname, float64(off)/MiB, time.Since(started)/1000)
+	}
+	t.statsif.addMany("numget", int64(1), "getlatency", int64(time.Since(started)/1000))
+}
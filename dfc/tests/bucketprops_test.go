@@ -0,0 +1,247 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The test sets per-bucket overrides of the cluster-wide configuration and checks
+// that HEAD bucket reports them
+// 	go test -v -run=bucketprops
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+)
+
+const (
+	propsBucket   = "propsbucket"
+	propsNumFiles = 5
+	propsFileSize = 4 * 1024
+)
+
+func Test_bucketprops(t *testing.T) {
+	if err := client.CreateLocalBucket(proxyurl, propsBucket); err != nil {
+		t.Fatalf("Failed to create local bucket %s: %v", propsBucket, err)
+	}
+	waitForLocalBucket(t, propsBucket, true)
+	defer destroyLocalBucket(httpclient, t, propsBucket)
+
+	props := dfc.BucketProps{
+		CksumConf:   &dfc.CksumConf{Checksum: dfc.ChecksumNone},
+		VersionConf: &dfc.VersionConf{Versioning: dfc.VersionNone},
+		LRUConf:     &dfc.LRUConf{LowWM: 50, HighWM: 70},
+		NextTierURL: "http://localhost:8080",
+	}
+	if err := client.SetBucketProps(proxyurl, propsBucket, props); err != nil {
+		t.Fatalf("Failed to set bucket %s properties: %v", propsBucket, err)
+	}
+	bprops, err := client.HeadBucket(proxyurl, propsBucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", propsBucket, err)
+	}
+	if bprops.Checksum != dfc.ChecksumNone || bprops.Versioning != dfc.VersionNone {
+		t.Errorf("Bucket %s: checksum %q, versioning %q - expected %q, %q",
+			propsBucket, bprops.Checksum, bprops.Versioning, dfc.ChecksumNone, dfc.VersionNone)
+	}
+	if bprops.LRUEnabled || bprops.LRULowWM != 50 || bprops.LRUHighWM != 70 {
+		t.Errorf("Bucket %s: unexpected LRU enabled %t, watermarks %d/%d",
+			propsBucket, bprops.LRUEnabled, bprops.LRULowWM, bprops.LRUHighWM)
+	}
+	if bprops.NextTierURL != props.NextTierURL {
+		t.Errorf("Bucket %s: next tier %q, expected %q", propsBucket, bprops.NextTierURL, props.NextTierURL)
+	}
+	gprops, err := client.GetBucketProps(proxyurl, propsBucket)
+	if err != nil {
+		t.Fatalf("Failed to get bucket %s properties: %v", propsBucket, err)
+	}
+	if gprops.CksumConf == nil || gprops.VersionConf == nil || gprops.LRUConf == nil || *gprops.LRUConf != *props.LRUConf {
+		t.Errorf("Bucket %s: unexpected properties %+v", propsBucket, gprops)
+	}
+
+	// invalid overrides; erasure coding and replication cannot be changed
+	invalid := []dfc.BucketProps{
+		{LRUConf: &dfc.LRUConf{LowWM: 80, HighWM: 70}},
+		{CksumConf: &dfc.CksumConf{Checksum: dfc.ChecksumMD5}},
+		{VersionConf: &dfc.VersionConf{Versioning: "some"}},
+		{NextTierURL: "localhost"},
+		{Copies: 2},
+	}
+	for _, p := range invalid {
+		if err = client.SetBucketProps(proxyurl, propsBucket, p); err == nil {
+			t.Errorf("Setting bucket %s properties %+v was expected to fail", propsBucket, p)
+		}
+	}
+
+	// objects are stored without checksums and versions
+	for i := 0; i < propsNumFiles; i++ {
+		name := fmt.Sprintf("props/obj%d", i)
+		reader, err := readers.NewRandReader(propsFileSize, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, propsBucket, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", propsBucket, name, err)
+		}
+	}
+	msg := &dfc.GetMsg{GetPrefix: "props/", GetProps: dfc.GetPropsVersion}
+	bucketList, err := client.ListBucket(proxyurl, propsBucket, msg, 0)
+	if err != nil {
+		t.Fatalf("Failed to list bucket %s: %v", propsBucket, err)
+	}
+	if len(bucketList.Entries) != propsNumFiles {
+		t.Errorf("Expected %d objects, listed %d", propsNumFiles, len(bucketList.Entries))
+	}
+	for _, entry := range bucketList.Entries {
+		if entry.Version != "" {
+			t.Errorf("Object %s has version %q with versioning disabled for the bucket", entry.Name, entry.Version)
+		}
+	}
+}
+
+func Test_cloudbucketprops(t *testing.T) {
+	bprops, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if bprops.CloudProvider == dfc.ProviderDfc {
+		t.Skipf("Bucket %s is a local bucket", clibucket)
+	}
+	props := dfc.BucketProps{
+		VersionConf: &dfc.VersionConf{Versioning: dfc.VersionAll},
+		Pinned:      true,
+	}
+	if err = client.SetBucketProps(proxyurl, clibucket, props); err != nil {
+		t.Fatalf("Failed to set Cloud bucket %s properties: %v", clibucket, err)
+	}
+	defer func() {
+		if err := client.SetBucketProps(proxyurl, clibucket, dfc.BucketProps{}); err != nil {
+			t.Errorf("Failed to reset Cloud bucket %s properties: %v", clibucket, err)
+		}
+	}()
+	if bprops, err = client.HeadBucket(proxyurl, clibucket); err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if !bprops.Pinned || bprops.ValidateWarmGet {
+		t.Errorf("Bucket %s: pinned %t, validate warm GET %t - expected true, false",
+			clibucket, bprops.Pinned, bprops.ValidateWarmGet)
+	}
+	gprops, err := client.GetBucketProps(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to get bucket %s properties: %v", clibucket, err)
+	}
+	if !gprops.Pinned || gprops.VersionConf == nil || gprops.VersionConf.ValidateWarmGet {
+		t.Errorf("Bucket %s: unexpected properties %+v", clibucket, gprops)
+	}
+
+	// local bucket only
+	invalid := []dfc.BucketProps{
+		{NextTierURL: "http://localhost:8080"},
+		{Copies: 2},
+		{EC: dfc.ECConf{DataSlices: 2, ParitySlices: 1}},
+	}
+	for _, p := range invalid {
+		if err = client.SetBucketProps(proxyurl, clibucket, p); err == nil {
+			t.Errorf("Setting Cloud bucket %s properties %+v was expected to fail", clibucket, p)
+		}
+	}
+
+	// the other buckets are not affected
+	if err := client.CreateLocalBucket(proxyurl, propsBucket); err != nil {
+		t.Fatalf("Failed to create local bucket %s: %v", propsBucket, err)
+	}
+	waitForLocalBucket(t, propsBucket, true)
+	defer destroyLocalBucket(httpclient, t, propsBucket)
+	if bprops, err = client.HeadBucket(proxyurl, propsBucket); err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", propsBucket, err)
+	}
+	if bprops.Pinned {
+		t.Errorf("Bucket %s is not expected to be pinned", propsBucket)
+	}
+}
+
+func Test_bucketsummary(t *testing.T) {
+	const evicted = 2
+	createLocalBucket(httpclient, t, propsBucket)
+	defer destroyLocalBucket(httpclient, t, propsBucket)
+
+	buckets := []string{propsBucket}
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderDfc {
+		buckets = append(buckets, clibucket)
+	}
+	for _, bucket := range buckets {
+		before, err := client.GetBucketSummary(proxyurl, bucket)
+		if err != nil {
+			t.Fatalf("Failed to get bucket %s summary: %v", bucket, err)
+		}
+		for i := 0; i < propsNumFiles; i++ {
+			name := fmt.Sprintf("summary/obj%d", i)
+			r, err := readers.NewRandReader(propsFileSize, true /* withHash */)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = client.Put(proxyurl, r, bucket, name, true); err != nil {
+				t.Fatalf("Failed to PUT %s/%s: %v", bucket, name, err)
+			}
+			if bucket == clibucket {
+				defer client.Del(proxyurl, bucket, name, nil, nil, true)
+			}
+		}
+		cached := propsNumFiles
+		if bucket == clibucket {
+			for i := 0; i < evicted; i++ {
+				if err = client.Evict(proxyurl, bucket, fmt.Sprintf("summary/obj%d", i)); err != nil {
+					t.Fatalf("Failed to evict %s/summary/obj%d: %v", bucket, i, err)
+				}
+			}
+			cached -= evicted
+		}
+		summary, err := client.GetBucketSummary(proxyurl, bucket)
+		if err != nil {
+			t.Fatalf("Failed to get bucket %s summary: %v", bucket, err)
+		}
+		if summary.Objects-before.Objects != int64(cached) || summary.Bytes-before.Bytes != int64(cached)*propsFileSize {
+			t.Errorf("Bucket %s: %d objects and %d bytes added, expected %d and %d", bucket,
+				summary.Objects-before.Objects, summary.Bytes-before.Bytes, cached, cached*propsFileSize)
+		}
+		var objects, bytes int64
+		for id, tsummary := range summary.Targets {
+			var mobjects, mbytes int64
+			for _, usage := range tsummary.Mountpaths {
+				mobjects += usage.Objects
+				mbytes += usage.Bytes
+			}
+			if mobjects != tsummary.Objects || mbytes != tsummary.Bytes {
+				t.Errorf("Bucket %s, target %s: mountpaths add up to %d objects and %d bytes, expected %d and %d",
+					bucket, id, mobjects, mbytes, tsummary.Objects, tsummary.Bytes)
+			}
+			objects += tsummary.Objects
+			bytes += tsummary.Bytes
+		}
+		if objects != summary.Objects || bytes != summary.Bytes {
+			t.Errorf("Bucket %s: targets add up to %d objects and %d bytes, expected %d and %d",
+				bucket, objects, bytes, summary.Objects, summary.Bytes)
+		}
+		if bucket != clibucket {
+			continue
+		}
+		if summary.CloudObjects-before.CloudObjects != propsNumFiles ||
+			summary.CloudBytes-before.CloudBytes != propsNumFiles*propsFileSize {
+			t.Errorf("Bucket %s: %d objects and %d bytes added to the Cloud, expected %d and %d", bucket,
+				summary.CloudObjects-before.CloudObjects, summary.CloudBytes-before.CloudBytes,
+				propsNumFiles, propsNumFiles*propsFileSize)
+		}
+		if fraction := float64(summary.Bytes) / float64(summary.CloudBytes); summary.CachedFraction != fraction {
+			t.Errorf("Bucket %s: cached fraction %f, expected %f", bucket, summary.CachedFraction, fraction)
+		}
+	}
+}
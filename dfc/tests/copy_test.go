@@ -0,0 +1,178 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The tests copy and move objects between local and Cloud buckets, and rename
+// local buckets; the copies must keep the size and the checksum of the originals
+// 	go test -v -run=copy
+// 	go test -v -run=move
+// 	go test -v -run=renamelb
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+)
+
+const (
+	copyBucket   = "copybucket"
+	copyNumFiles = 10
+	copyFileSize = 16 * 1024
+)
+
+func Test_copy(t *testing.T) {
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+	createLocalBucket(httpclient, t, copyBucket)
+	defer destroyLocalBucket(httpclient, t, copyBucket)
+
+	names := make([]string, 0, copyNumFiles)
+	cksums := make(map[string]string, copyNumFiles)
+	for i := 0; i < copyNumFiles; i++ {
+		name := fmt.Sprintf("copy/obj%d", i)
+		reader, err := readers.NewRandReader(copyFileSize, true /* withHash */)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, TestLocalBucketName, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, name, err)
+		}
+		names = append(names, name)
+		cksums[name] = reader.XXHash()
+	}
+
+	// single object, renamed on the way
+	if err := client.CopyObject(proxyurl, TestLocalBucketName, names[0], copyBucket, "copied/obj0"); err != nil {
+		t.Fatalf("Failed to copy %s/%s: %v", TestLocalBucketName, names[0], err)
+	}
+	checkCopy(t, copyBucket, "copied/obj0", cksums[names[0]])
+	if err := client.CopyObject(proxyurl, TestLocalBucketName, names[0], TestLocalBucketName, ""); err == nil {
+		t.Errorf("Copying %s/%s onto itself was expected to fail", TestLocalBucketName, names[0])
+	}
+	if err := client.CopyObject(proxyurl, TestLocalBucketName, "copy/nonexistent", copyBucket, ""); err == nil {
+		t.Errorf("Copying a non-existent object was expected to fail")
+	}
+
+	// list: local => local
+	if err := client.CopyList(proxyurl, TestLocalBucketName, copyBucket, names, true, 0); err != nil {
+		t.Fatalf("Failed to copy the list of %d objects: %v", len(names), err)
+	}
+	for _, name := range names {
+		checkCopy(t, copyBucket, name, cksums[name])
+	}
+
+	// range: local => Cloud
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider == dfc.ProviderDfc {
+		return
+	}
+	if err = client.CopyRange(proxyurl, copyBucket, clibucket, "copy/", "", "", true, 0); err != nil {
+		t.Fatalf("Failed to copy %s/copy/* to %s: %v", copyBucket, clibucket, err)
+	}
+	for _, name := range names {
+		defer client.Del(proxyurl, clibucket, name, nil, nil, true)
+		checkCopy(t, clibucket, name, cksums[name])
+	}
+}
+
+func Test_move(t *testing.T) {
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+	createLocalBucket(httpclient, t, copyBucket)
+	defer destroyLocalBucket(httpclient, t, copyBucket)
+
+	const objname = "move/obj"
+	reader, err := readers.NewRandReader(copyFileSize, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, TestLocalBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	if err = client.MoveObject(proxyurl, TestLocalBucketName, objname, copyBucket, "moved/obj"); err != nil {
+		t.Fatalf("Failed to move %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	checkCopy(t, copyBucket, "moved/obj", reader.XXHash())
+	if _, err = client.HeadObject(proxyurl, TestLocalBucketName, objname); err == nil {
+		t.Errorf("%s/%s still exists after having been moved", TestLocalBucketName, objname)
+	}
+}
+
+func Test_renamelb(t *testing.T) {
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+
+	cksums := make(map[string]string, copyNumFiles)
+	for i := 0; i < copyNumFiles; i++ {
+		name := fmt.Sprintf("renamelb/obj%d", i)
+		reader, err := readers.NewRandReader(copyFileSize, true /* withHash */)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, TestLocalBucketName, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, name, err)
+		}
+		cksums[name] = reader.XXHash()
+	}
+	if err := client.RenameLocalBucket(proxyurl, TestLocalBucketName, copyBucket); err != nil {
+		destroyLocalBucket(httpclient, t, TestLocalBucketName)
+		t.Fatalf("Failed to rename local bucket %s => %s: %v", TestLocalBucketName, copyBucket, err)
+	}
+	defer destroyLocalBucket(httpclient, t, copyBucket)
+
+	buckets, err := client.ListBuckets(proxyurl, true)
+	if err != nil {
+		t.Fatalf("Failed to list local buckets: %v", err)
+	}
+	found := false
+	for _, bucket := range buckets.Local {
+		if bucket == TestLocalBucketName {
+			t.Errorf("Local bucket %s still exists after having been renamed", bucket)
+		}
+		found = found || bucket == copyBucket
+	}
+	if !found {
+		t.Fatalf("Renamed local bucket %s does not exist", copyBucket)
+	}
+
+	// wait for the objects to migrate
+	for i := 0; i < 10; i++ {
+		missing := 0
+		for name := range cksums {
+			if _, err := client.HeadObject(proxyurl, copyBucket, name); err != nil {
+				missing++
+			}
+		}
+		if missing == 0 {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	for name, cksum := range cksums {
+		checkCopy(t, copyBucket, name, cksum)
+	}
+}
+
+func checkCopy(t *testing.T, bucket, objname, cksum string) {
+	objprops, err := client.HeadObject(proxyurl, bucket, objname)
+	if err != nil {
+		t.Errorf("Failed to HEAD the copy %s/%s: %v", bucket, objname, err)
+		return
+	}
+	if objprops.Size != copyFileSize {
+		t.Errorf("Copy %s/%s: size %d, expected %d", bucket, objname, objprops.Size, copyFileSize)
+	}
+	if objprops.ChecksumType == dfc.ChecksumXXHash && objprops.ChecksumValue != cksum {
+		t.Errorf("Copy %s/%s: checksum %s, expected %s", bucket, objname, objprops.ChecksumValue, cksum)
+	}
+}
@@ -0,0 +1,111 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The test requires at least 4 targets: it creates 2:1 erasure coded local bucket,
+// shuts down one of the targets and reads back its objects reconstructed from slices
+// 	go test -v -run=ec
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	ecBucket      = "ecbucket"
+	ecDataSlices  = 2
+	ecParitySlice = 1
+	ecNumFiles    = 20
+	ecFileSize    = 64*1024 + 7 // not a multiple of the number of data slices
+)
+
+func Test_ec(t *testing.T) {
+	smap := getClusterMap(httpclient, t)
+	if len(smap.Smap) < ecDataSlices+ecParitySlice+1 {
+		t.Skipf("Erasure coding %d:%d requires at least %d targets, have %d",
+			ecDataSlices, ecParitySlice, ecDataSlices+ecParitySlice+1, len(smap.Smap))
+	}
+	props := dfc.BucketProps{EC: dfc.ECConf{DataSlices: ecDataSlices, ParitySlices: ecParitySlice}}
+	if err := client.CreateLocalBucketWithProps(proxyurl, ecBucket, props); err != nil {
+		t.Fatalf("Failed to create erasure coded local bucket %s: %v", ecBucket, err)
+	}
+	waitForLocalBucket(t, ecBucket, true)
+	defer destroyLocalBucket(httpclient, t, ecBucket)
+
+	names := make([]string, 0, ecNumFiles)
+	for i := 0; i < ecNumFiles; i++ {
+		name := fmt.Sprintf("ec/obj%03d", i)
+		reader, err := readers.NewRandReader(ecFileSize, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, ecBucket, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", ecBucket, name, err)
+		}
+		names = append(names, name)
+	}
+	meta := map[string]string{"Label": "cat"}
+	putobjmeta(t, ecBucket, names[0], ecFileSize, meta)
+
+	// shut down the target that owns the first object
+	var targetID, targetURL, targetPort string
+	var max uint64
+	for id, sinfo := range smap.Smap {
+		cs := xxhash.ChecksumString64S(id+":"+ecBucket+"/"+names[0], HRWmLCG32)
+		if cs > max {
+			max, targetID, targetURL, targetPort = cs, id, sinfo.DirectURL, sinfo.DaemonPort
+		}
+	}
+	tcmd, targs, err := kill(httpclient, targetURL, targetPort)
+	if err != nil {
+		t.Fatalf("Failed to shut down target %s: %v", targetID, err)
+	}
+	time.Sleep(5 * time.Second) // FIXME: Deterministic wait for smap propogation
+	if smap = getClusterMap(httpclient, t); smap.Smap[targetID] != nil {
+		t.Errorf("Target %s was not removed from the cluster map", targetID)
+	}
+
+	// the objects that were stored on the target are now restored from their slices
+	for _, name := range names {
+		if _, _, err = client.Get(proxyurl, ecBucket, name, nil, nil, true, true); err != nil {
+			t.Errorf("GET %s/%s failed: %v", ecBucket, name, err)
+		}
+	}
+	checkobjmeta(t, ecBucket, names[0], meta)
+	for _, name := range names {
+		if err = client.Del(proxyurl, ecBucket, name, nil, nil, true); err != nil {
+			t.Errorf("Failed to DELETE %s/%s: %v", ecBucket, name, err)
+		}
+	}
+	// neither the objects nor their slices exist anymore
+	for _, name := range names {
+		if _, _, err = client.Get(proxyurl, ecBucket, name, nil, nil, true, false); err == nil {
+			t.Errorf("GET deleted %s/%s did not fail", ecBucket, name)
+		}
+	}
+
+	for i, arg := range targs {
+		if strings.Contains(arg, "-proxyurl") {
+			targs = append(targs[:i], targs[i+1:]...)
+			break
+		}
+	}
+	if err = restore(httpclient, targetURL, tcmd, targs); err != nil {
+		t.Errorf("Failed to restart target %s: %v", targetID, err)
+	}
+	time.Sleep(5 * time.Second)
+	if smap = getClusterMap(httpclient, t); smap.Smap[targetID] == nil {
+		t.Errorf("Restarted target %s did not rejoin the cluster", targetID)
+	}
+}
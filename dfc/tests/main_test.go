@@ -49,6 +49,7 @@ import (
 
 	"github.com/NVIDIA/dfcpub/dfc"
 	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
 	"github.com/OneOfOne/xxhash"
 )
 
@@ -492,6 +493,77 @@ func Test_list(t *testing.T) {
 	}
 }
 
+func Test_listsort(t *testing.T) {
+	const (
+		numobjs  = 30
+		pagesize = 4
+	)
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	for i := 0; i < numobjs; i++ {
+		// a few distinct sizes, to have the ties broken by name
+		r, err := readers.NewRandReader(int64(i%7+1)*1024, true /* withHash */)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objname := fmt.Sprintf("sort/obj%02d", (i*7)%numobjs)
+		if err = client.Put(proxyurl, r, TestLocalBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objname, err)
+		}
+	}
+	type key struct {
+		n    int64
+		name string
+	}
+	tests := []struct {
+		sort string
+		key  func(e *dfc.BucketEntry) int64
+		desc bool
+	}{
+		{"", func(e *dfc.BucketEntry) int64 { return 0 }, false},
+		{"descending, name", func(e *dfc.BucketEntry) int64 { return 0 }, true},
+		{"ascending, size", func(e *dfc.BucketEntry) int64 { return e.Size }, false},
+		{"descending, size", func(e *dfc.BucketEntry) int64 { return e.Size }, true},
+		{"ctime", func(e *dfc.BucketEntry) int64 {
+			tm, err := time.Parse(time.RFC3339Nano, e.Ctime)
+			if err != nil {
+				t.Errorf("Failed to parse ctime %q: %v", e.Ctime, err)
+			}
+			return tm.UnixNano()
+		}, false},
+	}
+	for _, test := range tests {
+		msg := &dfc.GetMsg{GetSort: test.sort, GetProps: dfc.GetPropsSize + "," + dfc.GetPropsCtime,
+			GetTimeFormat: time.RFC3339Nano, GetPrefix: "sort/", GetPageSize: pagesize}
+		list, err := client.ListBucket(proxyurl, TestLocalBucketName, msg, 0)
+		if err != nil {
+			t.Fatalf("Failed to list bucket %s sorted %q: %v", TestLocalBucketName, test.sort, err)
+		}
+		if len(list.Entries) != numobjs {
+			t.Errorf("Sorted %q: listed %d objects, expected %d", test.sort, len(list.Entries), numobjs)
+			for _, e := range list.Entries {
+				t.Logf("%s %d %s", e.Name, e.Size, e.Ctime)
+			}
+		}
+		for i := 1; i < len(list.Entries); i++ {
+			prev := key{test.key(list.Entries[i-1]), list.Entries[i-1].Name}
+			cur := key{test.key(list.Entries[i]), list.Entries[i].Name}
+			less := prev.n < cur.n || (prev.n == cur.n && prev.name < cur.name)
+			if test.desc {
+				less = prev.n > cur.n || (prev.n == cur.n && prev.name > cur.name)
+			}
+			if !less {
+				t.Errorf("Sorted %q: %s (%d) listed before %s (%d)", test.sort, prev.name, prev.n, cur.name, cur.n)
+			}
+		}
+	}
+	msg := &dfc.GetMsg{GetSort: "sideways"}
+	if _, err := client.ListBucket(proxyurl, TestLocalBucketName, msg, 0); err == nil {
+		t.Errorf("Listing with invalid sort option %q succeeded", msg.GetSort)
+	}
+}
+
 func Test_bucketnames(t *testing.T) {
 	var (
 		url = proxyurl + "/" + dfc.Rversion + "/" + dfc.Rbuckets + "/" + "*"
@@ -618,7 +690,6 @@ cleanup:
 func Test_headbucket(t *testing.T) {
 	// Test that a local bucket returns Server:"DFC"
 	createLocalBucket(httpclient, t, TestLocalBucketName)
-	time.Sleep(time.Second * 2) // FIXME
 	props, err := client.HeadBucket(proxyurl, TestLocalBucketName)
 	if err != nil {
 		t.Errorf("Failed to execute HeadBucket: %v", err)
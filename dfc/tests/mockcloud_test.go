@@ -0,0 +1,423 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The test requires a cluster deployed with "cloudprovider": "mock" - for instance:
+// 	BUCKET=mockbucket go test -v -run=mockcloud
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+)
+
+const (
+	mockCloudDir      = "mockcloud"
+	mockCloudNumFiles = 25
+	mockCloudPageSize = 10
+	mockCloudFileSize = 4 * 1024
+)
+
+func Test_mockcloud(t *testing.T) {
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+	names := make([]string, 0, mockCloudNumFiles)
+	for i := 0; i < mockCloudNumFiles; i++ {
+		name := fmt.Sprintf("%s/obj%03d", mockCloudDir, i)
+		reader, err := readers.NewRandReader(mockCloudFileSize, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, clibucket, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", clibucket, name, err)
+		}
+		names = append(names, name)
+	}
+	defer func() {
+		setConfig("mock_error_pct", "0", clusterurl, httpclient, t)
+		for _, name := range names {
+			if err := client.Del(proxyurl, clibucket, name, nil, nil, true); err != nil {
+				t.Errorf("Failed to DELETE %s/%s: %v", clibucket, name, err)
+			}
+		}
+	}()
+
+	// listbucket paging across the targets
+	msg := &dfc.GetMsg{GetPrefix: mockCloudDir + "/", GetPageSize: mockCloudPageSize, GetProps: dfc.GetPropsVersion}
+	bucketList, err := client.ListBucket(proxyurl, clibucket, msg, 0)
+	if err != nil {
+		t.Fatalf("Failed to list bucket %s: %v", clibucket, err)
+	}
+	if len(bucketList.Entries) != mockCloudNumFiles {
+		t.Errorf("Expected %d objects, listed %d", mockCloudNumFiles, len(bucketList.Entries))
+	}
+	for i, entry := range bucketList.Entries {
+		if i < len(names) && entry.Name != names[i] {
+			t.Errorf("Listed object #%d: %s, expected %s", i, entry.Name, names[i])
+		}
+		if entry.Version == "" {
+			t.Errorf("Object %s has no version", entry.Name)
+		}
+	}
+
+	// cold GET (validating MD5) after eviction
+	if err = client.Evict(proxyurl, clibucket, names[0]); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, names[0], err)
+	}
+	if _, _, err = client.Get(proxyurl, clibucket, names[0], nil, nil, true, true); err != nil {
+		t.Errorf("Cold GET %s/%s failed: %v", clibucket, names[0], err)
+	}
+
+	// injected failures
+	if err = client.Evict(proxyurl, clibucket, names[1]); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, names[1], err)
+	}
+	setConfig("mock_error_pct", "100", clusterurl, httpclient, t)
+	if _, _, err = client.Get(proxyurl, clibucket, names[1], nil, nil, true, false); err == nil {
+		t.Errorf("Cold GET %s/%s was expected to fail", clibucket, names[1])
+	}
+	setConfig("mock_error_pct", "0", clusterurl, httpclient, t)
+	if _, _, err = client.Get(proxyurl, clibucket, names[1], nil, nil, true, true); err != nil {
+		t.Errorf("Cold GET %s/%s failed: %v", clibucket, names[1], err)
+	}
+}
+
+func Test_teecoldget(t *testing.T) {
+	const (
+		objname    = mockCloudDir + "/tee"
+		numreaders = 8
+		size       = 1024 * 1024
+	)
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	config := getConfig(proxyurl+"/"+dfc.Rversion+"/"+dfc.Rdaemon, httpclient, t)
+	if tee, _ := config["experimental"].(map[string]interface{})["tee_cold_get"].(bool); !tee {
+		t.Skip("tee cold GET is disabled")
+	}
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+	reader, err := readers.NewRandReader(size, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	// concurrent GETs attach to the download held up by the mock Cloud latency
+	setConfig("mock_latency", "500ms", clusterurl, httpclient, t)
+	defer setConfig("mock_latency", "0s", clusterurl, httpclient, t)
+	coldgets := func() (n int64) {
+		for _, v := range getClusterStats(httpclient, t).Target {
+			n += v.Core.Numcoldget
+		}
+		return
+	}
+	before := coldgets()
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	wg := &sync.WaitGroup{}
+	for i := 0; i < numreaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := httpclient.Get(url)
+			if err != nil {
+				t.Errorf("GET %s/%s failed: %v", clibucket, objname, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("GET %s/%s: status %d", clibucket, objname, resp.StatusCode)
+				return
+			}
+			n, hash, err := client.ReadWriteWithHash(resp.Body, ioutil.Discard)
+			if err != nil {
+				t.Errorf("GET %s/%s: failed to read, err: %v", clibucket, objname, err)
+				return
+			}
+			// the checksum is returned as trailer if the object has been streamed while downloading
+			cksum := resp.Header.Get(dfc.HeaderDfcChecksumVal)
+			if cksum == "" {
+				cksum = resp.Trailer.Get(dfc.HeaderDfcChecksumVal)
+			}
+			if n != size || hash != reader.XXHash() || cksum != reader.XXHash() {
+				t.Errorf("GET %s/%s: %d bytes, hash %s, checksum %s, expected %d bytes, %s",
+					clibucket, objname, n, hash, cksum, size, reader.XXHash())
+			}
+		}()
+	}
+	wg.Wait()
+	if n := coldgets() - before; n != 1 {
+		t.Errorf("%d GETs of %s/%s resulted in %d cold GETs, expected 1", numreaders, clibucket, objname, n)
+	}
+
+	// failed download
+	if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	setConfig("mock_error_pct", "100", clusterurl, httpclient, t)
+	defer setConfig("mock_error_pct", "0", clusterurl, httpclient, t)
+	if _, _, err = client.Get(proxyurl, clibucket, objname, nil, nil, true, false); err == nil {
+		t.Errorf("GET %s/%s succeeded while the Cloud is failing", clibucket, objname)
+	}
+}
+
+func Test_rangedcoldget(t *testing.T) {
+	const (
+		objname  = mockCloudDir + "/ranged"
+		partsize = 1024 * 1024
+		size     = 5*partsize + 123
+	)
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	config := getConfig(proxyurl+"/"+dfc.Rversion+"/"+dfc.Rdaemon, httpclient, t)
+	coldgetcfg := config["cold_get"].(map[string]interface{})
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+	setConfig("cold_get_part_size", fmt.Sprint(partsize), clusterurl, httpclient, t)
+	defer setConfig("cold_get_part_size", fmt.Sprint(coldgetcfg["part_size"]), clusterurl, httpclient, t)
+	setConfig("cold_get_concurrency", "4", clusterurl, httpclient, t)
+	defer setConfig("cold_get_concurrency", fmt.Sprint(coldgetcfg["concurrency"]), clusterurl, httpclient, t)
+
+	reader, err := readers.NewRandReader(size, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	coldget := func() {
+		if err := client.Evict(proxyurl, clibucket, objname); err != nil {
+			t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+		}
+		resp, err := httpclient.Get(url)
+		if err != nil {
+			t.Fatalf("GET %s/%s failed: %v", clibucket, objname, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s/%s: status %d", clibucket, objname, resp.StatusCode)
+		}
+		n, hash, err := client.ReadWriteWithHash(resp.Body, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("GET %s/%s: failed to read, err: %v", clibucket, objname, err)
+		}
+		if n != size || hash != reader.XXHash() {
+			t.Errorf("GET %s/%s: %d bytes, hash %s, expected %d bytes, %s", clibucket, objname, n, hash, size, reader.XXHash())
+		}
+	}
+	// validated against the xxhash provided at PUT time
+	coldget()
+	// validated against the mock Cloud MD5
+	setConfig("checksum", dfc.ChecksumNone, clusterurl, httpclient, t)
+	defer setConfig("checksum", dfc.ChecksumXXHash, clusterurl, httpclient, t)
+	coldget()
+}
+
+func Test_resumecoldget(t *testing.T) {
+	resumecoldget(t, nil)
+}
+
+// resumecoldget retries the GET of the object whose download gets interrupted until the
+// download completes; restart, if not nil, restarts the object's target after the first try
+func resumecoldget(t *testing.T, restart func(objname string)) {
+	const (
+		objname    = mockCloudDir + "/resume"
+		size       = 1024 * 1024
+		abortafter = 300 * 1024
+	)
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	config := getConfig(proxyurl+"/"+dfc.Rversion+"/"+dfc.Rdaemon, httpclient, t)
+	coldgetcfg := config["cold_get"].(map[string]interface{})
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+
+	reader, err := readers.NewRandReader(size, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	// every GET from the mock Cloud drops after abortafter bytes: only resuming gets the whole object
+	setConfig("cold_get_part_size", "0", clusterurl, httpclient, t)
+	defer setConfig("cold_get_part_size", fmt.Sprint(coldgetcfg["part_size"]), clusterurl, httpclient, t)
+	setConfig("mock_abort_after", fmt.Sprint(abortafter), clusterurl, httpclient, t)
+	defer setConfig("mock_abort_after", "0", clusterurl, httpclient, t)
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	get := func() error {
+		resp, err := httpclient.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		n, hash, err := client.ReadWriteWithHash(resp.Body, ioutil.Discard)
+		if err != nil {
+			return err
+		}
+		if n != size || hash != reader.XXHash() {
+			return fmt.Errorf("%d bytes, hash %s, expected %d bytes, %s", n, hash, size, reader.XXHash())
+		}
+		return nil
+	}
+	expected := (size+abortafter-1)/abortafter - 1
+	for failed := 0; ; failed++ {
+		if err = get(); err == nil {
+			if failed != expected {
+				t.Errorf("GET %s/%s succeeded after %d failures, expected %d", clibucket, objname, failed, expected)
+			}
+			break
+		}
+		if failed == expected {
+			t.Fatalf("GET %s/%s failed after %d retries: %v", clibucket, objname, failed, err)
+		}
+		tlogf("GET %s/%s interrupted: %v\n", clibucket, objname, err)
+		if failed == 0 && restart != nil {
+			restart(objname)
+			// the restarted target comes up with the configured values
+			setConfig("cold_get_part_size", "0", clusterurl, httpclient, t)
+			setConfig("mock_abort_after", fmt.Sprint(abortafter), clusterurl, httpclient, t)
+		}
+	}
+}
+
+// Test_namelock: concurrent (range, and therefore not streamed) GETs of the object
+// that is being downloaded wait for the download to finish - rather than poll for it
+func Test_namelock(t *testing.T) {
+	const (
+		objname    = mockCloudDir + "/namelock"
+		numreaders = 16
+		size       = 64 * 1024
+		latency    = 500 * time.Millisecond
+	)
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+	reader, err := readers.NewRandReader(size, false /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	setConfig("mock_latency", latency.String(), clusterurl, httpclient, t)
+	defer setConfig("mock_latency", "0s", clusterurl, httpclient, t)
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	started := time.Now()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < numreaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			req.Header.Set("Range", "bytes=0-1023")
+			resp, err := httpclient.Do(req)
+			if err != nil {
+				t.Errorf("GET %s/%s failed: %v", clibucket, objname, err)
+				return
+			}
+			defer resp.Body.Close()
+			n, err := io.Copy(ioutil.Discard, resp.Body)
+			if err != nil || resp.StatusCode >= http.StatusBadRequest || n != 1024 {
+				t.Errorf("GET %s/%s: status %d, %d bytes, err: %v", clibucket, objname, resp.StatusCode, n, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// the GETs that wait for the download are woken up once it is done
+	if elapsed := time.Since(started); elapsed > 2*latency {
+		t.Errorf("%d concurrent GETs of %s/%s took %v (mock Cloud latency %v)", numreaders, clibucket, objname, elapsed, latency)
+	}
+}
+
+// Test_createonlyfailure: a create-only PUT fails if the Cloud cannot tell whether
+// the object exists (as opposed to telling that it does not)
+func Test_createonlyfailure(t *testing.T) {
+	const objname = mockCloudDir + "/createonly"
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderMock {
+		t.Skipf("Bucket %s is not a mock Cloud bucket (provider %q)", clibucket, props.CloudProvider)
+	}
+	clusterurl := proxyurl + "/" + dfc.Rversion + "/" + dfc.Rcluster
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	client.Del(proxyurl, clibucket, objname, nil, nil, true)
+
+	setConfig("mock_error_pct", "100", clusterurl, httpclient, t)
+	defer setConfig("mock_error_pct", "0", clusterurl, httpclient, t)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(make([]byte, mockCloudFileSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", "*")
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("Create-only PUT %s/%s failed: %v", clibucket, objname, err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(b), "whether the object exists") {
+		t.Errorf("Create-only PUT %s/%s: status %d (%s), expected %d upon failing to HEAD the object",
+			clibucket, objname, resp.StatusCode, strings.TrimSpace(string(b)), http.StatusServiceUnavailable)
+	}
+}
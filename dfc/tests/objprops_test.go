@@ -5,7 +5,11 @@
 package dfc_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -458,3 +462,490 @@ func Test_objpropsVersionDisabled(t *testing.T) {
 
 	propsMainTest(t, dfc.VersionNone)
 }
+
+func Test_headobject(t *testing.T) {
+	const objname = "headobject/obj"
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	reader, err := readers.NewRandReader(int64(fileSize), true /* withHash */)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, TestLocalBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	objprops, err := client.HeadObject(proxyurl, TestLocalBucketName, objname)
+	if err != nil {
+		t.Fatalf("Failed to HEAD %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	if !objprops.IsCached || objprops.Size != int64(fileSize) || objprops.Atime.IsZero() {
+		t.Errorf("%s/%s: unexpected properties %+v", TestLocalBucketName, objname, objprops)
+	}
+	if objprops.ChecksumType == dfc.ChecksumXXHash && objprops.ChecksumValue != reader.XXHash() {
+		t.Errorf("%s/%s: checksum %s, expected %s", TestLocalBucketName, objname, objprops.ChecksumValue, reader.XXHash())
+	}
+	if _, err = client.HeadObject(proxyurl, TestLocalBucketName, objname+"-nonexistent"); err == nil {
+		t.Errorf("HEAD of a non-existent object %s/%s was expected to fail", TestLocalBucketName, objname)
+	}
+
+	// Cloud object that is not cached
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderDfc {
+		if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", clibucket, objname, err)
+		}
+		defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+		if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+			t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+		}
+		if objprops, err = client.HeadObject(proxyurl, clibucket, objname); err != nil {
+			t.Fatalf("Failed to HEAD %s/%s: %v", clibucket, objname, err)
+		}
+		if objprops.IsCached || objprops.Size != int64(fileSize) {
+			t.Errorf("%s/%s: unexpected properties %+v", clibucket, objname, objprops)
+		}
+	}
+}
+
+func Test_conditional(t *testing.T) {
+	const objname = "conditional/obj"
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + TestLocalBucketName + "/" + objname
+	do := func(method string, body []byte, hdr, val string) *http.Response {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr != "" {
+			req.Header.Set(hdr, val)
+		}
+		resp, err := httpclient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s/%s failed: %v", method, TestLocalBucketName, objname, err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	data := make([]byte, fileSize)
+	// create-only PUT: the 2nd one must fail
+	if resp := do(http.MethodPut, data, "If-None-Match", "*"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Create-only PUT: status %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodPut, data, "If-None-Match", "*"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Create-only PUT of the existing object: status %d, expected %d",
+			resp.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	resp := do(http.MethodGet, nil, "", "")
+	etag, lastmod := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || lastmod == "" {
+		t.Fatalf("GET: status %d, Last-Modified %q", resp.StatusCode, lastmod)
+	}
+	if etag == "" {
+		t.Skipf("Bucket %s: no ETag (checksums disabled?)", TestLocalBucketName)
+	}
+	tests := []struct {
+		hdr, val string
+		status   int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Match", etag, http.StatusOK},
+		{"If-Match", `"other"`, http.StatusPreconditionFailed},
+		{"If-Modified-Since", lastmod, http.StatusNotModified},
+		{"If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, test := range tests {
+		if resp = do(http.MethodGet, nil, test.hdr, test.val); resp.StatusCode != test.status {
+			t.Errorf("GET %s: %s: status %d, expected %d", test.hdr, test.val, resp.StatusCode, test.status)
+		}
+	}
+}
+
+// Test_createonlycloud: of the concurrent create-only PUTs of the same Cloud object
+// exactly one succeeds and its content is what gets stored
+func Test_createonlycloud(t *testing.T) {
+	const (
+		objname = "createonly/obj"
+		numputs = 8
+	)
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + clibucket + "/" + objname
+	client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+
+	var (
+		wg       = &sync.WaitGroup{}
+		statuses = make([]int, numputs)
+	)
+	for i := 0; i < numputs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(bytes.Repeat([]byte{byte('a' + i)}, fileSize)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("If-None-Match", "*")
+			resp, err := httpclient.Do(req)
+			if err != nil {
+				t.Errorf("Create-only PUT #%d %s/%s failed: %v", i, clibucket, objname, err)
+				return
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, status := range statuses {
+		switch status {
+		case http.StatusOK:
+			if winner >= 0 {
+				t.Errorf("Create-only PUTs #%d and #%d both succeeded", winner, i)
+			}
+			winner = i
+		case http.StatusPreconditionFailed:
+		default:
+			t.Errorf("Create-only PUT #%d: status %d", i, status)
+		}
+	}
+	if winner < 0 {
+		t.Fatalf("None of the %d create-only PUTs succeeded: %v", numputs, statuses)
+	}
+	// evict the cached copy, if any, to read what has been stored in the Cloud
+	if err := client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	buf := &bytes.Buffer{}
+	if _, _, err := client.GetFile(proxyurl, clibucket, objname, nil, nil, true, false, buf); err != nil {
+		t.Fatalf("Failed to GET %s/%s: %v", clibucket, objname, err)
+	}
+	if !bytes.Equal(buf.Bytes(), bytes.Repeat([]byte{byte('a' + winner)}, fileSize)) {
+		t.Errorf("%s/%s: the content is not that of the successful create-only PUT #%d", clibucket, objname, winner)
+	}
+}
+
+// Test_zerocopyget: the entire object is sent with Content-Length (zero-copy over
+// plain HTTP), range GETs return the requested bytes
+func Test_zerocopyget(t *testing.T) {
+	const (
+		objname = "zerocopy/obj"
+		size    = 1024*1024 + 7
+	)
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + TestLocalBucketName + "/" + objname
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT %s/%s failed: %v", TestLocalBucketName, objname, err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT %s/%s: status %d", TestLocalBucketName, objname, resp.StatusCode)
+	}
+	get := func(rangehdr string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rangehdr != "" {
+			req.Header.Set("Range", rangehdr)
+		}
+		resp, err := httpclient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s/%s failed: %v", TestLocalBucketName, objname, err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("GET %s/%s: failed to read, err: %v", TestLocalBucketName, objname, err)
+		}
+		return resp, body
+	}
+	resp, body := get("")
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("GET %s/%s: status %d, %d bytes, expected %d", TestLocalBucketName, objname, resp.StatusCode, len(body), size)
+	}
+	if resp.Request.URL.Scheme == "http" && resp.ContentLength != size {
+		t.Errorf("GET %s/%s: Content-Length %d, expected %d", TestLocalBucketName, objname, resp.ContentLength, size)
+	}
+	resp, body = get("bytes=1000-1999")
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, data[1000:2000]) {
+		t.Errorf("Range GET %s/%s: status %d, %d bytes", TestLocalBucketName, objname, resp.StatusCode, len(body))
+	}
+}
+
+// Test_rangeget: overlapping and adjacent ranges are coalesced, too many ranges - the entire object
+func Test_rangeget(t *testing.T) {
+	const (
+		objname = "rangeget/obj"
+		size    = 64 * 1024
+	)
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + TestLocalBucketName + "/" + objname
+	do := func(method string, body []byte, rangehdr string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rangehdr != "" {
+			req.Header.Set("Range", rangehdr)
+		}
+		resp, err := httpclient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s/%s failed: %v", method, TestLocalBucketName, objname, err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("%s %s/%s: failed to read, err: %v", method, TestLocalBucketName, objname, err)
+		}
+		return resp, b
+	}
+	if resp, _ := do(http.MethodPut, data, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT %s/%s: status %d", TestLocalBucketName, objname, resp.StatusCode)
+	}
+	many := "bytes=0-0" // disjoint ranges, more than the target sends as multipart
+	for i := 2; i < 200; i += 2 {
+		many += fmt.Sprintf(",%d-%d", i, i)
+	}
+	tests := []struct {
+		rangehdr     string
+		status       int
+		contentRange string
+		first, last  int
+	}{
+		{"bytes=0-99,50-149", http.StatusPartialContent, fmt.Sprintf("bytes 0-149/%d", size), 0, 150},
+		{"bytes=20-29,10-19", http.StatusPartialContent, fmt.Sprintf("bytes 10-29/%d", size), 10, 30},
+		{"bytes=100-199," + strings.Repeat("150-159,", 1000) + "0-0", http.StatusPartialContent, "", 0, 0},
+		{many, http.StatusOK, "", 0, size},
+	}
+	for _, test := range tests {
+		resp, body := do(http.MethodGet, nil, test.rangehdr)
+		if resp.StatusCode != test.status {
+			t.Errorf("GET %.40s...: status %d, expected %d", test.rangehdr, resp.StatusCode, test.status)
+			continue
+		}
+		if test.contentRange == "" && test.status == http.StatusPartialContent {
+			// two disjoint ranges out of a thousand overlapping ones
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "multipart/byteranges") {
+				t.Errorf("GET %.40s...: Content-Type %q, expected multipart/byteranges", test.rangehdr, ct)
+			}
+			if len(body) > 1024 {
+				t.Errorf("GET %.40s...: %d bytes, overlapping ranges not coalesced", test.rangehdr, len(body))
+			}
+			continue
+		}
+		if cr := resp.Header.Get("Content-Range"); cr != test.contentRange {
+			t.Errorf("GET %.40s...: Content-Range %q, expected %q", test.rangehdr, cr, test.contentRange)
+		}
+		if !bytes.Equal(body, data[test.first:test.last]) {
+			t.Errorf("GET %.40s...: %d bytes, expected bytes [%d, %d)", test.rangehdr, len(body), test.first, test.last)
+		}
+	}
+}
+
+// badhashReader sends the reader's content with someone else's checksum
+type badhashReader struct {
+	client.Reader
+	xxhash string
+}
+
+func (r *badhashReader) XXHash() string { return r.xxhash }
+
+// Test_multipart: parts uploaded out of order, re-uploaded concurrently and with a bad checksum
+// (that must not affect the previously uploaded good part) are assembled into the object
+func Test_multipart(t *testing.T) {
+	const (
+		objname = "multipart/obj"
+		nparts  = 3
+	)
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	var (
+		parts    = make([]client.Reader, nparts)
+		expected []byte
+	)
+	for i := range parts {
+		r, err := readers.NewInMemReader(int64(fileSize*(i+1)), true /* withHash */)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _ := r.Open()
+		b, _ := ioutil.ReadAll(h)
+		h.Close()
+		parts[i], expected = r, append(expected, b...)
+	}
+	id, err := client.InitiateMultipart(proxyurl, TestLocalBucketName, objname)
+	if err != nil {
+		t.Fatalf("Failed to initiate multipart upload of %s/%s, err: %v", TestLocalBucketName, objname, err)
+	}
+	for _, num := range []int{3, 1, 2} {
+		if err = client.PutPart(proxyurl, parts[num-1], TestLocalBucketName, objname, id, num); err != nil {
+			t.Fatalf("Failed to PUT part %d, err: %v", num, err)
+		}
+	}
+	// a failed re-upload keeps the part uploaded before
+	bad := &badhashReader{Reader: parts[0], xxhash: parts[1].XXHash()}
+	if err = client.PutPart(proxyurl, bad, TestLocalBucketName, objname, id, 1); err == nil {
+		t.Errorf("PUT part 1 with a bad checksum expected to fail")
+	}
+	// concurrent re-uploads of the same part
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.PutPart(proxyurl, parts[1], TestLocalBucketName, objname, id, 2); err != nil {
+				t.Errorf("Failed to re-PUT part 2, err: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err = client.CompleteMultipart(proxyurl, TestLocalBucketName, objname, id); err != nil {
+		t.Fatalf("Failed to complete multipart upload of %s/%s, err: %v", TestLocalBucketName, objname, err)
+	}
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + TestLocalBucketName + "/" + objname
+	resp, err := httpclient.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s/%s failed: %v", TestLocalBucketName, objname, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s/%s: status %d, err: %v", TestLocalBucketName, objname, resp.StatusCode, err)
+	}
+	if !bytes.Equal(body, expected) {
+		t.Errorf("GET %s/%s: the assembled object (%d bytes) differs from the parts (%d bytes)",
+			TestLocalBucketName, objname, len(body), len(expected))
+	}
+}
+
+func Test_objmeta(t *testing.T) {
+	const objname = "objmeta/obj"
+	meta := map[string]string{"Content-Type": "image/jpeg", "Label": "cat"}
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	do := func(method, bucket string, body []byte, hdr map[string]string) *http.Response {
+		url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + objname
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range hdr {
+			req.Header.Set(dfc.HeaderDfcMetaPrefix+k, v)
+		}
+		resp, err := httpclient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s/%s failed: %v", method, bucket, objname, err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s/%s: status %d", method, bucket, objname, resp.StatusCode)
+		}
+		return resp
+	}
+	config := getConfig(proxyurl+"/"+dfc.Rversion+"/"+dfc.Rdaemon, httpclient, t)
+	tee, _ := config["experimental"].(map[string]interface{})["tee_cold_get"].(bool)
+	check := func(method, bucket string, cold bool) {
+		resp := do(method, bucket, nil, nil)
+		// cold GET streamed while downloading (experimental.tee_cold_get) returns
+		// the metadata as trailers; all other responses - as headers
+		for k, v := range meta {
+			got := resp.Header.Get(dfc.HeaderDfcMetaPrefix + k)
+			if cold && tee {
+				got = resp.Trailer.Get(dfc.HeaderDfcMetaPrefix + k)
+			}
+			if got != v {
+				t.Errorf("%s %s/%s: metadata %q = %q, expected %q", method, bucket, objname, k, got, v)
+			}
+		}
+	}
+	data := make([]byte, fileSize)
+	do(http.MethodPut, TestLocalBucketName, data, meta)
+	check(http.MethodGet, TestLocalBucketName, false)
+	check(http.MethodHead, TestLocalBucketName, false)
+
+	// Cloud bucket: write-through and cold GET
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider == dfc.ProviderDfc {
+		return
+	}
+	do(http.MethodPut, clibucket, data, meta)
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	check(http.MethodGet, clibucket, true)
+	check(http.MethodHead, clibucket, false)
+}
+
+// putobjmeta PUTs random data with the given user-defined metadata
+func putobjmeta(t *testing.T, bucket, objname string, size int, meta map[string]string) {
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + objname
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(make([]byte, size)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range meta {
+		req.Header.Set(dfc.HeaderDfcMetaPrefix+k, v)
+	}
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT %s/%s failed: %v", bucket, objname, err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT %s/%s: status %d", bucket, objname, resp.StatusCode)
+	}
+}
+
+// checkobjmeta HEADs the object and compares its user-defined metadata
+func checkobjmeta(t *testing.T, bucket, objname string, meta map[string]string) {
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + objname
+	resp, err := httpclient.Head(url)
+	if err != nil {
+		t.Errorf("HEAD %s/%s failed: %v", bucket, objname, err)
+		return
+	}
+	resp.Body.Close()
+	for k, v := range meta {
+		if got := resp.Header.Get(dfc.HeaderDfcMetaPrefix + k); got != v {
+			t.Errorf("HEAD %s/%s: metadata %q = %q, expected %q", bucket, objname, k, got, v)
+		}
+	}
+}
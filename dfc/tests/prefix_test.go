@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -222,3 +223,151 @@ func prefixCleanup(t *testing.T) {
 	default:
 	}
 }
+
+func Test_delimiter(t *testing.T) {
+	const dir = "delim"
+	names := []string{"a/1", "a/2", "b/c/3", "x", "y"}
+	tests := []struct {
+		prefix   string
+		pagesize int
+		entries  []string
+		prefixes []string
+	}{
+		{dir + "/", 0, []string{"x", "y"}, []string{"a/", "b/"}},
+		{dir + "/", 1, []string{"x", "y"}, []string{"a/", "b/"}},
+		{dir + "/a", 0, nil, []string{"a/"}},
+		{dir + "/b/", 0, nil, []string{"b/c/"}},
+		{dir + "/b/c/", 0, []string{"b/c/3"}, nil},
+	}
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	buckets := []string{TestLocalBucketName}
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderDfc {
+		buckets = append(buckets, clibucket)
+	}
+	for _, bucket := range buckets {
+		for _, name := range names {
+			r, err := readers.NewRandReader(fileSize, true /* withHash */)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = client.Put(proxyurl, r, bucket, dir+"/"+name, true); err != nil {
+				t.Fatalf("Failed to PUT %s/%s/%s: %v", bucket, dir, name, err)
+			}
+			if bucket == clibucket {
+				defer client.Del(proxyurl, bucket, dir+"/"+name, nil, nil, true)
+			}
+		}
+		for _, test := range tests {
+			msg := &dfc.GetMsg{GetPrefix: test.prefix, GetDelimiter: "/", GetPageSize: test.pagesize}
+			list, err := client.ListBucket(proxyurl, bucket, msg, 0)
+			if err != nil {
+				t.Fatalf("Failed to list bucket %s, prefix %s: %v", bucket, test.prefix, err)
+			}
+			entries := make([]string, 0)
+			for _, entry := range list.Entries {
+				entries = append(entries, strings.TrimPrefix(entry.Name, dir+"/"))
+			}
+			prefixes := make([]string, 0)
+			for _, cp := range list.CommonPrefixes {
+				prefixes = append(prefixes, strings.TrimPrefix(cp, dir+"/"))
+			}
+			if fmt.Sprint(entries) != fmt.Sprint(test.entries) || fmt.Sprint(prefixes) != fmt.Sprint(test.prefixes) {
+				t.Errorf("Bucket %s, prefix %s, page size %d: got %v and prefixes %v, expected %v and %v",
+					bucket, test.prefix, test.pagesize, entries, prefixes, test.entries, test.prefixes)
+			}
+		}
+	}
+}
+
+func Test_listfilter(t *testing.T) {
+	const (
+		dir  = "listfilter"
+		num  = 20
+		jpgs = 2 // obj-20.jpg and obj-21.jpg
+	)
+	tests := []struct {
+		msg     dfc.GetMsg
+		entries []int // object numbers
+		fails   bool
+	}{
+		{dfc.GetMsg{GetRegex: `\d+$`, GetRange: "5:9"}, []int{5, 6, 7, 8, 9}, false},
+		{dfc.GetMsg{GetRegex: `1\d$`}, []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, false},
+		{dfc.GetMsg{GetRegex: `\.jpg$`}, []int{20, 21}, false},
+		{dfc.GetMsg{GetMinSize: 10 * 1024, GetMaxSize: 12 * 1024}, []int{9, 10, 11}, false},
+		{dfc.GetMsg{GetRegex: `\d+$`, GetRange: ":3", GetMinSize: 3 * 1024}, []int{2, 3}, false},
+		{dfc.GetMsg{GetCtimeBefore: "2000-01-01T00:00:00Z"}, []int{}, false},
+		{dfc.GetMsg{GetCtimeAfter: "2000-01-01T00:00:00Z", GetMaxSize: 2 * 1024}, []int{0, 1}, false},
+		{dfc.GetMsg{GetRange: "5:9"}, nil, true},
+		{dfc.GetMsg{GetRegex: `\d+$`, GetRange: "5"}, nil, true},
+		{dfc.GetMsg{GetMinSize: 2, GetMaxSize: 1}, nil, true},
+		{dfc.GetMsg{GetCtimeAfter: "yesterday"}, nil, true},
+	}
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	buckets := []string{TestLocalBucketName}
+	props, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to HEAD bucket %s: %v", clibucket, err)
+	}
+	if props.CloudProvider != dfc.ProviderDfc {
+		buckets = append(buckets, clibucket)
+	}
+	objname := func(i int) string {
+		if i >= num {
+			return fmt.Sprintf("%s/obj-%d.jpg", dir, i)
+		}
+		return fmt.Sprintf("%s/obj-%d", dir, i)
+	}
+	for _, bucket := range buckets {
+		for i := 0; i < num+jpgs; i++ {
+			name := objname(i)
+			r, err := readers.NewRandReader(int64(i+1)*1024, true /* withHash */)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = client.Put(proxyurl, r, bucket, name, true); err != nil {
+				t.Fatalf("Failed to PUT %s/%s: %v", bucket, name, err)
+			}
+			if bucket == clibucket {
+				defer client.Del(proxyurl, bucket, name, nil, nil, true)
+			}
+		}
+		for _, test := range tests {
+			msg := test.msg
+			msg.GetPrefix, msg.GetPageSize = dir+"/obj-", 3
+			list, err := client.ListBucket(proxyurl, bucket, &msg, 0)
+			if test.fails {
+				if err == nil {
+					t.Errorf("Bucket %s: listing with %+v was expected to fail", bucket, test.msg)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Failed to list bucket %s with %+v: %v", bucket, test.msg, err)
+			}
+			entries := make([]string, 0, len(list.Entries))
+			for _, entry := range list.Entries {
+				entries = append(entries, entry.Name)
+				if entry.Ctime != "" {
+					t.Errorf("Bucket %s: %s listed with ctime that was not requested", bucket, entry.Name)
+				}
+			}
+			expected := make([]string, 0, len(test.entries))
+			for _, i := range test.entries {
+				expected = append(expected, objname(i))
+			}
+			sort.Strings(entries)
+			sort.Strings(expected)
+			if fmt.Sprint(entries) != fmt.Sprint(expected) {
+				t.Errorf("Bucket %s, %+v: got %v, expected %v", bucket, test.msg, entries, expected)
+			}
+		}
+	}
+}
@@ -38,6 +38,8 @@ const (
 	RenameStr             = "rename"
 	ListRangeDir          = "/tmp/dfc/listrange"
 	ListRangeStr          = "__listrange"
+	lbmapSyncTimeout      = 10 * time.Second
+	lbmapSyncPoll         = 100 * time.Millisecond
 )
 
 var (
@@ -122,7 +124,6 @@ func regressionCloudBuckets(t *testing.T) {
 func regressionLocalBuckets(t *testing.T) {
 	bucket := TestLocalBucketName
 	createLocalBucket(httpclient, t, bucket)
-	time.Sleep(time.Second * 2) // FIXME: must be deterministic
 	regressionBucket(httpclient, t, bucket)
 	destroyLocalBucket(httpclient, t, bucket)
 }
@@ -956,11 +957,11 @@ func regressionHeadBucket(t *testing.T) {
 	}
 
 	if bprops == nil {
-		t.Errorf("Failed to get bucket %s head but no errors", bprops)
+		t.Errorf("Failed to get bucket %s head but no errors", clibucket)
 		return
 	}
 
-	providerList := []string{dfc.ProviderAmazon, dfc.ProviderGoogle, dfc.ProviderDfc}
+	providerList := append(dfc.CloudProviders(), dfc.ProviderDfc)
 	if !stringInSlice(bprops.CloudProvider, providerList) {
 		t.Errorf("Invalid bucket %s Cloud Provider: %s [must be one of %s]",
 			clibucket, bprops.CloudProvider, strings.Join(providerList, ", "))
@@ -1073,6 +1074,7 @@ func createLocalBucket(httpclient *http.Client, t *testing.T, bucket string) {
 	if testfail(err, "Create Local Bucket", r, nil, t) {
 		return
 	}
+	waitForLocalBucket(t, bucket, true)
 }
 
 func destroyLocalBucket(httpclient *http.Client, t *testing.T, bucket string) {
@@ -1099,6 +1101,31 @@ func destroyLocalBucket(httpclient *http.Client, t *testing.T, bucket string) {
 	if testfail(err, "Delete Local Bucket", r, nil, t) {
 		return
 	}
+	waitForLocalBucket(t, bucket, false)
+}
+
+// waitForLocalBucket polls each target until its lbmap has (or, if !exists, no longer has)
+// the local bucket: the proxy synchronizes lbmap with the targets asynchronously
+func waitForLocalBucket(t *testing.T, bucket string, exists bool) {
+	smap := getClusterMap(httpclient, t)
+	deadline := time.Now().Add(lbmapSyncTimeout)
+	for id, sinfo := range smap.Smap {
+		// HEAD ?local=true fails if the target does not consider the bucket local
+		url := sinfo.DirectURL + "/" + dfc.Rversion + "/" + dfc.Rbuckets + "/" + bucket + "?" + dfc.URLParamLocal + "=true"
+		for {
+			r, err := httpclient.Head(url)
+			if err == nil {
+				r.Body.Close()
+				if (r.StatusCode == http.StatusOK) == exists {
+					break
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Target %s: local bucket %s exists = %t after %v", id, bucket, !exists, lbmapSyncTimeout)
+			}
+			time.Sleep(lbmapSyncPoll)
+		}
+	}
 }
 
 func getClusterStats(httpclient *http.Client, t *testing.T) (stats dfc.ClusterStats) {
@@ -0,0 +1,417 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The test requires at least 3 targets: it creates 2-way replicated local bucket,
+// shuts down one of the targets and reads back its objects restored from replicas
+// 	go test -v -run=replica
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	replicaBucket   = "replicabucket"
+	replicaCopies   = 2
+	replicaNumFiles = 20
+	replicaFileSize = 64 * 1024
+)
+
+func Test_replica(t *testing.T) {
+	smap := getClusterMap(httpclient, t)
+	if len(smap.Smap) < replicaCopies+1 {
+		t.Skipf("%d-way replication test requires at least %d targets, have %d",
+			replicaCopies, replicaCopies+1, len(smap.Smap))
+	}
+	props := dfc.BucketProps{Copies: replicaCopies}
+	if err := client.CreateLocalBucketWithProps(proxyurl, replicaBucket, props); err != nil {
+		t.Fatalf("Failed to create replicated local bucket %s: %v", replicaBucket, err)
+	}
+	waitForLocalBucket(t, replicaBucket, true)
+	defer destroyLocalBucket(httpclient, t, replicaBucket)
+
+	names := make([]string, 0, replicaNumFiles)
+	for i := 0; i < replicaNumFiles; i++ {
+		name := fmt.Sprintf("replica/obj%03d", i)
+		reader, err := readers.NewRandReader(replicaFileSize, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, replicaBucket, name, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", replicaBucket, name, err)
+		}
+		names = append(names, name)
+	}
+	meta := map[string]string{"Label": "cat"}
+	putobjmeta(t, replicaBucket, names[0], replicaFileSize, meta)
+
+	// shut down the target that owns the first object
+	var targetID, targetURL, targetPort string
+	var max uint64
+	for id, sinfo := range smap.Smap {
+		cs := xxhash.ChecksumString64S(id+":"+replicaBucket+"/"+names[0], HRWmLCG32)
+		if cs > max {
+			max, targetID, targetURL, targetPort = cs, id, sinfo.DirectURL, sinfo.DaemonPort
+		}
+	}
+	tcmd, targs, err := kill(httpclient, targetURL, targetPort)
+	if err != nil {
+		t.Fatalf("Failed to shut down target %s: %v", targetID, err)
+	}
+	time.Sleep(5 * time.Second) // FIXME: Deterministic wait for smap propogation
+	if smap = getClusterMap(httpclient, t); smap.Smap[targetID] != nil {
+		t.Errorf("Target %s was not removed from the cluster map", targetID)
+	}
+
+	// the objects that were stored on the target are now restored from their replicas
+	for _, name := range names {
+		if _, _, err = client.Get(proxyurl, replicaBucket, name, nil, nil, true, true); err != nil {
+			t.Errorf("GET %s/%s failed: %v", replicaBucket, name, err)
+		}
+	}
+	checkobjmeta(t, replicaBucket, names[0], meta)
+	for _, name := range names {
+		if err = client.Del(proxyurl, replicaBucket, name, nil, nil, true); err != nil {
+			t.Errorf("Failed to DELETE %s/%s: %v", replicaBucket, name, err)
+		}
+	}
+
+	for i, arg := range targs {
+		if strings.Contains(arg, "-proxyurl") {
+			targs = append(targs[:i], targs[i+1:]...)
+			break
+		}
+	}
+	if err = restore(httpclient, targetURL, tcmd, targs); err != nil {
+		t.Errorf("Failed to restart target %s: %v", targetID, err)
+	}
+	time.Sleep(5 * time.Second)
+	if smap = getClusterMap(httpclient, t); smap.Smap[targetID] == nil {
+		t.Errorf("Restarted target %s did not rejoin the cluster", targetID)
+	}
+}
+
+func Test_locate(t *testing.T) {
+	const objname = "locate/obj"
+	smap := getClusterMap(httpclient, t)
+	props := dfc.BucketProps{}
+	if len(smap.Smap) > 1 {
+		props.Copies = 2
+	}
+	if err := client.CreateLocalBucketWithProps(proxyurl, TestLocalBucketName, props); err != nil {
+		t.Fatalf("Failed to create local bucket %s: %v", TestLocalBucketName, err)
+	}
+	waitForLocalBucket(t, TestLocalBucketName, true)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	r, err := readers.NewRandReader(fileSize, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, r, TestLocalBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	var (
+		owner string
+		max   uint64
+	)
+	for id := range smap.Smap {
+		if cs := xxhash.ChecksumString64S(id+":"+TestLocalBucketName+"/"+objname, HRWmLCG32); cs > max {
+			max, owner = cs, id
+		}
+	}
+	loc, err := client.LocateObject(proxyurl, TestLocalBucketName, objname)
+	if err != nil {
+		t.Fatalf("Failed to locate %s/%s: %v", TestLocalBucketName, objname, err)
+	}
+	if loc.DaemonID != owner || !loc.Present || loc.Size != fileSize {
+		t.Errorf("Located %s/%s at %s (present: %t, size %d), expected %s (present, size %d)",
+			TestLocalBucketName, objname, loc.DaemonID, loc.Present, loc.Size, owner, fileSize)
+	}
+	if !strings.HasPrefix(loc.FQN, loc.Mountpath) || !strings.HasSuffix(loc.FQN, "/"+TestLocalBucketName+"/"+objname) {
+		t.Errorf("Unexpected FQN %q (mountpath %q)", loc.FQN, loc.Mountpath)
+	}
+	// with 2-way replication, the only copy is the replica on the next target
+	if props.Copies > 1 {
+		if len(loc.Copies) != 1 || !loc.Copies[0].Replica || loc.Copies[0].DaemonID == owner || loc.Copies[0].Size != fileSize {
+			t.Errorf("Expected one replica of %s/%s on another target, got %+v", TestLocalBucketName, objname, loc.Copies)
+		}
+	}
+
+	loc, err = client.LocateObject(proxyurl, TestLocalBucketName, objname+"-nonexistent")
+	if err != nil {
+		t.Fatalf("Failed to locate %s/%s: %v", TestLocalBucketName, objname+"-nonexistent", err)
+	}
+	if loc.DaemonID == "" || loc.Present || len(loc.Copies) != 0 {
+		t.Errorf("Nonexistent object located: %+v", loc)
+	}
+
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + TestLocalBucketName + "/" + objname + "?" + dfc.URLParamWhat + "=where"
+	resp, err := httpclient.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET %s: status %d, expected %d", url, resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// locateFailMockTarget fails all locate requests
+type locateFailMockTarget struct{}
+
+func (*locateFailMockTarget) filehdlr(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get(dfc.URLParamWhat) == dfc.GetWhatLocate {
+		http.Error(w, "mock target: locate failure", http.StatusInternalServerError)
+	}
+}
+
+func (*locateFailMockTarget) daemonhdlr(w http.ResponseWriter, r *http.Request) {}
+
+func (*locateFailMockTarget) votehdlr(w http.ResponseWriter, r *http.Request) {}
+
+// Test_locatefailure: a target that fails to look for the object does not fail the locate
+func Test_locatefailure(t *testing.T) {
+	createLocalBucket(httpclient, t, TestLocalBucketName)
+	defer destroyLocalBucket(httpclient, t, TestLocalBucketName)
+
+	smap := getClusterMap(httpclient, t)
+	stopch := make(chan struct{})
+	go runMockTarget(&locateFailMockTarget{}, stopch, &smap)
+	mockjoined := func(joined bool) {
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+			if smap = getClusterMap(httpclient, t); (smap.Smap["MOCK"] != nil) == joined {
+				return
+			}
+		}
+		t.Fatalf("Mock target did not %s the cluster", map[bool]string{true: "join", false: "leave"}[joined])
+	}
+	mockjoined(true)
+	defer mockjoined(false)
+	defer close(stopch)
+
+	// one object owned by a target, another - by the mock
+	owners := make(map[bool]string, 2)
+	objnames := make(map[bool]string, 2)
+	for i := 0; len(objnames) < 2; i++ {
+		var (
+			objname = fmt.Sprintf("locate/obj%d", i)
+			owner   string
+			max     uint64
+		)
+		for id := range smap.Smap {
+			if cs := xxhash.ChecksumString64S(id+":"+TestLocalBucketName+"/"+objname, HRWmLCG32); cs > max {
+				max, owner = cs, id
+			}
+		}
+		if _, ok := objnames[owner == "MOCK"]; !ok {
+			objnames[owner == "MOCK"], owners[owner == "MOCK"] = objname, owner
+		}
+	}
+	r, err := readers.NewRandReader(fileSize, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, r, TestLocalBucketName, objnames[false], true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objnames[false], err)
+	}
+
+	for _, mock := range []bool{false, true} {
+		objname := objnames[mock]
+		loc, err := client.LocateObject(proxyurl, TestLocalBucketName, objname)
+		if err != nil {
+			t.Fatalf("Failed to locate %s/%s: %v", TestLocalBucketName, objname, err)
+		}
+		if loc.DaemonID != owners[mock] || loc.Present == mock {
+			t.Errorf("Located %s/%s at %s (present: %t), expected %s", TestLocalBucketName, objname,
+				loc.DaemonID, loc.Present, owners[mock])
+		}
+		if len(loc.Errors) != 1 || loc.Errors["MOCK"] == "" {
+			t.Errorf("Locate %s/%s: expected the mock target's error, got %v", TestLocalBucketName, objname, loc.Errors)
+		}
+	}
+}
+
+func Test_replicacorrupt(t *testing.T) {
+	const objname = "replica/corrupt"
+	smap := getClusterMap(httpclient, t)
+	if len(smap.Smap) < replicaCopies {
+		t.Skipf("%d-way replication test requires at least %d targets, have %d",
+			replicaCopies, replicaCopies, len(smap.Smap))
+	}
+	// validate_warm_get: GET validates the object and restores the one that fails validation
+	props := dfc.BucketProps{
+		Copies:      replicaCopies,
+		VersionConf: &dfc.VersionConf{ValidateWarmGet: true, Versioning: dfc.VersionAll},
+	}
+	if err := client.CreateLocalBucketWithProps(proxyurl, replicaBucket, props); err != nil {
+		t.Fatalf("Failed to create replicated local bucket %s: %v", replicaBucket, err)
+	}
+	waitForLocalBucket(t, replicaBucket, true)
+	defer destroyLocalBucket(httpclient, t, replicaBucket)
+
+	reader, err := readers.NewRandReader(replicaFileSize, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Put(proxyurl, reader, replicaBucket, objname, true); err != nil {
+		t.Fatalf("Failed to PUT %s/%s: %v", replicaBucket, objname, err)
+	}
+	loc, err := client.LocateObject(proxyurl, replicaBucket, objname)
+	if err != nil {
+		t.Fatalf("Failed to locate %s/%s: %v", replicaBucket, objname, err)
+	}
+	if !loc.Present || len(loc.Copies) == 0 {
+		t.Fatalf("Expected %s/%s and its replica, got %+v", replicaBucket, objname, loc)
+	}
+
+	// corrupt the object in place - the stored checksum (xattr) remains
+	file, err := os.OpenFile(loc.FQN, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", loc.FQN, err)
+	}
+	b := make([]byte, 16)
+	if _, err = file.ReadAt(b, 0); err == nil {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+		_, err = file.WriteAt(b, 0)
+	}
+	file.Close()
+	if err != nil {
+		t.Fatalf("Failed to corrupt %s: %v", loc.FQN, err)
+	}
+
+	// the object is served (and restored) from the replica
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + replicaBucket + "/" + objname
+	resp, err := httpclient.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s/%s failed: %v", replicaBucket, objname, err)
+	}
+	n, hash, err := client.ReadWriteWithHash(resp.Body, ioutil.Discard)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s/%s: status %d, err: %v", replicaBucket, objname, resp.StatusCode, err)
+	}
+	if n != replicaFileSize || hash != reader.XXHash() {
+		t.Errorf("GET %s/%s: %d bytes, hash %s, expected %d bytes, %s",
+			replicaBucket, objname, n, hash, replicaFileSize, reader.XXHash())
+	}
+}
+
+func Test_renamelbrestore(t *testing.T) {
+	const numfiles = 10
+	smap := getClusterMap(httpclient, t)
+	tests := []struct {
+		name     string
+		props    dfc.BucketProps
+		ntargets int
+	}{
+		{"replicated", dfc.BucketProps{Copies: replicaCopies}, replicaCopies},
+		{"erasure coded", dfc.BucketProps{EC: dfc.ECConf{DataSlices: 2, ParitySlices: 1}}, 4},
+	}
+	for _, test := range tests {
+		if len(smap.Smap) < test.ntargets {
+			t.Logf("Skipping %s local bucket: requires at least %d targets, have %d",
+				test.name, test.ntargets, len(smap.Smap))
+			continue
+		}
+		renamelbrestore(t, test.name, test.props, numfiles)
+	}
+}
+
+// renamelbrestore renames the local bucket with the given props and then restores
+// its objects (from the replicas or slices) that are removed once migrated
+func renamelbrestore(t *testing.T, name string, props dfc.BucketProps, numfiles int) {
+	if err := client.CreateLocalBucketWithProps(proxyurl, TestLocalBucketName, props); err != nil {
+		t.Fatalf("Failed to create %s local bucket %s: %v", name, TestLocalBucketName, err)
+	}
+	waitForLocalBucket(t, TestLocalBucketName, true)
+
+	cksums := make(map[string]string, numfiles)
+	for i := 0; i < numfiles; i++ {
+		objname := fmt.Sprintf("renamelb/obj%d", i)
+		reader, err := readers.NewRandReader(replicaFileSize, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Put(proxyurl, reader, TestLocalBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to PUT %s/%s: %v", TestLocalBucketName, objname, err)
+		}
+		cksums[objname] = reader.XXHash()
+	}
+	if err := client.RenameLocalBucket(proxyurl, TestLocalBucketName, copyBucket); err != nil {
+		destroyLocalBucket(httpclient, t, TestLocalBucketName)
+		t.Fatalf("Failed to rename %s local bucket %s => %s: %v", name, TestLocalBucketName, copyBucket, err)
+	}
+	defer destroyLocalBucket(httpclient, t, copyBucket)
+
+	// wait for the objects to migrate: in place and no copies left behind other than replicas
+	fqns := make(map[string]string, numfiles)
+	for deadline := time.Now().Add(10 * time.Second); len(fqns) < numfiles && time.Now().Before(deadline); {
+		for objname := range cksums {
+			loc, err := client.LocateObject(proxyurl, copyBucket, objname)
+			if err != nil || !loc.Present {
+				continue
+			}
+			migrated := true
+			for _, cp := range loc.Copies {
+				migrated = migrated && cp.Replica
+			}
+			if migrated {
+				fqns[objname] = loc.FQN
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(fqns) < numfiles {
+		t.Fatalf("%s local bucket %s: %d of %d objects migrated", name, copyBucket, len(fqns), numfiles)
+	}
+
+	for _, fqn := range fqns {
+		if err := os.Remove(fqn); err != nil {
+			t.Fatalf("Failed to remove %s: %v", fqn, err)
+		}
+	}
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + copyBucket + "/"
+	for objname, cksum := range cksums {
+		// the slices (replicas) may still be on their way
+		var (
+			status int
+			hash   string
+			err    error
+		)
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+			var resp *http.Response
+			if resp, err = httpclient.Get(url + objname); err != nil {
+				break
+			}
+			_, hash, err = client.ReadWriteWithHash(resp.Body, ioutil.Discard)
+			resp.Body.Close()
+			if status = resp.StatusCode; status == http.StatusOK {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil || status != http.StatusOK {
+			t.Errorf("%s local bucket: GET %s/%s: status %d, err: %v", name, copyBucket, objname, status, err)
+		} else if hash != cksum {
+			t.Errorf("%s local bucket: GET %s/%s: hash %s, expected %s", name, copyBucket, objname, hash, cksum)
+		}
+	}
+}
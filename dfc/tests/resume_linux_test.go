@@ -0,0 +1,59 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// The test requires a cluster deployed with "cloudprovider": "mock": it interrupts the
+// cold GET, restarts the target and checks that the download gets resumed
+// 	BUCKET=mockbucket go test -v -run=resumecoldgetrestart
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func Test_resumecoldgetrestart(t *testing.T) {
+	resumecoldget(t, func(objname string) {
+		var (
+			smap                            = getClusterMap(httpclient, t)
+			targetID, targetURL, targetPort string
+			max                             uint64
+		)
+		for id, sinfo := range smap.Smap {
+			if cs := xxhash.ChecksumString64S(id+":"+clibucket+"/"+objname, HRWmLCG32); cs > max {
+				max, targetID, targetURL, targetPort = cs, id, sinfo.DirectURL, sinfo.DaemonPort
+			}
+		}
+		tcmd, targs, err := kill(httpclient, targetURL, targetPort)
+		if err != nil {
+			t.Fatalf("Failed to shut down target %s: %v", targetID, err)
+		}
+		waitForTarget(t, targetID, false)
+		for i, arg := range targs {
+			if strings.Contains(arg, "-proxyurl") {
+				targs = append(targs[:i], targs[i+1:]...)
+				break
+			}
+		}
+		if err = restore(httpclient, targetURL, tcmd, targs); err != nil {
+			t.Fatalf("Failed to restart target %s: %v", targetID, err)
+		}
+		waitForTarget(t, targetID, true)
+	})
+}
+
+// waitForTarget waits for the target to join (leave) the cluster
+func waitForTarget(t *testing.T, id string, joined bool) {
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); time.Sleep(time.Second) {
+		if smap := getClusterMap(httpclient, t); (smap.Smap[id] != nil) == joined {
+			return
+		}
+	}
+	t.Fatalf("Target %s did not %s the cluster", id, map[bool]string{true: "join", false: "leave"}[joined])
+}
@@ -0,0 +1,110 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+)
+
+// s3ListResult is the part of the S3 ListObjectsV2 response the test checks
+type s3ListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+func s3do(t *testing.T, method, path string, body []byte) (int, []byte) {
+	req, err := http.NewRequest(method, proxyurl+"/"+dfc.Rs3+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("S3 %s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("S3 %s %s: failed to read response: %v", method, path, err)
+	}
+	return resp.StatusCode, b
+}
+
+func Test_s3(t *testing.T) {
+	const dir = "s3"
+	bucket := TestLocalBucketName
+	names := []string{"a/1", "a/2", "b"}
+	createLocalBucket(httpclient, t, bucket)
+	defer destroyLocalBucket(httpclient, t, bucket)
+
+	for i, name := range names {
+		data := bytes.Repeat([]byte{byte('0' + i)}, (i+1)*1024)
+		path := bucket + "/" + dir + "/" + name
+		if status, _ := s3do(t, http.MethodPut, path, data); status != http.StatusOK {
+			t.Fatalf("S3 PUT %s: status %d", path, status)
+		}
+		status, b := s3do(t, http.MethodGet, path, nil)
+		if status != http.StatusOK || !bytes.Equal(b, data) {
+			t.Errorf("S3 GET %s: status %d, %d bytes, expected %d bytes", path, status, len(b), len(data))
+		}
+		if status, _ = s3do(t, http.MethodHead, path, nil); status != http.StatusOK {
+			t.Errorf("S3 HEAD %s: status %d", path, status)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		keys     []string
+		prefixes []string
+	}{
+		{"list-type=2&prefix=" + dir + "/", []string{dir + "/a/1", dir + "/a/2", dir + "/b"}, nil},
+		{"list-type=2&prefix=" + dir + "/&delimiter=/", []string{dir + "/b"}, []string{dir + "/a/"}},
+		{"list-type=2&prefix=" + dir + "/a/&delimiter=/", []string{dir + "/a/1", dir + "/a/2"}, nil},
+	}
+	for _, test := range tests {
+		status, b := s3do(t, http.MethodGet, bucket+"?"+test.query, nil)
+		if status != http.StatusOK {
+			t.Errorf("S3 list %s?%s: status %d: %s", bucket, test.query, status, string(b))
+			continue
+		}
+		result := &s3ListResult{}
+		if err := xml.Unmarshal(b, result); err != nil {
+			t.Fatalf("S3 list %s?%s: failed to unmarshal: %v", bucket, test.query, err)
+		}
+		keys, prefixes := make([]string, 0), make([]string, 0)
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		for _, cp := range result.CommonPrefixes {
+			prefixes = append(prefixes, cp.Prefix)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(test.keys) || fmt.Sprint(prefixes) != fmt.Sprint(test.prefixes) {
+			t.Errorf("S3 list %s?%s: got %v and prefixes %v, expected %v and %v",
+				bucket, test.query, keys, prefixes, test.keys, test.prefixes)
+		}
+	}
+
+	for _, name := range names {
+		path := bucket + "/" + dir + "/" + name
+		if status, _ := s3do(t, http.MethodDelete, path, nil); status != http.StatusOK && status != http.StatusNoContent {
+			t.Errorf("S3 DELETE %s: status %d", path, status)
+		}
+		if status, _ := s3do(t, http.MethodGet, path, nil); status == http.StatusOK {
+			t.Errorf("S3 GET deleted %s did not fail", path)
+		}
+	}
+}
@@ -125,6 +125,7 @@ TESTFSPATHCOUNT=$testfspathcnt
 echo Select Cloud Provider:
 echo  1: Amazon Cloud
 echo  2: Google Cloud
+echo  3: Azure Cloud
 echo Enter your choice:
 read cldprovider
 if [ $cldprovider -eq 1 ]
@@ -145,8 +146,11 @@ then
 elif [ $cldprovider -eq 2 ]
 then
   CLDPROVIDER="gcp"
+elif [ $cldprovider -eq 3 ]
+then
+  CLDPROVIDER="azure"
 else
-  echo "Error: '$cldprovider' is not a valid input, can be either 1 or 2"; exit 1
+  echo "Error: '$cldprovider' is not a valid input, can be either 1, 2 or 3"; exit 1
 fi
 
 CONFFILE="dfc.json"
@@ -19,6 +19,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -141,9 +142,28 @@ type ReqError struct {
 	message string
 }
 
+// BucketProps are the bucket properties returned by HeadBucket - see also GetBucketProps
 type BucketProps struct {
-	CloudProvider string
-	Versioning    string
+	CloudProvider   string
+	Versioning      string
+	Checksum        string
+	ValidateColdGet bool
+	ValidateWarmGet bool
+	LRUEnabled      bool
+	LRULowWM        uint32
+	LRUHighWM       uint32
+	NextTierURL     string
+	Pinned          bool
+}
+
+// ObjectProps are the object properties returned by HeadObject
+type ObjectProps struct {
+	Size          int64
+	Version       string
+	ChecksumType  string
+	ChecksumValue string
+	Atime         time.Time // zero if not cached
+	IsCached      bool
 }
 
 // Reader is the interface a client works with to read in data and send to a HTTP server
@@ -270,14 +290,23 @@ func get(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan
 
 	tr.tsHTTPEnd = time.Now()
 
-	if validate {
+	// the checksum is returned either in the headers or, when the object is streamed
+	// while being downloaded from the Cloud (tee cold GET), in the declared trailers
+	var intrailer bool
+	if validate && err == nil {
 		hdhash = resp.Header.Get(dfc.HeaderDfcChecksumVal)
 		hdhashtype = resp.Header.Get(dfc.HeaderDfcChecksumType)
+		_, intrailer = resp.Trailer[http.CanonicalHeaderKey(dfc.HeaderDfcChecksumVal)]
 	}
 
-	v := hdhashtype == dfc.ChecksumXXHash
+	v := hdhashtype == dfc.ChecksumXXHash || intrailer
 	len, hash, err := readResponse(resp, w, err, fmt.Sprintf("GET (object %s from bucket %s)", keyname, bucket), v)
-	if err != nil && v {
+	if err == nil && intrailer {
+		hdhash = resp.Trailer.Get(dfc.HeaderDfcChecksumVal)
+		hdhashtype = resp.Trailer.Get(dfc.HeaderDfcChecksumType)
+		v = hdhashtype == dfc.ChecksumXXHash
+	}
+	if err == nil && v {
 		if hdhash != hash {
 			s := fmt.Sprintf("Header's hash %s doesn't match the file's %s \n", hdhash, hash)
 			if errch != nil {
@@ -319,6 +348,38 @@ func GetFile(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch
 	return get(proxyurl, bucket, keyname, wg, errch, silent, validate, w)
 }
 
+// GetRange sends a ranged get request to proxy and writes the returned bytes to an io.Writer.
+// Reads length bytes starting at offset; length 0 (zero) reads till the end of the object
+func GetRange(proxyurl, bucket, keyname string, offset, length int64, w io.Writer) (int64, error) {
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + keyname
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create new http request, err: %v", err)
+	}
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err = checkHTTPStatus(resp, "GET range"); err != nil {
+		discardHTTPResp(resp)
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		discardHTTPResp(resp)
+		return 0, fmt.Errorf("GET range (object %s from bucket %s): unexpected http status %d",
+			keyname, bucket, resp.StatusCode)
+	}
+	return io.Copy(w, resp.Body)
+}
+
 func Del(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan error, silent bool) (err error) {
 	if wg != nil {
 		defer wg.Done()
@@ -413,15 +474,17 @@ func ListBucket(proxyurl, bucket string, msg *dfc.GetMsg, objectCountLimit int)
 		}
 
 		reslist.Entries = append(reslist.Entries, page.Entries...)
+		reslist.CommonPrefixes = append(reslist.CommonPrefixes, page.CommonPrefixes...)
 		if page.PageMarker == "" {
 			break
 		}
 
+		// common prefixes count towards the limit same as objects
 		if objectCountLimit != 0 {
-			if len(reslist.Entries) >= objectCountLimit {
+			if len(reslist.Entries)+len(reslist.CommonPrefixes) >= objectCountLimit {
 				break
 			}
-			toRead -= len(page.Entries)
+			toRead -= len(page.Entries) + len(page.CommonPrefixes)
 		}
 
 		msg.GetPageMarker = page.PageMarker
@@ -525,6 +588,45 @@ func EvictRange(proxyurl, bucket, prefix, regex, rng string, wait bool, deadline
 	return doListRangeCall(proxyurl, bucket, dfc.ActEvict, http.MethodDelete, evictMsg, wait)
 }
 
+// CopyList copies the listed objects from bucket to dstbucket under the same names
+func CopyList(proxyurl, bucket, dstbucket string, fileslist []string, wait bool, deadline time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait, DestBucket: dstbucket}
+	copyMsg := dfc.ListMsg{Objnames: fileslist, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeCall(proxyurl, bucket, dfc.ActCopy, http.MethodPost, copyMsg, wait)
+}
+
+// CopyRange copies the objects matching prefix, regex and range from bucket to dstbucket
+func CopyRange(proxyurl, bucket, dstbucket, prefix, regex, rng string, wait bool, deadline time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait, DestBucket: dstbucket}
+	copyMsg := dfc.RangeMsg{Prefix: prefix, Regex: regex, Range: rng, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeCall(proxyurl, bucket, dfc.ActCopy, http.MethodPost, copyMsg, wait)
+}
+
+// CopyObject copies bucket/objname to dstbucket/dstobjname without passing the object
+// through the client; an empty dstobjname keeps the source name
+func CopyObject(proxyURL, bucket, objname, dstbucket, dstobjname string) error {
+	msg := &dfc.ActionMsg{Action: dfc.ActCopy, Value: dfc.CopyMsg{DestBucket: dstbucket, DestObjname: dstobjname}}
+	resp, err := doMultipartAction(proxyURL, bucket, objname, msg)
+	if err != nil {
+		return err
+	}
+	discardHTTPResp(resp)
+	resp.Body.Close()
+	return nil
+}
+
+// MoveObject moves bucket/objname to dstbucket/dstobjname - see CopyObject
+func MoveObject(proxyURL, bucket, objname, dstbucket, dstobjname string) error {
+	msg := &dfc.ActionMsg{Action: dfc.ActMove, Value: dfc.CopyMsg{DestBucket: dstbucket, DestObjname: dstobjname}}
+	resp, err := doMultipartAction(proxyURL, bucket, objname, msg)
+	if err != nil {
+		return err
+	}
+	discardHTTPResp(resp)
+	resp.Body.Close()
+	return nil
+}
+
 // fastRandomFilename is taken from https://stackoverflow.com/questions/22892120/how-to-generate-a-random-string-of-a-fixed-length-in-golang
 const (
 	letterBytes   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -569,9 +671,156 @@ func HeadBucket(proxyurl, bucket string) (bucketprops *BucketProps, err error) {
 	}
 	bucketprops.CloudProvider = r.Header.Get(dfc.CloudProvider)
 	bucketprops.Versioning = r.Header.Get(dfc.Versioning)
+	bucketprops.Checksum = r.Header.Get(dfc.HeaderBucketChecksum)
+	bucketprops.ValidateColdGet, _ = strconv.ParseBool(r.Header.Get(dfc.HeaderBucketValidateColdGet))
+	bucketprops.ValidateWarmGet, _ = strconv.ParseBool(r.Header.Get(dfc.HeaderBucketValidateWarmGet))
+	bucketprops.LRUEnabled, _ = strconv.ParseBool(r.Header.Get(dfc.HeaderBucketLRUEnabled))
+	if wm, err := strconv.ParseUint(r.Header.Get(dfc.HeaderBucketLRULowWM), 10, 32); err == nil {
+		bucketprops.LRULowWM = uint32(wm)
+	}
+	if wm, err := strconv.ParseUint(r.Header.Get(dfc.HeaderBucketLRUHighWM), 10, 32); err == nil {
+		bucketprops.LRUHighWM = uint32(wm)
+	}
+	bucketprops.NextTierURL = r.Header.Get(dfc.HeaderBucketNextTierURL)
+	bucketprops.Pinned, _ = strconv.ParseBool(r.Header.Get(dfc.HeaderBucketPinned))
 	return
 }
 
+// HeadObject returns the properties of the object: cached ones from the DFC
+// target that stores the object or, if the object is not cached, from the Cloud
+func HeadObject(proxyurl, bucket, objname string) (objprops *ObjectProps, err error) {
+	var (
+		url = proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + objname
+		r   *http.Response
+	)
+	r, err = client.Head(url)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+	if r.StatusCode >= http.StatusBadRequest {
+		err = newReqError(fmt.Sprintf("Head object %s/%s failed, HTTP status %d", bucket, objname, r.StatusCode),
+			r.StatusCode)
+		return
+	}
+	objprops = &ObjectProps{
+		Size:          r.ContentLength,
+		Version:       r.Header.Get(dfc.HeaderDfcObjVersion),
+		ChecksumType:  r.Header.Get(dfc.HeaderDfcChecksumType),
+		ChecksumValue: r.Header.Get(dfc.HeaderDfcChecksumVal),
+	}
+	objprops.IsCached, _ = strconv.ParseBool(r.Header.Get(dfc.HeaderDfcObjIsCached))
+	if atime := r.Header.Get(dfc.HeaderDfcObjAtime); atime != "" {
+		if objprops.Atime, err = time.Parse(dfc.RFC822, atime); err != nil {
+			return nil, fmt.Errorf("Head object %s/%s: invalid atime %q, err: %v", bucket, objname, atime, err)
+		}
+	}
+	return
+}
+
+// LocateObject returns the target and the mountpath that store the object as per
+// HRW, and the copies of the object found elsewhere in the cluster
+func LocateObject(proxyurl, bucket, objname string) (*dfc.ObjectLocation, error) {
+	url := proxyurl + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + objname +
+		"?" + dfc.URLParamWhat + "=" + dfc.GetWhatLocate
+	r, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, "locate object"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	loc := &dfc.ObjectLocation{}
+	err = json.Unmarshal(b, loc)
+	return loc, err
+}
+
+// GetBucketProps returns the properties of a bucket including its overrides
+// of the cluster-wide configuration (none for a Cloud bucket that has no overrides)
+func GetBucketProps(proxyURL, bucket string) (*dfc.BucketProps, error) {
+	msg, err := json.Marshal(dfc.GetMsg{GetWhat: dfc.GetWhatProps})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, proxyURL+"/"+dfc.Rversion+"/"+dfc.Rbuckets+"/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, "get bucket properties"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	props := &dfc.BucketProps{}
+	err = json.Unmarshal(b, props)
+	return props, err
+}
+
+// GetBucketSummary returns the number of objects and bytes the cluster stores in
+// the bucket - in total, per target and per mountpath
+func GetBucketSummary(proxyURL, bucket string) (*dfc.BucketSummary, error) {
+	msg, err := json.Marshal(dfc.GetMsg{GetWhat: dfc.GetWhatSummary})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, proxyURL+"/"+dfc.Rversion+"/"+dfc.Rbuckets+"/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, "get bucket summary"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	summary := &dfc.BucketSummary{}
+	err = json.Unmarshal(b, summary)
+	return summary, err
+}
+
+// SetBucketProps sets (replaces) the overrides of the cluster-wide configuration for
+// a bucket; erasure coding and replication of a local bucket cannot be changed,
+// empty props remove the overrides
+func SetBucketProps(proxyURL, bucket string, props dfc.BucketProps) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActSetProps, Value: props})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, proxyURL+"/"+dfc.Rversion+"/"+dfc.Rbuckets+"/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, dfc.ActSetProps); err != nil {
+		return err
+	}
+	// FIXME: same as CreateLocalBucket - wait for the local bucket map to propagate
+	time.Sleep(time.Second * 2)
+	return nil
+}
+
 func checkHTTPStatus(resp *http.Response, op string) error {
 	if resp.StatusCode >= http.StatusBadRequest {
 		return ReqError{
@@ -642,6 +891,106 @@ func PutAsync(wg *sync.WaitGroup, proxyURL string, reader Reader, bucket string,
 	}
 }
 
+// InitiateMultipart starts a multipart upload of bucket/key and returns the upload ID
+func InitiateMultipart(proxyURL, bucket, key string) (string, error) {
+	resp, err := doMultipartAction(proxyURL, bucket, key, &dfc.ActionMsg{Action: dfc.ActMultipartInit})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read response, err: %v", err)
+	}
+	upload := &dfc.MultipartUpload{}
+	if err = json.Unmarshal(b, upload); err != nil {
+		return "", fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return upload.UploadID, nil
+}
+
+// PutPart uploads a single part of the multipart upload; part numbers start from 1
+func PutPart(proxyURL string, reader Reader, bucket, key, uploadID string, partnum int) error {
+	url := fmt.Sprintf("%s/%s/%s/%s/%s?%s=%s&%s=%d", proxyURL, dfc.Rversion, dfc.Robjects, bucket, key,
+		dfc.URLParamUploadID, uploadID, dfc.URLParamPartNum, partnum)
+	handle, err := reader.Open()
+	if err != nil {
+		return fmt.Errorf("Failed to open reader, err: %v", err)
+	}
+	defer handle.Close()
+
+	req, err := http.NewRequest(http.MethodPut, url, handle)
+	if err != nil {
+		return fmt.Errorf("Failed to create new http request, err: %v", err)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return reader.Open()
+	}
+	if reader.XXHash() != "" {
+		req.Header.Set(dfc.HeaderDfcChecksumType, dfc.ChecksumXXHash)
+		req.Header.Set(dfc.HeaderDfcChecksumVal, reader.XXHash())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = checkHTTPStatus(resp, "PUT part")
+	discardHTTPResp(resp)
+	return err
+}
+
+// CompleteMultipart assembles the uploaded parts into the bucket/key object
+func CompleteMultipart(proxyURL, bucket, key, uploadID string) error {
+	msg := &dfc.ActionMsg{Action: dfc.ActMultipartComplete, Name: uploadID}
+	resp, err := doMultipartAction(proxyURL, bucket, key, msg)
+	if err != nil {
+		return err
+	}
+	discardHTTPResp(resp)
+	resp.Body.Close()
+	return nil
+}
+
+// AbortMultipart cancels the multipart upload and removes all its parts
+func AbortMultipart(proxyURL, bucket, key, uploadID string) error {
+	msg := &dfc.ActionMsg{Action: dfc.ActMultipartAbort, Name: uploadID}
+	resp, err := doMultipartAction(proxyURL, bucket, key, msg)
+	if err != nil {
+		return err
+	}
+	discardHTTPResp(resp)
+	resp.Body.Close()
+	return nil
+}
+
+func doMultipartAction(proxyURL, bucket, key string, msg *dfc.ActionMsg) (*http.Response, error) {
+	injson, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	url := proxyURL + "/" + dfc.Rversion + "/" + dfc.Robjects + "/" + bucket + "/" + key
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(injson))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new http request, err: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkHTTPStatus(resp, msg.Action); err != nil {
+		discardHTTPResp(resp)
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
 // CreateLocalBucket sends a HTTP request to a proxy and asks it to create a local bucket
 func CreateLocalBucket(proxyURL, bucket string) error {
 	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActCreateLB})
@@ -664,6 +1013,32 @@ func CreateLocalBucket(proxyURL, bucket string) error {
 	return err
 }
 
+// CreateLocalBucketWithProps creates a local bucket with the given properties, e.g. erasure coded
+func CreateLocalBucketWithProps(proxyURL, bucket string, props dfc.BucketProps) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActCreateLB, Value: props})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", proxyURL+"/"+dfc.Rversion+"/"+dfc.Rbuckets+"/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, dfc.ActCreateLB); err != nil {
+		return err
+	}
+
+	// FIXME: same as CreateLocalBucket - wait for the local bucket map to propagate
+	time.Sleep(time.Second * 2)
+	return nil
+}
+
 // DestroyLocalBucket deletes a local bucket
 func DestroyLocalBucket(proxyURL, bucket string) error {
 	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActDestroyLB})
@@ -684,6 +1059,27 @@ func DestroyLocalBucket(proxyURL, bucket string) error {
 	return err
 }
 
+// RenameLocalBucket renames the local bucket; objects that, given the new name, map
+// to other targets migrate in the background after the call returns
+func RenameLocalBucket(proxyURL, bucket, newbucket string) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActRenameLB, Name: newbucket})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, proxyURL+"/"+dfc.Rversion+"/"+dfc.Rbuckets+"/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	err = checkHTTPStatus(resp, dfc.ActRenameLB)
+	discardHTTPResp(resp)
+	resp.Body.Close()
+	return err
+}
+
 // ListObjects returns a slice of object names of all objects that match the prefix in a bucket
 func ListObjects(proxyURL, bucket, prefix string, objectCountLimit int) ([]string, error) {
 	msg := &dfc.GetMsg{GetPrefix: prefix}
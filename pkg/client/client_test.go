@@ -2,6 +2,7 @@ package client_test
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"flag"
@@ -40,6 +41,51 @@ func TestPutSG(t *testing.T) {
 	}
 }
 
+func TestGetRange(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 1, "0"},
+		{10, 6, "abcdef"},
+		{30, 0, "uvwxyz"},
+		{30, 100, "uvwxyz"},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		n, err := client.GetRange(srv.URL, "bucket", "key", test.offset, test.length, buf)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d) failed, err: %v", test.offset, test.length, err)
+		}
+		if n != int64(len(test.expected)) || buf.String() != test.expected {
+			t.Errorf("GetRange(%d, %d): expected %q, got %q (%d bytes)",
+				test.offset, test.length, test.expected, buf.String(), n)
+		}
+	}
+	if _, err := client.GetRange(srv.URL, "bucket", "key", 100, 1, ioutil.Discard); err == nil {
+		t.Error("GetRange beyond the end of the object expected to fail")
+	}
+}
+
+func TestPutPart(t *testing.T) {
+	r, err := readers.NewInMemReader(1024, true /* withHash */)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	err = client.PutPart(server.URL, r, "bucket", "key", "uploadid", 1)
+	if err != nil {
+		t.Fatal("Put part failed", err)
+	}
+}
+
 func putFile(size int64, withHash bool) error {
 	fn := "dfc-client-test-" + client.FastRandomFilename(rand.New(rand.NewSource(time.Now().UnixNano())), 32)
 	dir := "/tmp"